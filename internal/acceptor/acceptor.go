@@ -6,19 +6,25 @@ import (
 	"github.com/cenkalti/rain/internal/logger"
 )
 
+// Filter is called for each accepted connection. The connection is closed if an error is returned.
+// Otherwise, the returned connection is sent to the channel in place of the accepted one.
+type Filter func(net.Conn) (net.Conn, error)
+
 // Acceptor accepts sockets from a listener and sends to a channel.
 type Acceptor struct {
 	listener net.Listener
+	filter   Filter
 	newConns chan net.Conn
 	closeC   chan struct{}
 	doneC    chan struct{}
 	log      logger.Logger
 }
 
-// New returns a new Acceptor.
-func New(lis net.Listener, newConns chan net.Conn, l logger.Logger) *Acceptor {
+// New returns a new Acceptor. filter may be nil.
+func New(lis net.Listener, filter Filter, newConns chan net.Conn, l logger.Logger) *Acceptor {
 	return &Acceptor{
 		listener: lis,
+		filter:   filter,
 		newConns: newConns,
 		closeC:   make(chan struct{}),
 		doneC:    make(chan struct{}),
@@ -57,6 +63,15 @@ func (a *Acceptor) Run() {
 			}
 			return
 		}
+		if a.filter != nil {
+			fconn, err := a.filter(conn)
+			if err != nil {
+				a.log.Debugln("rejected connection from", conn.RemoteAddr().String(), "reason:", err.Error())
+				conn.Close()
+				continue
+			}
+			conn = fconn
+		}
 		select {
 		case a.newConns <- conn:
 		case <-a.closeC:
@@ -0,0 +1,67 @@
+package announcer
+
+import (
+	"time"
+
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+// Number of announce results kept by PeriodicalAnnouncer.
+const historySize = 50
+
+// Announce results older than this many records have no effect on the health score.
+const healthWindow = 10
+
+// Record is the result of a single announce request.
+type Record struct {
+	// URL of the tracker that the request is made. May change between requests if the tracker is a Tier.
+	URL     string
+	Time    time.Time
+	Event   tracker.Event
+	Peers   int
+	Latency time.Duration
+	Error   *AnnounceError
+}
+
+type history []Record
+
+func (h *history) add(r Record) {
+	if len(*h) < historySize {
+		*h = append(*h, r)
+		return
+	}
+	copy(*h, (*h)[1:])
+	(*h)[len(*h)-1] = r
+}
+
+func (h history) copy() []Record {
+	ret := make([]Record, len(h))
+	copy(ret, h)
+	return ret
+}
+
+// health returns a score between 0 and 100 calculated from the recent announces to the URL.
+// Recent results have more weight. Slow responses lower the score.
+// tracker.NeutralHealth is returned if there is no announce to the URL yet.
+func (h history) health(url string) int {
+	var sum, total float64
+	weight := 1.0
+	n := 0
+	for i := len(h) - 1; i >= 0 && n < healthWindow; i-- {
+		r := h[i]
+		if r.URL != url {
+			continue
+		}
+		if r.Error == nil {
+			// 1 for instant response, 0.5 for a response in 10 seconds.
+			sum += weight / (1 + r.Latency.Seconds()/10)
+		}
+		total += weight
+		weight *= 0.8
+		n++
+	}
+	if n == 0 {
+		return tracker.NeutralHealth
+	}
+	return int(100 * sum / total)
+}
@@ -0,0 +1,45 @@
+package announcer
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+func TestHistory(t *testing.T) {
+	var h history
+	if s := h.health("a"); s != tracker.NeutralHealth {
+		t.Errorf("health without records: %d", s)
+	}
+	for i := 0; i < historySize+5; i++ {
+		h.add(Record{URL: "a", Peers: i})
+	}
+	if len(h) != historySize {
+		t.Fatalf("history length: %d", len(h))
+	}
+	if h[0].Peers != 5 {
+		t.Errorf("oldest record is not dropped: %d", h[0].Peers)
+	}
+	if s := h.health("a"); s != 100 {
+		t.Errorf("health of working tracker: %d", s)
+	}
+
+	// Recent errors lower the score more than the old ones.
+	h.add(Record{URL: "a", Error: &AnnounceError{Err: errors.New("error")}})
+	failed := h.health("a")
+	if failed >= 100 || failed < tracker.NeutralHealth {
+		t.Errorf("health after an error: %d", failed)
+	}
+	h.add(Record{URL: "a"})
+	if s := h.health("a"); s <= failed {
+		t.Errorf("health does not recover: %d", s)
+	}
+
+	// Slow responses lower the score.
+	h.add(Record{URL: "b", Latency: 10 * time.Second})
+	if s := h.health("b"); s != 50 {
+		t.Errorf("health of slow tracker: %d", s)
+	}
+}
@@ -32,6 +32,9 @@ const (
 	NotWorking
 )
 
+// Maximum interval between announce retries to a tracker that is not working.
+const maxBackoff = 30 * time.Minute
+
 // PeriodicalAnnouncer announces the Torrent to the Tracker periodically.
 type PeriodicalAnnouncer struct {
 	Tracker       tracker.Tracker
@@ -42,6 +45,8 @@ type PeriodicalAnnouncer struct {
 	minInterval   time.Duration
 	seeders       int
 	leechers      int
+	peers         int
+	peersTotal    int
 	warningMsg    string
 	lastError     *AnnounceError
 	log           logger.Logger
@@ -50,7 +55,10 @@ type PeriodicalAnnouncer struct {
 	backoff       backoff.BackOff
 	getTorrent    func() tracker.Torrent
 	lastAnnounce  time.Time
+	lastEvent     tracker.Event
+	lastURL       string
 	nextAnnounce  time.Time
+	history       history
 	HasAnnounced  bool
 	responseC     chan *tracker.AnnounceResponse
 	errC          chan error
@@ -83,7 +91,7 @@ func NewPeriodicalAnnouncer(trk tracker.Tracker, numWant int, minInterval time.D
 			InitialInterval:     5 * time.Second,
 			RandomizationFactor: 0.5,
 			Multiplier:          2,
-			MaxInterval:         30 * time.Minute,
+			MaxInterval:         maxBackoff,
 			MaxElapsedTime:      0, // never stop
 			Clock:               backoff.SystemClock,
 		},
@@ -162,9 +170,12 @@ func (a *PeriodicalAnnouncer) Run() {
 			}
 			a.doAnnounce(ctx, tracker.EventNone, a.numWant)
 		case resp := <-a.responseC:
+			a.addRecord(len(resp.Peers), nil)
 			a.status = Working
 			a.seeders = int(resp.Seeders)
 			a.leechers = int(resp.Leechers)
+			a.peers = len(resp.Peers)
+			a.peersTotal += len(resp.Peers)
 			a.warningMsg = resp.WarningMessage
 			if a.warningMsg != "" {
 				a.log.Debugln("announce warning:", a.warningMsg)
@@ -188,6 +199,7 @@ func (a *PeriodicalAnnouncer) Run() {
 			a.status = NotWorking
 			// Give more friendly error to the user
 			a.lastError = a.newAnnounceError(err)
+			a.addRecord(0, a.lastError)
 			if a.lastError.Unknown {
 				a.log.Errorln("announce error:", a.lastError.ErrorWithType())
 			} else {
@@ -227,32 +239,66 @@ func (a *PeriodicalAnnouncer) getNextInterval() time.Duration {
 	return a.interval
 }
 
+// getNextIntervalFromError returns the backoff interval scaled by the health of the tracker that is going to be announced next.
+// If the tracker is a Tier, next announce is made to another tracker in the tier, so a healthy one is retried sooner.
 func (a *PeriodicalAnnouncer) getNextIntervalFromError(err *AnnounceError) time.Duration {
 	if terr, ok := err.Err.(*tracker.Error); ok && terr.RetryIn > 0 {
 		return terr.RetryIn
 	}
-	return a.backoff.NextBackOff()
+	interval := a.backoff.NextBackOff()
+	// Between 0.5x for health 100 and 1.5x for health 0.
+	interval = interval * time.Duration(150-a.history.health(a.Tracker.URL())) / 100
+	if interval > maxBackoff {
+		interval = maxBackoff
+	}
+	return interval
 }
 
 func (a *PeriodicalAnnouncer) doAnnounce(ctx context.Context, event tracker.Event, numWant int) {
+	a.lastURL = a.Tracker.URL()
 	go a.announce(ctx, event, numWant)
 	a.status = Contacting
+	a.lastEvent = event
 	a.lastAnnounce = time.Now()
 }
 
+// addRecord adds the result of the last announce to the history and updates the health of the tracker.
+func (a *PeriodicalAnnouncer) addRecord(peers int, err *AnnounceError) {
+	now := time.Now()
+	a.history.add(Record{
+		URL:     a.lastURL,
+		Time:    a.lastAnnounce,
+		Event:   a.lastEvent,
+		Peers:   peers,
+		Latency: now.Sub(a.lastAnnounce),
+		Error:   err,
+	})
+	if tier, ok := a.Tracker.(*tracker.Tier); ok {
+		tier.SetHealth(a.lastURL, a.history.health(a.lastURL))
+	}
+}
+
 func (a *PeriodicalAnnouncer) announce(ctx context.Context, event tracker.Event, numWant int) {
 	announce(ctx, a.Tracker, event, numWant, a.getTorrent(), a.responseC, a.errC)
 }
 
 // Stats about the announcer.
 type Stats struct {
-	Status       Status
-	Error        *AnnounceError
-	Warning      string
-	Seeders      int
-	Leechers     int
+	Status   Status
+	Error    *AnnounceError
+	Warning  string
+	Seeders  int
+	Leechers int
+	// Number of peers in the last response.
+	Peers int
+	// Total number of peers returned since the announcer is started.
+	PeersTotal   int
 	LastAnnounce time.Time
 	NextAnnounce time.Time
+	// Health score of the current tracker, between 0 and 100.
+	Health int
+	// Results of the recent announces, oldest first.
+	History []Record
 }
 
 func (a *PeriodicalAnnouncer) stats() Stats {
@@ -262,8 +308,12 @@ func (a *PeriodicalAnnouncer) stats() Stats {
 		Warning:      a.warningMsg,
 		Seeders:      a.seeders,
 		Leechers:     a.leechers,
+		Peers:        a.peers,
+		PeersTotal:   a.peersTotal,
 		LastAnnounce: a.lastAnnounce,
 		NextAnnounce: a.nextAnnounce,
+		Health:       a.history.health(a.Tracker.URL()),
+		History:      a.history.copy(),
 	}
 }
 
@@ -399,7 +449,12 @@ func (a *PeriodicalAnnouncer) newAnnounceError(err error) (e *AnnounceError) {
 	return
 }
 
+// Type returns the type name of the underlying error.
+func (e *AnnounceError) Type() string {
+	return reflect.TypeOf(e.Err).String()
+}
+
 // ErrorWithType returns the error string that is prefixed with type name.
 func (e *AnnounceError) ErrorWithType() string {
-	return reflect.TypeOf(e.Err).String() + ": " + e.Err.Error()
+	return e.Type() + ": " + e.Err.Error()
 }
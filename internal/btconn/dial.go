@@ -13,8 +13,10 @@ import (
 // Dial new connection to the address. Does the BitTorrent protocol handshake.
 // Handles encryption. May try to connect again if encryption does not match with given setting.
 // Returns a net.Conn that is ready for sending/receiving BitTorrent peer protocol messages.
+// TCP connection is opened with the dialer. If dialer is nil, a default net.Dialer is used.
 func Dial(
 	addr net.Addr,
+	dialer Dialer,
 	dialTimeout, handshakeTimeout time.Duration,
 	enableEncryption,
 	forceEncryption bool,
@@ -38,8 +40,15 @@ func Dial(
 
 	// First connection
 	log.Debug("Connecting to peer...")
-	dialer := net.Dialer{Timeout: dialTimeout}
-	conn, err = dialer.DialContext(ctx, addr.Network(), addr.String())
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
+	dial := func() (net.Conn, error) {
+		dctx, dcancel := context.WithTimeout(ctx, dialTimeout)
+		defer dcancel()
+		return dialer.DialContext(dctx, addr.Network(), addr.String())
+	}
+	conn, err = dial()
 	if err != nil {
 		return
 	}
@@ -97,7 +106,7 @@ func Dial(
 			// Close current connection and try again without encryption
 			conn.Close()
 			log.Debug("Connecting again without encryption...")
-			conn, err = dialer.DialContext(ctx, addr.Network(), addr.String())
+			conn, err = dial()
 			if err != nil {
 				return
 			}
@@ -155,3 +164,21 @@ func Dial(
 	}
 	return
 }
+
+// Dialer opens TCP connections to peers. It is implemented by net.Dialer.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// HolepunchDialer returns a Dialer that connects from the local address.
+// The port in localAddr may be the same port with the listener returned from Listen, in that case
+// the peer can do a TCP simultaneous open by dialing us at the same time.
+func HolepunchDialer(localAddr *net.TCPAddr) Dialer {
+	if localAddr.Port != 0 && !reusePortSupported {
+		localAddr = &net.TCPAddr{IP: localAddr.IP}
+	}
+	return &net.Dialer{
+		LocalAddr: localAddr,
+		Control:   reusePort,
+	}
+}
@@ -0,0 +1,21 @@
+package btconn
+
+import (
+	"context"
+	"net"
+)
+
+// Listen for incoming BitTorrent connections on the TCP address.
+// If reuse is true, the port of the listener can be used as the local port of outgoing connections
+// for TCP simultaneous open.
+func Listen(addr *net.TCPAddr, reuse bool) (*net.TCPListener, error) {
+	var lc net.ListenConfig
+	if reuse {
+		lc.Control = reusePort
+	}
+	l, err := lc.Listen(context.Background(), "tcp4", addr.String())
+	if err != nil {
+		return nil, err
+	}
+	return l.(*net.TCPListener), nil
+}
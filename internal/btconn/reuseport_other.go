@@ -0,0 +1,12 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package btconn
+
+import "syscall"
+
+// reusePortSupported is true if the listening port can be shared with outgoing connections on this platform.
+const reusePortSupported = false
+
+func reusePort(network, address string, c syscall.RawConn) error {
+	return nil
+}
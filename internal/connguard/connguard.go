@@ -0,0 +1,197 @@
+// Package connguard protects the session against hosts that open too many incoming connections
+// or fail the handshake repeatedly.
+package connguard
+
+import (
+	"errors"
+	"net"
+	"sync"
+	"time"
+)
+
+// Errors returned from Guard.Accept method.
+var (
+	ErrTooManyConnections = errors.New("too many connections from IP")
+	ErrRateLimited        = errors.New("connection rate limit exceeded for IP")
+	ErrPenalized          = errors.New("IP is penalized for failed handshakes")
+)
+
+// Hosts that have no active state are removed from memory at this interval.
+const pruneInterval = time.Minute
+
+// Config for Guard. Zero values disable the corresponding limit.
+type Config struct {
+	// Max number of concurrent connections from a single IP.
+	MaxPerIP int
+	// Number of new connections allowed per second from a single IP.
+	Rate float64
+	// Number of connections that can be accepted at once before rate limiting kicks in.
+	Burst int
+	// Number of consecutive handshake failures after which the IP is put into the penalty box.
+	MaxFailures int
+	// Duration of the penalty.
+	Penalty time.Duration
+}
+
+// Guard keeps state about remote IPs of incoming connections and decides whether a new connection is accepted.
+type Guard struct {
+	config Config
+
+	m         sync.Mutex
+	hosts     map[string]*host
+	lastPrune time.Time
+	stats     Stats
+}
+
+type host struct {
+	lastSeen    time.Time
+	conns       int
+	tokens      float64
+	lastRefill  time.Time
+	failures    int
+	bannedUntil time.Time
+}
+
+// Stats about Guard.
+type Stats struct {
+	// Number of connections that are rejected due to the per-IP connection limit.
+	RejectedTooManyConnections int64
+	// Number of connections that are rejected due to the per-IP rate limit.
+	RejectedRateLimited int64
+	// Number of connections that are rejected because the IP is in the penalty box.
+	RejectedPenalized int64
+	// Number of IPs that are currently in the penalty box.
+	PenalizedIPs int
+}
+
+// New returns a new Guard.
+func New(cfg Config) *Guard {
+	if cfg.Rate > 0 && cfg.Burst < 1 {
+		cfg.Burst = 1
+	}
+	return &Guard{
+		config: cfg,
+		hosts:  make(map[string]*host),
+	}
+}
+
+// Accept checks the limits for the remote IP of the connection.
+// If the connection is accepted, the returned connection must be used in place of the original one.
+// Slot of the connection is released when the returned connection is closed.
+func (g *Guard) Accept(conn net.Conn) (net.Conn, error) {
+	ip := conn.RemoteAddr().(*net.TCPAddr).IP.String()
+	now := time.Now()
+	g.m.Lock()
+	defer g.m.Unlock()
+	g.prune(now)
+	h, ok := g.hosts[ip]
+	if !ok {
+		h = &host{tokens: float64(g.config.Burst), lastRefill: now}
+		g.hosts[ip] = h
+	}
+	h.lastSeen = now
+	if now.Before(h.bannedUntil) {
+		g.stats.RejectedPenalized++
+		return nil, ErrPenalized
+	}
+	if g.config.MaxPerIP > 0 && h.conns >= g.config.MaxPerIP {
+		g.stats.RejectedTooManyConnections++
+		return nil, ErrTooManyConnections
+	}
+	if g.config.Rate > 0 {
+		h.tokens += now.Sub(h.lastRefill).Seconds() * g.config.Rate
+		if max := float64(g.config.Burst); h.tokens > max {
+			h.tokens = max
+		}
+		h.lastRefill = now
+		if h.tokens < 1 {
+			g.stats.RejectedRateLimited++
+			return nil, ErrRateLimited
+		}
+		h.tokens--
+	}
+	h.conns++
+	return &guardedConn{Conn: conn, guard: g, ip: ip}, nil
+}
+
+// HandshakeFailed must be called when the handshake of an accepted connection fails.
+func (g *Guard) HandshakeFailed(ip net.IP) {
+	if g.config.MaxFailures <= 0 {
+		return
+	}
+	g.m.Lock()
+	defer g.m.Unlock()
+	h, ok := g.hosts[ip.String()]
+	if !ok {
+		return
+	}
+	h.failures++
+	if h.failures >= g.config.MaxFailures {
+		h.failures = 0
+		h.bannedUntil = time.Now().Add(g.config.Penalty)
+	}
+}
+
+// HandshakeSucceeded must be called when the handshake of an accepted connection is successful.
+func (g *Guard) HandshakeSucceeded(ip net.IP) {
+	g.m.Lock()
+	defer g.m.Unlock()
+	if h, ok := g.hosts[ip.String()]; ok {
+		h.failures = 0
+	}
+}
+
+// Stats returns statistics about rejected connections.
+func (g *Guard) Stats() Stats {
+	now := time.Now()
+	g.m.Lock()
+	defer g.m.Unlock()
+	stats := g.stats
+	for _, h := range g.hosts {
+		if now.Before(h.bannedUntil) {
+			stats.PenalizedIPs++
+		}
+	}
+	return stats
+}
+
+func (g *Guard) release(ip string) {
+	g.m.Lock()
+	defer g.m.Unlock()
+	if h, ok := g.hosts[ip]; ok {
+		h.conns--
+	}
+}
+
+// prune removes the hosts that do not have any connections or penalties and whose rate limit buckets are full.
+// Recorded handshake failures are forgotten after the host is not seen for the penalty duration.
+func (g *Guard) prune(now time.Time) {
+	if now.Sub(g.lastPrune) < pruneInterval {
+		return
+	}
+	g.lastPrune = now
+	for ip, h := range g.hosts {
+		if h.conns > 0 || now.Before(h.bannedUntil) {
+			continue
+		}
+		if h.failures > 0 && now.Sub(h.lastSeen) < g.config.Penalty {
+			continue
+		}
+		if g.config.Rate > 0 && h.tokens+now.Sub(h.lastRefill).Seconds()*g.config.Rate < float64(g.config.Burst) {
+			continue
+		}
+		delete(g.hosts, ip)
+	}
+}
+
+type guardedConn struct {
+	net.Conn
+	guard *Guard
+	ip    string
+	once  sync.Once
+}
+
+func (c *guardedConn) Close() error {
+	c.once.Do(func() { c.guard.release(c.ip) })
+	return c.Conn.Close()
+}
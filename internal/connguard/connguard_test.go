@@ -0,0 +1,76 @@
+package connguard
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeConn struct {
+	net.Conn
+	addr *net.TCPAddr
+}
+
+func (c *fakeConn) RemoteAddr() net.Addr { return c.addr }
+func (c *fakeConn) Close() error         { return nil }
+
+func newConn(ip string) net.Conn {
+	return &fakeConn{addr: &net.TCPAddr{IP: net.ParseIP(ip), Port: 6881}}
+}
+
+func TestMaxPerIP(t *testing.T) {
+	g := New(Config{MaxPerIP: 2})
+	c1, err := g.Accept(newConn("1.2.3.4"))
+	assert.NoError(t, err)
+	_, err = g.Accept(newConn("1.2.3.4"))
+	assert.NoError(t, err)
+	_, err = g.Accept(newConn("1.2.3.4"))
+	assert.Equal(t, ErrTooManyConnections, err)
+	_, err = g.Accept(newConn("5.6.7.8"))
+	assert.NoError(t, err)
+
+	// Closing twice must release a single slot.
+	c1.Close()
+	c1.Close()
+	_, err = g.Accept(newConn("1.2.3.4"))
+	assert.NoError(t, err)
+	_, err = g.Accept(newConn("1.2.3.4"))
+	assert.Equal(t, ErrTooManyConnections, err)
+	assert.Equal(t, int64(2), g.Stats().RejectedTooManyConnections)
+}
+
+func TestRateLimit(t *testing.T) {
+	g := New(Config{Rate: 1000, Burst: 2})
+	for i := 0; i < 2; i++ {
+		_, err := g.Accept(newConn("1.2.3.4"))
+		assert.NoError(t, err)
+	}
+	_, err := g.Accept(newConn("1.2.3.4"))
+	assert.Equal(t, ErrRateLimited, err)
+	time.Sleep(10 * time.Millisecond)
+	_, err = g.Accept(newConn("1.2.3.4"))
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1), g.Stats().RejectedRateLimited)
+}
+
+func TestPenalty(t *testing.T) {
+	g := New(Config{MaxFailures: 2, Penalty: 50 * time.Millisecond})
+	ip := net.ParseIP("1.2.3.4")
+	_, err := g.Accept(newConn("1.2.3.4"))
+	assert.NoError(t, err)
+	g.HandshakeFailed(ip)
+	g.HandshakeSucceeded(ip)
+	g.HandshakeFailed(ip)
+	_, err = g.Accept(newConn("1.2.3.4"))
+	assert.NoError(t, err)
+	g.HandshakeFailed(ip)
+	_, err = g.Accept(newConn("1.2.3.4"))
+	assert.Equal(t, ErrPenalized, err)
+	assert.Equal(t, 1, g.Stats().PenalizedIPs)
+	time.Sleep(60 * time.Millisecond)
+	_, err = g.Accept(newConn("1.2.3.4"))
+	assert.NoError(t, err)
+	assert.Equal(t, Stats{RejectedPenalized: 1}, g.Stats())
+}
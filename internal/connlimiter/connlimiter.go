@@ -0,0 +1,220 @@
+// Package connlimiter limits the number of peer connections and half-open dials that are shared by all torrents in a Session.
+package connlimiter
+
+import (
+	"sort"
+	"sync"
+)
+
+// Limiter decides whether a torrent can open a new connection by looking at the connection counts of all torrents.
+// Connection slots are reserved for torrents that have fewer connections than minPerTorrent and could not get a slot,
+// so torrents that are started later are not starved by torrents that have already connected to many peers.
+// Half-open dials are shared equally between torrents.
+type Limiter struct {
+	maxConns      int
+	maxHalfOpen   int
+	minPerTorrent int
+
+	m        sync.Mutex
+	conns    int
+	halfOpen int
+	owners   map[*Owner]struct{}
+}
+
+// Owner counts the connections of a single torrent.
+type Owner struct {
+	l        *Limiter
+	conns    int
+	halfOpen int
+	// Set when the owner cannot get a slot. Waiting owners are notified when slots are released.
+	// Slots are reserved for waiting owners until they have minPerTorrent connections or they stop waiting.
+	waiting bool
+	notifyC chan struct{}
+}
+
+// Stats about Limiter.
+type Stats struct {
+	Conns       int
+	MaxConns    int
+	HalfOpen    int
+	MaxHalfOpen int
+}
+
+// New returns a new Limiter. Zero values for maxConns and maxHalfOpen means there is no limit.
+func New(maxConns, maxHalfOpen, minPerTorrent int) *Limiter {
+	return &Limiter{
+		maxConns:      maxConns,
+		maxHalfOpen:   maxHalfOpen,
+		minPerTorrent: minPerTorrent,
+		owners:        make(map[*Owner]struct{}),
+	}
+}
+
+// Stats returns the current number of connections in all torrents.
+func (l *Limiter) Stats() Stats {
+	l.m.Lock()
+	defer l.m.Unlock()
+	return Stats{
+		Conns:       l.conns,
+		MaxConns:    l.maxConns,
+		HalfOpen:    l.halfOpen,
+		MaxHalfOpen: l.maxHalfOpen,
+	}
+}
+
+// NewOwner registers a new torrent to the Limiter.
+// When a waiting owner can get a slot, a value is sent to notifyC without blocking.
+func (l *Limiter) NewOwner(notifyC chan struct{}) *Owner {
+	o := &Owner{
+		l:       l,
+		notifyC: notifyC,
+	}
+	l.m.Lock()
+	l.owners[o] = struct{}{}
+	l.m.Unlock()
+	return o
+}
+
+// Close unregisters the owner and releases all of its slots.
+func (o *Owner) Close() {
+	if o == nil {
+		return
+	}
+	l := o.l
+	l.m.Lock()
+	defer l.m.Unlock()
+	l.conns -= o.conns
+	l.halfOpen -= o.halfOpen
+	o.conns, o.halfOpen = 0, 0
+	delete(l.owners, o)
+	l.notifyWaiting()
+}
+
+// AcquireDial takes a connection slot and a half-open slot for dialing a peer.
+// Returns false if the limit is reached.
+func (o *Owner) AcquireDial() bool {
+	if o == nil {
+		return true
+	}
+	l := o.l
+	l.m.Lock()
+	defer l.m.Unlock()
+	if !l.canConnect(o) || !l.canDial(o) {
+		o.waiting = true
+		return false
+	}
+	o.conns++
+	o.halfOpen++
+	l.conns++
+	l.halfOpen++
+	if o.conns >= l.minPerTorrent {
+		o.waiting = false
+	}
+	return true
+}
+
+// StopWaiting must be called when the owner does not need new connections anymore,
+// so the slots that are reserved for it can be used by other owners.
+func (o *Owner) StopWaiting() {
+	if o == nil {
+		return
+	}
+	o.l.m.Lock()
+	o.waiting = false
+	o.l.m.Unlock()
+}
+
+// AcquireConn takes a connection slot for accepting a peer.
+// Returns false if the limit is reached.
+func (o *Owner) AcquireConn() bool {
+	if o == nil {
+		return true
+	}
+	l := o.l
+	l.m.Lock()
+	defer l.m.Unlock()
+	if !l.canConnect(o) {
+		return false
+	}
+	o.conns++
+	l.conns++
+	return true
+}
+
+// Set the actual number of connections and half-open dials of the owner.
+// Slots that are taken with Acquire methods are released by setting lower values.
+func (o *Owner) Set(conns, halfOpen int) {
+	if o == nil {
+		return
+	}
+	l := o.l
+	l.m.Lock()
+	defer l.m.Unlock()
+	released := conns < o.conns || halfOpen < o.halfOpen
+	l.conns += conns - o.conns
+	l.halfOpen += halfOpen - o.halfOpen
+	o.conns, o.halfOpen = conns, halfOpen
+	if released {
+		l.notifyWaiting()
+	}
+}
+
+func (l *Limiter) canConnect(o *Owner) bool {
+	if l.maxConns <= 0 {
+		return true
+	}
+	if l.conns >= l.maxConns {
+		return false
+	}
+	if o.conns < l.minPerTorrent {
+		return true
+	}
+	var reserved int
+	for wo := range l.owners {
+		if wo.waiting && wo != o && wo.conns < l.minPerTorrent {
+			reserved += l.minPerTorrent - wo.conns
+		}
+	}
+	return l.conns+reserved < l.maxConns
+}
+
+func (l *Limiter) canDial(o *Owner) bool {
+	if l.maxHalfOpen <= 0 {
+		return true
+	}
+	if l.halfOpen >= l.maxHalfOpen {
+		return false
+	}
+	if len(l.owners) == 0 {
+		return true
+	}
+	share := (l.maxHalfOpen + len(l.owners) - 1) / len(l.owners)
+	return o.halfOpen < share
+}
+
+// notifyWaiting wakes up as many waiting owners as the number of free slots, starting from the ones with fewest connections.
+func (l *Limiter) notifyWaiting() {
+	var waiting []*Owner
+	for o := range l.owners {
+		if o.waiting {
+			waiting = append(waiting, o)
+		}
+	}
+	if len(waiting) == 0 {
+		return
+	}
+	sort.Slice(waiting, func(i, j int) bool { return waiting[i].conns < waiting[j].conns })
+	free := len(waiting)
+	if l.maxConns > 0 {
+		free = l.maxConns - l.conns
+	}
+	if l.maxHalfOpen > 0 && l.maxHalfOpen-l.halfOpen < free {
+		free = l.maxHalfOpen - l.halfOpen
+	}
+	for i := 0; i < free && i < len(waiting); i++ {
+		select {
+		case waiting[i].notifyC <- struct{}{}:
+		default:
+		}
+	}
+}
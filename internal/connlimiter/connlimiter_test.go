@@ -0,0 +1,85 @@
+package connlimiter
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMaxConns(t *testing.T) {
+	l := New(3, 0, 0)
+	o := l.NewOwner(make(chan struct{}, 1))
+	assert.True(t, o.AcquireDial())
+	assert.True(t, o.AcquireConn())
+	assert.True(t, o.AcquireDial())
+	assert.False(t, o.AcquireDial())
+	assert.False(t, o.AcquireConn())
+	assert.Equal(t, Stats{Conns: 3, MaxConns: 3, HalfOpen: 2}, l.Stats())
+
+	// One of the dials has failed.
+	o.Set(2, 1)
+	assert.True(t, o.AcquireConn())
+	o.Close()
+	assert.Equal(t, Stats{MaxConns: 3}, l.Stats())
+}
+
+func TestReserveForTorrentsWithFewPeers(t *testing.T) {
+	l := New(10, 0, 3)
+	o1 := l.NewOwner(make(chan struct{}, 1))
+	notifyC := make(chan struct{}, 1)
+	o2 := l.NewOwner(notifyC)
+	for i := 0; i < 9; i++ {
+		assert.True(t, o1.AcquireDial())
+	}
+	assert.True(t, o2.AcquireDial())
+	assert.False(t, o2.AcquireDial())
+
+	// Slots released by o1 are reserved for o2.
+	o1.Set(6, 0)
+	select {
+	case <-notifyC:
+	default:
+		t.Fatal("owner is not notified")
+	}
+	assert.True(t, o2.AcquireDial())
+	assert.True(t, o2.AcquireDial())
+	assert.True(t, o2.AcquireDial())
+	assert.False(t, o2.AcquireDial())
+	assert.False(t, o1.AcquireDial())
+}
+
+func TestReservedSlotsAreNotTaken(t *testing.T) {
+	l := New(10, 0, 3)
+	o1 := l.NewOwner(make(chan struct{}, 1))
+	o2 := l.NewOwner(make(chan struct{}, 1))
+	for i := 0; i < 10; i++ {
+		assert.True(t, o1.AcquireDial())
+	}
+	assert.False(t, o2.AcquireDial())
+	o1.Set(8, 0)
+	assert.False(t, o1.AcquireConn())
+	assert.True(t, o2.AcquireDial())
+	assert.True(t, o2.AcquireDial())
+	assert.False(t, o2.AcquireDial())
+
+	o1.Set(7, 0)
+	o2.StopWaiting()
+	assert.True(t, o1.AcquireConn())
+}
+
+func TestHalfOpenShare(t *testing.T) {
+	l := New(0, 4, 0)
+	o1 := l.NewOwner(make(chan struct{}, 1))
+	o2 := l.NewOwner(make(chan struct{}, 1))
+	assert.True(t, o1.AcquireDial())
+	assert.True(t, o1.AcquireDial())
+	assert.False(t, o1.AcquireDial())
+	assert.True(t, o2.AcquireDial())
+	assert.True(t, o2.AcquireDial())
+	assert.False(t, o2.AcquireDial())
+
+	// Dials completed and became connections.
+	o1.Set(2, 0)
+	assert.True(t, o1.AcquireDial())
+	assert.Equal(t, Stats{Conns: 5, HalfOpen: 3, MaxHalfOpen: 4}, l.Stats())
+}
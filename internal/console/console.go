@@ -1,6 +1,7 @@
 package console
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"os"
@@ -20,6 +21,7 @@ const (
 	torrents int = iota
 	sessionStats
 	addTorrent
+	speedLimits
 	help
 )
 
@@ -28,6 +30,7 @@ const (
 	general int = iota
 	stats
 	trackers
+	trackerHistory
 	peers
 	webseeds
 )
@@ -56,12 +59,15 @@ type Console struct {
 	selectedPage int
 	// distance Y from 0,0
 	tabAdjust int
+	// id of the torrent whose speed limits are being edited, empty for session limits
+	speedLimitsID string
 
 	// fields to hold responsed from rpc requests
 	torrents     []Torrent
 	stats        rpctypes.Stats
 	sessionStats rpctypes.SessionStats
 	trackers     []rpctypes.Tracker
+	history      []rpctypes.TrackerAnnounce
 	peers        []rpctypes.Peer
 	webseeds     []rpctypes.Webseed
 
@@ -135,6 +141,7 @@ func (c *Console) keybindings(g *gocui.Gui) {
 	_ = g.SetKeybinding("help", 'q', gocui.ModNone, c.quit)
 	_ = g.SetKeybinding("session-stats", 'q', gocui.ModNone, c.quit)
 	_ = g.SetKeybinding("add-torrent", gocui.KeyCtrlQ, gocui.ModNone, c.quit)
+	_ = g.SetKeybinding("speed-limits", gocui.KeyCtrlQ, gocui.ModNone, c.quit)
 
 	// Navigation
 	_ = g.SetKeybinding("torrents", 'j', gocui.ModNone, c.cursorDown)
@@ -154,6 +161,7 @@ func (c *Console) keybindings(g *gocui.Gui) {
 	_ = g.SetKeybinding("torrents", 'g', gocui.ModAlt, c.switchGeneral)
 	_ = g.SetKeybinding("torrents", 's', gocui.ModAlt, c.switchStats)
 	_ = g.SetKeybinding("torrents", 't', gocui.ModAlt, c.switchTrackers)
+	_ = g.SetKeybinding("torrents", 'h', gocui.ModAlt, c.switchTrackerHistory)
 	_ = g.SetKeybinding("torrents", 'p', gocui.ModAlt, c.switchPeers)
 	_ = g.SetKeybinding("torrents", 'w', gocui.ModAlt, c.switchWebseeds)
 
@@ -165,6 +173,10 @@ func (c *Console) keybindings(g *gocui.Gui) {
 	_ = g.SetKeybinding("torrents", gocui.KeyCtrlV, gocui.ModNone, c.verify)
 	_ = g.SetKeybinding("torrents", gocui.KeyCtrlA, gocui.ModNone, c.switchAddTorrent)
 	_ = g.SetKeybinding("add-torrent", gocui.KeyEnter, gocui.ModNone, c.addTorrentHandleEnter)
+	_ = g.SetKeybinding("torrents", gocui.KeyCtrlL, gocui.ModNone, c.switchTorrentSpeedLimits)
+	_ = g.SetKeybinding("torrents", gocui.KeyCtrlL, gocui.ModAlt, c.switchSessionSpeedLimits)
+	_ = g.SetKeybinding("speed-limits", gocui.KeyEnter, gocui.ModNone, c.speedLimitsHandleEnter)
+	_ = g.SetKeybinding("torrents", gocui.KeyCtrlT, gocui.ModNone, c.toggleTurtleMode)
 }
 
 func (c *Console) startUpdatingTorrents(g *gocui.Gui) {
@@ -220,7 +232,10 @@ func (c *Console) layout(g *gocui.Gui) error {
 	if c.selectedPage != help {
 		_ = g.DeleteView("help")
 	}
-	if c.selectedPage != addTorrent {
+	if c.selectedPage != speedLimits {
+		_ = g.DeleteView("speed-limits")
+	}
+	if c.selectedPage != addTorrent && c.selectedPage != speedLimits {
 		_ = g.DeleteView("add-torrent")
 		g.Cursor = false
 	}
@@ -254,6 +269,13 @@ func (c *Console) layout(g *gocui.Gui) error {
 		}
 		g.Cursor = true
 		_, err = g.SetCurrentView("add-torrent")
+	case speedLimits:
+		err = c.drawSpeedLimits(g)
+		if err != nil {
+			return err
+		}
+		g.Cursor = true
+		_, err = g.SetCurrentView("speed-limits")
 	}
 	return err
 }
@@ -296,6 +318,7 @@ func (c *Console) drawHelp(g *gocui.Gui) error {
 	fmt.Fprintln(v, "     alt+g  switch to General info tab")
 	fmt.Fprintln(v, "     alt+s  switch to Stats tab")
 	fmt.Fprintln(v, "     alt+t  switch to Trackers tab")
+	fmt.Fprintln(v, "     alt+h  switch to Tracker History tab")
 	fmt.Fprintln(v, "     alt+p  switch to Peers tab")
 	fmt.Fprintln(v, "     alt+w  switch to Webseeds tab")
 
@@ -307,6 +330,9 @@ func (c *Console) drawHelp(g *gocui.Gui) error {
 	fmt.Fprintln(v, "ctrl+alt+a  Announce torrent")
 	fmt.Fprintln(v, "    ctrl+v  Verify torrent")
 	fmt.Fprintln(v, "    ctrl+a  Add new torrent")
+	fmt.Fprintln(v, "    ctrl+l  Set speed limits of torrent")
+	fmt.Fprintln(v, "ctrl+alt+l  Set global speed limits")
+	fmt.Fprintln(v, "    ctrl+t  Toggle turtle mode")
 
 	return nil
 }
@@ -326,6 +352,41 @@ func (c *Console) drawAddTorrent(g *gocui.Gui) error {
 	return nil
 }
 
+func (c *Console) drawSpeedLimits(g *gocui.Gui) error {
+	maxX, maxY := g.Size()
+	v, err := g.SetView("speed-limits", 5, 2, maxX-6, maxY-3)
+	if err != nil {
+		if err != gocui.ErrUnknownView {
+			return err
+		}
+		v.Frame = true
+		v.Editable = true
+		target := "global"
+		var download, upload int64
+		if c.speedLimitsID == "" {
+			s, err := c.client.GetSessionStats()
+			if err != nil {
+				fmt.Fprintln(v, "error:", err)
+				return nil
+			}
+			download, upload = s.SpeedLimitDownload, s.SpeedLimitUpload
+		} else {
+			target = "torrent " + c.speedLimitsID
+			s, err := c.client.GetTorrentStats(c.speedLimitsID)
+			if err != nil {
+				fmt.Fprintln(v, "error:", err)
+				return nil
+			}
+			download, upload = s.SpeedLimit.Download, s.SpeedLimit.Upload
+		}
+		v.Title = "Speed limits of " + target + " as \"download upload\" in KB/s, 0 for unlimited (Press ctrl-q to close window)"
+		line := fmt.Sprintf("%d %d", download, upload)
+		fmt.Fprint(v, line)
+		_ = v.SetCursor(len(line), 0)
+	}
+	return nil
+}
+
 func (c *Console) drawSessionStats(g *gocui.Gui) error {
 	maxX, maxY := g.Size()
 	v, err := g.SetView("session-stats", 5, 2, maxX-6, maxY-3)
@@ -535,6 +596,8 @@ func (c *Console) drawDetails(g *gocui.Gui) error {
 			v.Title = "Stats"
 		case trackers:
 			v.Title = "Trackers"
+		case trackerHistory:
+			v.Title = "Tracker History"
 		case peers:
 			v.Title = "Peers"
 		case webseeds:
@@ -570,12 +633,12 @@ func (c *Console) drawDetails(g *gocui.Gui) error {
 					if t.ErrorUnknown {
 						errStr = errStr + " (" + t.ErrorInternal + ")"
 					}
-					fmt.Fprintf(v, "    Status: %s, Error: %s\n", t.Status, errStr)
+					fmt.Fprintf(v, "    Status: %s, Health: %d, Error: %s\n", t.Status, t.Health, errStr)
 				default:
 					if t.Warning != "" {
-						fmt.Fprintf(v, "    Status: %s, Seeders: %d, Leechers: %d Warning: %s\n", t.Status, t.Seeders, t.Leechers, t.Warning)
+						fmt.Fprintf(v, "    Status: %s, Health: %d, Seeders: %d, Leechers: %d Warning: %s\n", t.Status, t.Health, t.Seeders, t.Leechers, t.Warning)
 					} else {
-						fmt.Fprintf(v, "    Status: %s, Seeders: %d, Leechers: %d\n", t.Status, t.Seeders, t.Leechers)
+						fmt.Fprintf(v, "    Status: %s, Health: %d, Seeders: %d, Leechers: %d\n", t.Status, t.Health, t.Seeders, t.Leechers)
 					}
 				}
 				var nextAnnounce string
@@ -585,6 +648,22 @@ func (c *Console) drawDetails(g *gocui.Gui) error {
 					nextAnnounce = t.NextAnnounce.Time.Format(time.RFC3339)
 				}
 				fmt.Fprintf(v, "    Last announce: %s, Next announce: %s\n", t.LastAnnounce.Time.Format(time.RFC3339), nextAnnounce)
+				if !t.LastScrape.IsZero() {
+					if t.ScrapeError != "" {
+						fmt.Fprintf(v, "    Last scrape: %s, Error: %s\n", t.LastScrape.Time.Format(time.RFC3339), t.ScrapeError)
+					} else {
+						fmt.Fprintf(v, "    Last scrape: %s, Completed: %d\n", t.LastScrape.Time.Format(time.RFC3339), t.Completed)
+					}
+				}
+			}
+		case trackerHistory:
+			format := "%8s %9s %5s %7s %s\n"
+			fmt.Fprintf(v, format, "Time", "Event", "Peers", "Latency", "URL")
+			for _, a := range c.history {
+				fmt.Fprintf(v, format, a.Time.Time.Format("15:04:05"), a.Event, fmt.Sprintf("%d", a.Peers), fmt.Sprintf("%dms", a.Latency), a.URL)
+				if a.Error != "" {
+					fmt.Fprintf(v, "    Error: %s (%s)\n", a.Error, a.ErrorType)
+				}
 			}
 		case peers:
 			format := "%2s %21s %7s %8s %6s %s\n"
@@ -746,6 +825,14 @@ func (c *Console) updateDetails(g *gocui.Gui) {
 		c.trackers = trackers
 		c.errDetails = err
 		c.m.Unlock()
+	case trackerHistory:
+		history, err := c.client.GetTorrentTrackerHistory(selectedID)
+		// Newest first
+		sort.Slice(history, func(i, j int) bool { return history[i].Time.Time.After(history[j].Time.Time) })
+		c.m.Lock()
+		c.history = history
+		c.errDetails = err
+		c.m.Unlock()
 	case peers:
 		peers, err := c.client.GetTorrentPeers(selectedID)
 		sort.Slice(peers, func(i, j int) bool {
@@ -836,6 +923,31 @@ func (c *Console) addTorrentHandleEnter(g *gocui.Gui, v *gocui.View) error {
 	return nil
 }
 
+func (c *Console) speedLimitsHandleEnter(g *gocui.Gui, v *gocui.View) error {
+	handleError := func(err error) error {
+		v.Clear()
+		_ = v.SetCursor(0, 0)
+		fmt.Fprintln(v, "error:", err)
+		return nil
+	}
+	var download, upload int64
+	_, err := fmt.Sscan(v.Buffer(), &download, &upload)
+	if err != nil {
+		return handleError(errors.New("enter download and upload limits separated by space"))
+	}
+	if c.speedLimitsID == "" {
+		err = c.client.SetSpeedLimits(download, upload)
+	} else {
+		err = c.client.SetTorrentSpeedLimits(c.speedLimitsID, download, upload)
+	}
+	if err != nil {
+		return handleError(err)
+	}
+	c.selectedPage = torrents
+	c.triggerUpdateDetails(false)
+	return nil
+}
+
 func (c *Console) switchRow(v *gocui.View, row int) error {
 	switch {
 	case len(c.torrents) == 0:
@@ -1002,6 +1114,14 @@ func (c *Console) verify(g *gocui.Gui, v *gocui.View) error {
 	return nil
 }
 
+func (c *Console) toggleTurtleMode(g *gocui.Gui, v *gocui.View) error {
+	s, err := c.client.GetSessionStats()
+	if err != nil {
+		return err
+	}
+	return c.client.SetTurtleMode(!s.TurtleMode)
+}
+
 func (c *Console) tabAdjustDown(g *gocui.Gui, v *gocui.View) error {
 	_, maxY := g.Size()
 	halfY := maxY / 2
@@ -1044,6 +1164,14 @@ func (c *Console) switchTrackers(g *gocui.Gui, v *gocui.View) error {
 	return nil
 }
 
+func (c *Console) switchTrackerHistory(g *gocui.Gui, v *gocui.View) error {
+	c.m.Lock()
+	c.selectedTab = trackerHistory
+	c.m.Unlock()
+	c.triggerUpdateDetails(true)
+	return nil
+}
+
 func (c *Console) switchPeers(g *gocui.Gui, v *gocui.View) error {
 	c.m.Lock()
 	c.selectedTab = peers
@@ -1075,6 +1203,24 @@ func (c *Console) switchAddTorrent(g *gocui.Gui, v *gocui.View) error {
 	return nil
 }
 
+func (c *Console) switchTorrentSpeedLimits(g *gocui.Gui, v *gocui.View) error {
+	c.m.Lock()
+	id := c.selectedID
+	c.m.Unlock()
+	if id == "" {
+		return nil
+	}
+	c.speedLimitsID = id
+	c.selectedPage = speedLimits
+	return nil
+}
+
+func (c *Console) switchSessionSpeedLimits(g *gocui.Gui, v *gocui.View) error {
+	c.speedLimitsID = ""
+	c.selectedPage = speedLimits
+	return nil
+}
+
 func (c *Console) triggerUpdateDetails(clear bool) {
 	if clear {
 		c.updatingDetails = true
@@ -1140,6 +1286,8 @@ func flags(p rpctypes.Peer) string {
 		sb.WriteString("I")
 	case "MANUAL":
 		sb.WriteString("M")
+	case "HOLEPUNCH":
+		sb.WriteString("P")
 	default:
 		sb.WriteString(" ")
 	}
@@ -1198,6 +1346,13 @@ func getUploadSpeed(stats *rpctypes.Stats) string {
 	return fmt.Sprintf("%d KiB/s", stats.Speed.Upload/1024)
 }
 
+func formatSpeedLimit(limit int64) string {
+	if limit == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%d KiB/s", limit)
+}
+
 func getETA(stats *rpctypes.Stats) string {
 	var eta string
 	if stats.ETA != -1 {
@@ -1221,6 +1376,8 @@ func FormatStats(stats *rpctypes.Stats, v io.Writer) {
 	fmt.Fprintf(v, "Peers: %d in / %d out\n", stats.Peers.Incoming, stats.Peers.Outgoing)
 	fmt.Fprintf(v, "Download speed: %11s\n", getDownloadSpeed(stats))
 	fmt.Fprintf(v, "Upload speed:   %11s\n", getUploadSpeed(stats))
+	fmt.Fprintf(v, "Speed limits: %s down / %s up\n", formatSpeedLimit(stats.SpeedLimit.Download), formatSpeedLimit(stats.SpeedLimit.Upload))
+	fmt.Fprintf(v, "Bandwidth priority: %d\n", stats.BandwidthPriority)
 	fmt.Fprintf(v, "ETA: %s\n", getETA(stats))
 }
 
@@ -1233,6 +1390,20 @@ func FormatSessionStats(s *rpctypes.SessionStats, v io.Writer) {
 	fmt.Fprintf(v, "ReadCache Objects: %d, Size: %dMB, Utilization: %d%%\n", s.ReadCacheObjects, s.ReadCacheSize/(1<<20), s.ReadCacheUtilization)
 	fmt.Fprintf(v, "WriteCache Objects: %d, Size: %dMB, PendingKeys: %d\n", s.WriteCacheObjects, s.WriteCacheSize/(1<<20), s.WriteCachePendingKeys)
 	fmt.Fprintf(v, "DownloadSpeed: %dKB/s, UploadSpeed: %dKB/s\n", s.SpeedDownload/1024, s.SpeedUpload/1024)
+	fmt.Fprintf(v, "SpeedLimits: %s down / %s up, Profile: %s\n", formatSpeedLimit(s.SpeedLimitDownload), formatSpeedLimit(s.SpeedLimitUpload), s.SpeedLimitProfile)
 	fmt.Fprintf(v, "BytesDownloaded: %dMB, BytesUploaded: %dMB\n", s.BytesDownloaded/1024/1024, s.BytesUploaded/1024/1024)
 	fmt.Fprintf(v, "BytesRead: %dMB, BytesWritten: %dMB\n", s.BytesRead/1024/1024, s.BytesWritten/1024/1024)
+	fmt.Fprintf(v, "Today: %dMB down, %dMB up, Quota: %s\n", s.BytesDownloadedToday>>20, s.BytesUploadedToday>>20, formatQuota(s.DailyTrafficQuota))
+	fmt.Fprintf(v, "ThisMonth: %dMB down, %dMB up, Quota: %s\n", s.BytesDownloadedThisMonth>>20, s.BytesUploadedThisMonth>>20, formatQuota(s.MonthlyTrafficQuota))
+	if s.UploadsStoppedByQuota || s.DownloadsStoppedByQuota {
+		fmt.Fprintf(v, "Quota exceeded, UploadsStopped: %t, DownloadsStopped: %t\n", s.UploadsStoppedByQuota, s.DownloadsStoppedByQuota)
+	}
+	fmt.Fprintf(v, "DHT: %d nodes (%d good, %d bad), Queries: %d sent, %d received, PeersFound: %d\n", s.DHTNodes, s.DHTGoodNodes, s.DHTBadNodes, s.DHTQueriesSent, s.DHTQueriesReceived, s.DHTPeersFound)
+}
+
+func formatQuota(quota int64) string {
+	if quota == 0 {
+		return "unlimited"
+	}
+	return fmt.Sprintf("%dMB", quota>>20)
 }
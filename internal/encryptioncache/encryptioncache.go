@@ -0,0 +1,112 @@
+// Package encryptioncache remembers which handshake type was accepted by peer addresses,
+// so the next connection to the same address can be made with the known-good handshake on the first dial.
+package encryptioncache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// Result of an outgoing handshake.
+type Result int
+
+// Handshake results that are stored in Cache.
+const (
+	// Unknown means no handshake has been completed with the address recently.
+	Unknown Result = iota
+	// Encrypted means the peer has accepted the encryption handshake.
+	Encrypted
+	// PlainText means the encryption handshake has failed but the peer has accepted the plaintext BitTorrent handshake.
+	PlainText
+)
+
+// Cache is a size-bounded LRU cache of handshake results keyed by peer address.
+// Results are forgotten after ttl so peers that change their settings are probed again.
+type Cache struct {
+	size int
+	ttl  time.Duration
+
+	m     sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List
+}
+
+type item struct {
+	addr      string
+	result    Result
+	expiresAt time.Time
+}
+
+// New returns a new Cache that holds at most size items.
+func New(size int, ttl time.Duration) *Cache {
+	return &Cache{
+		size:  size,
+		ttl:   ttl,
+		items: make(map[string]*list.Element),
+		lru:   list.New(),
+	}
+}
+
+// Get returns the last handshake result for the address.
+func (c *Cache) Get(addr string) Result {
+	c.m.Lock()
+	defer c.m.Unlock()
+	e, ok := c.items[addr]
+	if !ok {
+		return Unknown
+	}
+	it := e.Value.(*item)
+	if time.Now().After(it.expiresAt) {
+		c.remove(e)
+		return Unknown
+	}
+	c.lru.MoveToFront(e)
+	return it.result
+}
+
+// Set the handshake result for the address.
+func (c *Cache) Set(addr string, result Result) {
+	if c.size <= 0 {
+		return
+	}
+	if result == Unknown {
+		c.Remove(addr)
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	expiresAt := time.Now().Add(c.ttl)
+	if e, ok := c.items[addr]; ok {
+		it := e.Value.(*item)
+		it.result = result
+		it.expiresAt = expiresAt
+		c.lru.MoveToFront(e)
+		return
+	}
+	c.items[addr] = c.lru.PushFront(&item{addr: addr, result: result, expiresAt: expiresAt})
+	for c.lru.Len() > c.size {
+		c.remove(c.lru.Back())
+	}
+}
+
+// Remove the result for the address.
+func (c *Cache) Remove(addr string) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if e, ok := c.items[addr]; ok {
+		c.remove(e)
+	}
+}
+
+// Len returns the number of addresses in the Cache.
+func (c *Cache) Len() int {
+	c.m.Lock()
+	defer c.m.Unlock()
+	return c.lru.Len()
+}
+
+func (c *Cache) remove(e *list.Element) {
+	c.lru.Remove(e)
+	delete(c.items, e.Value.(*item).addr)
+}
@@ -0,0 +1,40 @@
+package encryptioncache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCache(t *testing.T) {
+	c := New(2, time.Hour)
+	assert.Equal(t, Unknown, c.Get("1.2.3.4:5000"))
+
+	c.Set("1.2.3.4:5000", PlainText)
+	c.Set("1.2.3.5:5000", Encrypted)
+	assert.Equal(t, PlainText, c.Get("1.2.3.4:5000"))
+	assert.Equal(t, Encrypted, c.Get("1.2.3.5:5000"))
+
+	// Least recently used address is evicted.
+	c.Get("1.2.3.4:5000")
+	c.Set("1.2.3.6:5000", Encrypted)
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, Unknown, c.Get("1.2.3.5:5000"))
+	assert.Equal(t, PlainText, c.Get("1.2.3.4:5000"))
+
+	c.Set("1.2.3.4:5000", Encrypted)
+	assert.Equal(t, Encrypted, c.Get("1.2.3.4:5000"))
+
+	c.Remove("1.2.3.4:5000")
+	assert.Equal(t, Unknown, c.Get("1.2.3.4:5000"))
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestExpire(t *testing.T) {
+	c := New(10, time.Millisecond)
+	c.Set("1.2.3.4:5000", PlainText)
+	time.Sleep(10 * time.Millisecond)
+	assert.Equal(t, Unknown, c.Get("1.2.3.4:5000"))
+	assert.Equal(t, 0, c.Len())
+}
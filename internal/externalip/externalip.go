@@ -2,12 +2,19 @@ package externalip
 
 import (
 	"net"
+	"sync"
 
 	"github.com/cenkalti/log"
 )
 
 var ips []net.IP
 
+// External IP of the gateway that is discovered by port mapping.
+var (
+	mMapped sync.RWMutex
+	mapped  net.IP
+)
+
 func init() {
 	addrs, err := net.InterfaceAddrs()
 	if err != nil {
@@ -46,20 +53,38 @@ func isPublicIP(ip4 net.IP) bool {
 	}
 }
 
-// IsExternal returns true if the given IP matches one of the IP address of the external network interfaces on the server.
+// SetMapped sets the external IP address of the gateway that the server is behind.
+func SetMapped(ip net.IP) {
+	mMapped.Lock()
+	mapped = ip.To4()
+	mMapped.Unlock()
+}
+
+func getMapped() net.IP {
+	mMapped.RLock()
+	defer mMapped.RUnlock()
+	return mapped
+}
+
+// IsExternal returns true if the given IP matches one of the IP address of the external network interfaces on the server
+// or the external IP address of the gateway.
 func IsExternal(ip net.IP) bool {
 	for i := range ips {
 		if ip.Equal(ips[i]) {
 			return true
 		}
 	}
+	if m := getMapped(); m != nil && ip.Equal(m) {
+		return true
+	}
 	return false
 }
 
 // FirstExternalIP returns the first external IP of the network interfaces on the server.
+// If the server does not have a public IP, the external IP of the gateway is returned if it is known.
 func FirstExternalIP() net.IP {
 	if len(ips) == 0 {
-		return nil
+		return getMapped()
 	}
 	return ips[0]
 }
@@ -21,20 +21,38 @@ type OutgoingHandshaker struct {
 	Cipher     mse.CryptoMethod
 	Error      error
 
+	dialer btconn.Dialer
+	// Connection is made with TCP simultaneous open.
+	holepunch bool
+
 	closeC chan struct{}
 	doneC  chan struct{}
 }
 
 // New returns a new OutgoingHandshaker for a TCP address.
-func New(addr *net.TCPAddr, source peersource.Source) *OutgoingHandshaker {
+// Connection is opened with the dialer. If dialer is nil, a default net.Dialer is used.
+func New(addr *net.TCPAddr, source peersource.Source, dialer btconn.Dialer) *OutgoingHandshaker {
 	return &OutgoingHandshaker{
 		Addr:   addr,
 		Source: source,
+		dialer: dialer,
 		closeC: make(chan struct{}),
 		doneC:  make(chan struct{}),
 	}
 }
 
+// NewHolepunch returns a new OutgoingHandshaker that dials the address from the local address at the same time
+// the peer dials us (BEP 55). Encryption is not used because both sides start the handshake as the initiator.
+func NewHolepunch(addr, localAddr *net.TCPAddr) *OutgoingHandshaker {
+	var dialer btconn.Dialer
+	if localAddr != nil {
+		dialer = btconn.HolepunchDialer(localAddr)
+	}
+	h := New(addr, peersource.Holepunch, dialer)
+	h.holepunch = true
+	return h
+}
+
 // Close the handshaker.
 func (h *OutgoingHandshaker) Close() {
 	close(h.closeC)
@@ -46,7 +64,8 @@ func (h *OutgoingHandshaker) Run(dialTimeout, handshakeTimeout time.Duration, pe
 	defer close(h.doneC)
 	log := logger.New("peer -> " + h.Addr.String())
 
-	conn, cipher, peerExtensions, peerID, err := btconn.Dial(h.Addr, dialTimeout, handshakeTimeout, !disableOutgoingEncryption, forceOutgoingEncryption, ourExtensions, infoHash, peerID, h.closeC)
+	enableEncryption := !disableOutgoingEncryption && !h.holepunch
+	conn, cipher, peerExtensions, peerID, err := btconn.Dial(h.Addr, h.dialer, dialTimeout, handshakeTimeout, enableEncryption, forceOutgoingEncryption, ourExtensions, infoHash, peerID, h.closeC)
 	if err != nil {
 		if err == io.EOF {
 			log.Debug("peer has closed the connection: EOF")
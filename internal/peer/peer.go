@@ -18,8 +18,8 @@ import (
 	"github.com/cenkalti/rain/internal/pexlist"
 	"github.com/cenkalti/rain/internal/piece"
 	"github.com/cenkalti/rain/internal/sliceset"
+	"github.com/cenkalti/rain/internal/speedlimit"
 	"github.com/cenkalti/rain/internal/stringutil"
-	"github.com/juju/ratelimit"
 	"github.com/rcrowley/go-metrics"
 )
 
@@ -61,6 +61,9 @@ type Peer struct {
 
 	ExtensionHandshake *peerprotocol.ExtensionHandshakeMessage
 
+	// UploadOnly is true if the peer has announced that it is not going to download any pieces (BEP 21).
+	UploadOnly bool
+
 	PEX *pex
 
 	snubTimeout time.Duration
@@ -87,7 +90,7 @@ type PieceMessage struct {
 }
 
 // New wraps the net.Conn and returns a new Peer.
-func New(conn net.Conn, source peersource.Source, id [20]byte, extensions [8]byte, cipher mse.CryptoMethod, pieceReadTimeout, snubTimeout time.Duration, maxRequestsIn int, br, bw *ratelimit.Bucket) *Peer {
+func New(conn net.Conn, source peersource.Source, id [20]byte, extensions [8]byte, cipher mse.CryptoMethod, pieceReadTimeout, snubTimeout time.Duration, maxRequestsIn int, br, bw *speedlimit.Limiter) *Peer {
 	bf, _ := bitfield.NewBytes(extensions[:], 64)
 	fastEnabled := bf.Test(61)
 	extensionsEnabled := bf.Test(43)
@@ -219,6 +222,14 @@ func (p *Peer) UploadSpeed() int {
 	return int(p.uploadSpeed.Rate1())
 }
 
+// Completion returns the ratio of pieces that the remote Peer has. Returns zero if the torrent metadata is not known yet.
+func (p *Peer) Completion() float64 {
+	if p.Bitfield == nil || p.Bitfield.Len() == 0 {
+		return 0
+	}
+	return float64(p.Bitfield.Count()) / float64(p.Bitfield.Len())
+}
+
 // Choke the connected Peer by sending a "choke" protocol message.
 func (p *Peer) Choke() {
 	p.ClientChoking = true
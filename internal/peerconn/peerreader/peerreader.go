@@ -13,7 +13,7 @@ import (
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/peerprotocol"
 	"github.com/cenkalti/rain/internal/piece"
-	"github.com/juju/ratelimit"
+	"github.com/cenkalti/rain/internal/speedlimit"
 )
 
 const (
@@ -33,14 +33,14 @@ type PeerReader struct {
 	r            io.Reader
 	log          logger.Logger
 	pieceTimeout time.Duration
-	bucket       *ratelimit.Bucket
+	bucket       *speedlimit.Limiter
 	messages     chan any
 	stopC        chan struct{}
 	doneC        chan struct{}
 }
 
 // New returns a new PeerReader by wrapping a net.Conn.
-func New(conn net.Conn, l logger.Logger, pieceTimeout time.Duration, b *ratelimit.Bucket) *PeerReader {
+func New(conn net.Conn, l logger.Logger, pieceTimeout time.Duration, b *speedlimit.Limiter) *PeerReader {
 	return &PeerReader{
 		conn:         conn,
 		r:            bufio.NewReaderSize(conn, readBufferSize),
@@ -5,3 +5,10 @@ package peerwriter
 type BlockUploaded struct {
 	Length uint32
 }
+
+// PieceReadError is used to signal the Torrent when the data of a piece cannot be read for uploading to the peer.
+// The torrent must consider the piece as lost and download it again.
+type PieceReadError struct {
+	Index uint32
+	Error error
+}
@@ -11,7 +11,7 @@ import (
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/peerconn/peerreader"
 	"github.com/cenkalti/rain/internal/peerprotocol"
-	"github.com/juju/ratelimit"
+	"github.com/cenkalti/rain/internal/speedlimit"
 )
 
 const keepAlivePeriod = 2 * time.Minute
@@ -28,14 +28,14 @@ type PeerWriter struct {
 	writeC                chan peerprotocol.Message
 	messages              chan any
 	servedRequests        map[peerprotocol.RequestMessage]struct{}
-	bucket                *ratelimit.Bucket
+	bucket                *speedlimit.Limiter
 	log                   logger.Logger
 	stopC                 chan struct{}
 	doneC                 chan struct{}
 }
 
 // New returns a new PeerWriter by wrapping a net.Conn.
-func New(conn net.Conn, l logger.Logger, maxQueuedRequests int, fastEnabled bool, b *ratelimit.Bucket) *PeerWriter {
+func New(conn net.Conn, l logger.Logger, maxQueuedRequests int, fastEnabled bool, b *speedlimit.Limiter) *PeerWriter {
 	return &PeerWriter{
 		conn:              conn,
 		queueC:            make(chan peerprotocol.Message),
@@ -218,6 +218,26 @@ func (p *PeerWriter) messageWriter() {
 			} else {
 				m, err = buf.ReadFrom(msg)
 			}
+			if pi, ok := msg.(Piece); ok && err != nil {
+				select {
+				case <-p.stopC:
+					return
+				default:
+				}
+				p.log.Errorf("cannot read piece #%d: %s", pi.Index, err.Error())
+				select {
+				case p.messages <- PieceReadError{Index: pi.Index, Error: err}:
+				case <-p.stopC:
+					return
+				}
+				if !p.fastEnabled {
+					continue
+				}
+				msg = peerprotocol.RejectMessage{RequestMessage: pi.RequestMessage}
+				buf = bytes.NewBuffer(b)
+				buf.Write([]byte{0, 0, 0, 0, 0})
+				m, err = buf.ReadFrom(msg)
+			}
 			if err != nil {
 				select {
 				case <-p.stopC:
@@ -22,6 +22,10 @@ func (p Piece) Read(b []byte) (int, error) {
 	binary.BigEndian.PutUint32(b[4:8], p.Begin)
 	n, err := p.Data.ReadAt(b[8:8+p.Length], int64(p.Begin))
 	m := n + 8
+	if err == io.EOF {
+		// Nothing could be read from the files. Must not be reported as a successful read.
+		err = io.ErrUnexpectedEOF
+	}
 	if err != nil {
 		return m, err
 	}
@@ -2,6 +2,7 @@ package peerprotocol
 
 import (
 	"bytes"
+	"encoding"
 	"encoding/binary"
 	"fmt"
 	"io"
@@ -17,13 +18,25 @@ const (
 	ExtensionIDMetadata
 	// ExtensionIDPEX is ID for PEX extension messages.
 	ExtensionIDPEX
+	// ExtensionIDHolepunch is ID for Holepunch extension messages.
+	ExtensionIDHolepunch
+	// ExtensionIDDontHave is ID for DontHave extension messages.
+	ExtensionIDDontHave
 )
 
+// ExtensionIDCustomBegin is the first ID that is assigned to extensions registered by the users of the library.
+// Messages with IDs lower than this value are parsed by this package.
+const ExtensionIDCustomBegin = 64
+
 const (
 	// ExtensionKeyMetadata is the key for the metadata extension.
 	ExtensionKeyMetadata = "ut_metadata"
 	// ExtensionKeyPEX is the key for the PEX extension.
 	ExtensionKeyPEX = "ut_pex"
+	// ExtensionKeyHolepunch is the key for the Holepunch extension.
+	ExtensionKeyHolepunch = "ut_holepunch"
+	// ExtensionKeyDontHave is the key for the DontHave extension.
+	ExtensionKeyDontHave = "lt_donthave"
 )
 
 const (
@@ -35,6 +48,26 @@ const (
 	ExtensionMetadataMessageTypeReject
 )
 
+const (
+	// ExtensionHolepunchMessageTypeRendezvous is sent to the relaying peer for connecting to the target peer.
+	ExtensionHolepunchMessageTypeRendezvous = iota
+	// ExtensionHolepunchMessageTypeConnect is sent by the relaying peer to both sides for initiating a simultaneous connect.
+	ExtensionHolepunchMessageTypeConnect
+	// ExtensionHolepunchMessageTypeError is sent by the relaying peer if it cannot relay the rendezvous message.
+	ExtensionHolepunchMessageTypeError
+)
+
+const (
+	// HolepunchErrNoSuchPeer means that the target endpoint is invalid.
+	HolepunchErrNoSuchPeer = iota + 1
+	// HolepunchErrNotConnected means that the relaying peer is not connected to the target peer.
+	HolepunchErrNotConnected
+	// HolepunchErrNoSupport means that the target peer does not support the holepunch extension.
+	HolepunchErrNoSupport
+	// HolepunchErrNoSelf means that the target endpoint belongs to the peer sending the rendezvous message.
+	HolepunchErrNoSelf
+)
+
 // ExtensionMessage is extension to BitTorrent protocol.
 type ExtensionMessage struct {
 	ExtendedMessageID uint8
@@ -56,6 +89,21 @@ func (m ExtensionMessage) WriteTo(w io.Writer) (n int64, err error) {
 	if err != nil {
 		return
 	}
+	if rm, ok := m.Payload.(ExtensionRawMessage); ok {
+		nn, err = w.Write(rm.Data)
+		n += int64(nn)
+		return
+	}
+	if bm, ok := m.Payload.(encoding.BinaryMarshaler); ok {
+		var b []byte
+		b, err = bm.MarshalBinary()
+		if err != nil {
+			return
+		}
+		nn, err = w.Write(b)
+		n += int64(nn)
+		return
+	}
 	wc := newWriterCounter(w)
 	err = bencode.NewEncoder(wc).Encode(m.Payload)
 	n += wc.Count()
@@ -84,13 +132,17 @@ func (m *ExtensionMessage) UnmarshalBinary(data []byte) error {
 	case ExtensionIDHandshake:
 		var extMsg ExtensionHandshakeMessage
 		err = dec.Decode(&extMsg)
-		m.Payload = extMsg
+		if err != nil {
+			return err
+		}
 		if extMsg.MetadataSize < 0 {
 			extMsg.MetadataSize = 0
 		}
 		if extMsg.RequestQueue < 0 {
 			extMsg.RequestQueue = 0
 		}
+		err = bencode.DecodeBytes(payload, &extMsg.Raw)
+		m.Payload = extMsg
 	case ExtensionIDMetadata:
 		var extMsg ExtensionMetadataMessage
 		err = dec.Decode(&extMsg)
@@ -100,8 +152,19 @@ func (m *ExtensionMessage) UnmarshalBinary(data []byte) error {
 		var extMsg ExtensionPEXMessage
 		err = dec.Decode(&extMsg)
 		m.Payload = extMsg
+	case ExtensionIDHolepunch:
+		var extMsg ExtensionHolepunchMessage
+		err = extMsg.UnmarshalBinary(payload)
+		m.Payload = extMsg
+	case ExtensionIDDontHave:
+		var extMsg ExtensionDontHaveMessage
+		err = extMsg.UnmarshalBinary(payload)
+		m.Payload = extMsg
 	default:
-		return fmt.Errorf("peer sent invalid extension message id: %d", m.ExtendedMessageID)
+		if m.ExtendedMessageID < ExtensionIDCustomBegin {
+			return fmt.Errorf("peer sent invalid extension message id: %d", m.ExtendedMessageID)
+		}
+		m.Payload = ExtensionRawMessage{ExtendedMessageID: m.ExtendedMessageID, Data: payload}
 	}
 	return err
 }
@@ -113,14 +176,48 @@ type ExtensionHandshakeMessage struct {
 	YourIP       string           `bencode:"yourip,omitempty"`
 	MetadataSize int              `bencode:"metadata_size,omitempty"`
 	RequestQueue int              `bencode:"reqq"`
+	// IPv4 is the external address of the sender.
+	IPv4 string `bencode:"ipv4,omitempty"`
+	// UploadOnly is set to 1 if the peer is not going to download any more pieces (BEP 21).
+	UploadOnly int `bencode:"upload_only,omitempty"`
+	// ListenPort is the port that the sender accepts incoming connections on.
+	ListenPort int `bencode:"p,omitempty"`
+
+	// Extra keys to be sent along with the known fields above.
+	// Keys that conflict with the known fields are ignored.
+	Extra map[string]any `bencode:"-"`
+	// Raw contains all of the keys in a received handshake message.
+	Raw map[string]bencode.RawMessage `bencode:"-"`
+}
+
+// MarshalBencode encodes the handshake message by merging the keys in Extra field.
+func (m ExtensionHandshakeMessage) MarshalBencode() ([]byte, error) {
+	type handshake ExtensionHandshakeMessage
+	b, err := bencode.EncodeBytes(handshake(m))
+	if err != nil || len(m.Extra) == 0 {
+		return b, err
+	}
+	var d map[string]any
+	err = bencode.DecodeBytes(b, &d)
+	if err != nil {
+		return nil, err
+	}
+	for k, v := range m.Extra {
+		if _, ok := d[k]; !ok {
+			d[k] = v
+		}
+	}
+	return bencode.EncodeBytes(d)
 }
 
 // NewExtensionHandshake returns a new ExtensionHandshakeMessage by filling the struct with given values.
 func NewExtensionHandshake(metadataSize uint32, version string, yourip net.IP, requestQueueLength int) ExtensionHandshakeMessage {
 	return ExtensionHandshakeMessage{
 		M: map[string]uint8{
-			ExtensionKeyMetadata: ExtensionIDMetadata,
-			ExtensionKeyPEX:      ExtensionIDPEX,
+			ExtensionKeyMetadata:  ExtensionIDMetadata,
+			ExtensionKeyPEX:       ExtensionIDPEX,
+			ExtensionKeyHolepunch: ExtensionIDHolepunch,
+			ExtensionKeyDontHave:  ExtensionIDDontHave,
 		},
 		V:            version,
 		YourIP:       string(truncateIP(yourip)),
@@ -137,12 +234,98 @@ type ExtensionMetadataMessage struct {
 	Data      []byte `bencode:"-"`
 }
 
+// ExtensionRawMessage is a message of an extension that is not known by this package.
+// Data contains the payload of the message as it is sent by the peer.
+type ExtensionRawMessage struct {
+	ExtendedMessageID uint8
+	Data              []byte
+}
+
 // ExtensionPEXMessage is the message for the PEX extension.
 type ExtensionPEXMessage struct {
 	Added   string `bencode:"added"`
 	Dropped string `bencode:"dropped"`
 }
 
+// ExtensionHolepunchMessage is the message for the Holepunch extension.
+// Unlike the other extension messages, it is encoded in binary format instead of bencode.
+type ExtensionHolepunchMessage struct {
+	Type  uint8
+	Addr  *net.TCPAddr
+	Error uint32
+}
+
+// MarshalBinary encodes the message as described in BEP 55.
+func (m ExtensionHolepunchMessage) MarshalBinary() ([]byte, error) {
+	var addrType uint8
+	ip := m.Addr.IP.To4()
+	if ip == nil {
+		addrType = 1
+		ip = m.Addr.IP.To16()
+		if ip == nil {
+			return nil, fmt.Errorf("invalid holepunch ip: %s", m.Addr.IP)
+		}
+	}
+	b := make([]byte, 2+len(ip)+2+4)
+	b[0] = m.Type
+	b[1] = addrType
+	copy(b[2:], ip)
+	binary.BigEndian.PutUint16(b[2+len(ip):], uint16(m.Addr.Port))
+	binary.BigEndian.PutUint32(b[2+len(ip)+2:], m.Error)
+	return b, nil
+}
+
+// UnmarshalBinary decodes the message as described in BEP 55.
+func (m *ExtensionHolepunchMessage) UnmarshalBinary(data []byte) error {
+	if len(data) < 2 {
+		return io.ErrUnexpectedEOF
+	}
+	m.Type = data[0]
+	var ipLen int
+	switch data[1] {
+	case 0:
+		ipLen = net.IPv4len
+	case 1:
+		ipLen = net.IPv6len
+	default:
+		return fmt.Errorf("invalid holepunch address type: %d", data[1])
+	}
+	data = data[2:]
+	if len(data) < ipLen+2+4 {
+		return io.ErrUnexpectedEOF
+	}
+	ip := make(net.IP, ipLen)
+	copy(ip, data)
+	m.Addr = &net.TCPAddr{
+		IP:   ip,
+		Port: int(binary.BigEndian.Uint16(data[ipLen:])),
+	}
+	m.Error = binary.BigEndian.Uint32(data[ipLen+2:])
+	return nil
+}
+
+// ExtensionDontHaveMessage is the message for the DontHave extension.
+// It is sent when a piece that is announced before becomes unavailable.
+type ExtensionDontHaveMessage struct {
+	Index uint32
+}
+
+// MarshalBinary encodes the message as described in BEP 54.
+func (m ExtensionDontHaveMessage) MarshalBinary() ([]byte, error) {
+	b := make([]byte, 4)
+	binary.BigEndian.PutUint32(b, m.Index)
+	return b, nil
+}
+
+// UnmarshalBinary decodes the message as described in BEP 54.
+func (m *ExtensionDontHaveMessage) UnmarshalBinary(data []byte) error {
+	if len(data) != 4 {
+		return fmt.Errorf("invalid donthave message length: %d", len(data))
+	}
+	m.Index = binary.BigEndian.Uint32(data)
+	return nil
+}
+
 func truncateIP(ip net.IP) net.IP {
 	ip4 := ip.To4()
 	if ip4 != nil {
@@ -0,0 +1,77 @@
+package peerprotocol
+
+import (
+	"bytes"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHolepunchMessage(t *testing.T) {
+	cases := []struct {
+		msg     ExtensionHolepunchMessage
+		encoded []byte
+	}{
+		{
+			msg: ExtensionHolepunchMessage{
+				Type: ExtensionHolepunchMessageTypeRendezvous,
+				Addr: &net.TCPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 6881},
+			},
+			encoded: []byte{0, 0, 1, 2, 3, 4, 0x1a, 0xe1, 0, 0, 0, 0},
+		},
+		{
+			msg: ExtensionHolepunchMessage{
+				Type:  ExtensionHolepunchMessageTypeError,
+				Addr:  &net.TCPAddr{IP: net.ParseIP("2001:db8::1"), Port: 80},
+				Error: HolepunchErrNotConnected,
+			},
+			encoded: []byte{2, 1, 0x20, 0x01, 0x0d, 0xb8, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 1, 0, 80, 0, 0, 0, 2},
+		},
+	}
+	for _, c := range cases {
+		b, err := c.msg.MarshalBinary()
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, c.encoded, b)
+
+		var em ExtensionMessage
+		err = em.UnmarshalBinary(append([]byte{ExtensionIDHolepunch}, b...))
+		if err != nil {
+			t.Fatal(err)
+		}
+		m := em.Payload.(ExtensionHolepunchMessage)
+		assert.Equal(t, c.msg.Type, m.Type)
+		assert.Equal(t, c.msg.Error, m.Error)
+		assert.Equal(t, c.msg.Addr.String(), m.Addr.String())
+	}
+}
+
+func TestHolepunchMessageInvalid(t *testing.T) {
+	for _, b := range [][]byte{
+		{},
+		{0, 0, 1, 2, 3, 4, 0x1a, 0xe1, 0, 0, 0},
+		{0, 2, 1, 2, 3, 4, 0x1a, 0xe1, 0, 0, 0, 0},
+	} {
+		var m ExtensionHolepunchMessage
+		assert.Error(t, m.UnmarshalBinary(b))
+	}
+}
+
+func TestHolepunchMessageWrite(t *testing.T) {
+	msg := ExtensionMessage{
+		ExtendedMessageID: 5,
+		Payload: ExtensionHolepunchMessage{
+			Type: ExtensionHolepunchMessageTypeConnect,
+			Addr: &net.TCPAddr{IP: net.IPv4(1, 2, 3, 4), Port: 6881},
+		},
+	}
+	var buf bytes.Buffer
+	n, err := msg.WriteTo(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(13), n)
+	assert.Equal(t, []byte{5, 1, 0, 1, 2, 3, 4, 0x1a, 0xe1, 0, 0, 0, 0}, buf.Bytes())
+}
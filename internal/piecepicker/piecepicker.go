@@ -152,6 +152,12 @@ func (p *PiecePicker) HandleHave(pe *peer.Peer, i uint32) {
 	p.addHavingPeer(i, pe)
 }
 
+// HandleDontHave must be called when the peer does not have the piece anymore.
+func (p *PiecePicker) HandleDontHave(pe *peer.Peer, i uint32) {
+	pe.Bitfield.Clear(i)
+	p.removeHavingPeer(int(i), pe)
+}
+
 // HandleAllowedFast must be called to set the allowed-fast status of the piece at peer.
 func (p *PiecePicker) HandleAllowedFast(pe *peer.Peer, i uint32) {
 	pe.ReceivedAllowedFast.Add(p.pieces[i].Piece)
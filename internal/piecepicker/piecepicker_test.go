@@ -69,6 +69,35 @@ func TestPiecePicker(t *testing.T) {
 	assert.True(t, pp.endgame)
 }
 
+func TestPiecePickerDontHave(t *testing.T) {
+	pieces := make([]piece.Piece, numPieces)
+	for i := range pieces {
+		pieces[i] = newPiece(i)
+	}
+	peers := make([]*peer.Peer, numPeers)
+	for i := range peers {
+		peers[i] = newPeer(i)
+	}
+	pp := New(pieces, 2, nil)
+	pp.HandleHave(peers[0], 1)
+	pp.HandleHave(peers[1], 1)
+	pp.HandleHave(peers[1], 2)
+	assert.Equal(t, uint32(2), pp.Available())
+
+	pp.HandleDontHave(peers[1], 1)
+	assert.False(t, peers[1].Bitfield.Test(1))
+	assert.Equal(t, uint32(2), pp.Available())
+	assert.Equal(t, &pieces[2], pp.pickFor(peers[1]))
+
+	pp.HandleDontHave(peers[0], 1)
+	assert.Equal(t, uint32(1), pp.Available())
+	assert.Nil(t, pp.pickFor(peers[0]))
+
+	// Receiving the message twice must not change availability.
+	pp.HandleDontHave(peers[0], 1)
+	assert.Equal(t, uint32(1), pp.Available())
+}
+
 func newPiece(i int) piece.Piece {
 	return piece.Piece{Index: uint32(i)}
 }
@@ -0,0 +1,75 @@
+package portmap
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"net"
+	"os"
+	"strings"
+)
+
+// defaultGateway returns the IPv4 address of the default gateway.
+// The routing table is read from /proc on Linux. On other systems, the first address in the network of
+// a private interface address is assumed to be the gateway, which is the common configuration of home routers.
+func defaultGateway() (net.IP, error) {
+	ip, err := gatewayFromProc("/proc/net/route")
+	if err == nil {
+		return ip, nil
+	}
+	return guessGateway()
+}
+
+func gatewayFromProc(path string) (net.IP, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := bufio.NewScanner(f)
+	s.Scan() // skip header
+	for s.Scan() {
+		// Iface Destination Gateway Flags ...
+		fields := strings.Fields(s.Text())
+		if len(fields) < 3 || fields[1] != "00000000" {
+			continue
+		}
+		b, err := hex.DecodeString(fields[2])
+		if err != nil || len(b) != 4 {
+			continue
+		}
+		// Addresses are in host byte order, which is little endian on all supported platforms.
+		ip := make(net.IP, 4)
+		binary.BigEndian.PutUint32(ip, binary.LittleEndian.Uint32(b))
+		if ip.IsUnspecified() {
+			continue
+		}
+		return ip, nil
+	}
+	if err = s.Err(); err != nil {
+		return nil, err
+	}
+	return nil, errors.New("default route not found")
+}
+
+func guessGateway() (net.IP, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, err
+	}
+	for _, addr := range addrs {
+		in, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip4 := in.IP.To4()
+		if ip4 == nil || !ip4.IsPrivate() {
+			continue
+		}
+		gw := ip4.Mask(in.Mask)
+		gw[3]++
+		return gw, nil
+	}
+	return nil, errors.New("cannot find gateway address")
+}
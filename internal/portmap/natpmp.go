@@ -0,0 +1,319 @@
+package portmap
+
+// https://www.rfc-editor.org/rfc/rfc6886
+// https://www.rfc-editor.org/rfc/rfc6887
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+)
+
+const natpmpPort = 5351
+
+const (
+	natpmpVersion = 0
+	pcpVersion    = 2
+)
+
+const (
+	natpmpOpExternalAddress = 0
+	natpmpOpMapUDP          = 1
+	natpmpOpMapTCP          = 2
+)
+
+const (
+	pcpOpAnnounce = 0
+	pcpOpMap      = 1
+)
+
+const resultUnsupportedVersion = 1
+
+var errUnsupportedVersion = errors.New("unsupported version")
+
+var natpmpResults = []string{
+	"success",
+	"unsupported version",
+	"not authorized",
+	"network failure",
+	"out of resources",
+	"unsupported opcode",
+}
+
+var pcpResults = []string{
+	"success",
+	"unsupported version",
+	"not authorized",
+	"malformed request",
+	"unsupported opcode",
+	"unsupported option",
+	"malformed option",
+	"network failure",
+	"no resources",
+	"unsupported protocol",
+	"user exceeded quota",
+	"cannot provide external",
+	"address mismatch",
+	"excessive remote peers",
+}
+
+// natpmpClient talks to the gateway with PCP if it is supported, otherwise falls back to NAT-PMP.
+type natpmpClient struct {
+	gateway *net.UDPAddr
+	pcp     bool
+
+	// PCP returns the external address in MAP responses only.
+	// NAT-PMP has a separate request for getting the external address.
+	m          sync.Mutex
+	externalIP net.IP
+	// PCP requires the same nonce to be used when renewing or deleting a mapping.
+	nonces map[mappingKey][12]byte
+}
+
+var _ client = (*natpmpClient)(nil)
+
+func discoverNATPMP(ctx context.Context, gateway *net.UDPAddr) (*natpmpClient, error) {
+	c := &natpmpClient{
+		gateway: gateway,
+		nonces:  make(map[mappingKey][12]byte),
+	}
+	err := c.pcpAnnounce(ctx)
+	if err == nil {
+		c.pcp = true
+		return c, nil
+	}
+	if err != errUnsupportedVersion {
+		return nil, err
+	}
+	_, err = c.ExternalIP(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *natpmpClient) Name() string {
+	if c.pcp {
+		return "PCP"
+	}
+	return "NAT-PMP"
+}
+
+func (c *natpmpClient) ExternalIP(ctx context.Context) (net.IP, error) {
+	if c.pcp {
+		c.m.Lock()
+		defer c.m.Unlock()
+		if c.externalIP == nil {
+			return nil, errors.New("external address is not known yet")
+		}
+		return c.externalIP, nil
+	}
+	resp, err := c.natpmpRequest(ctx, []byte{natpmpVersion, natpmpOpExternalAddress}, 12)
+	if err != nil {
+		return nil, err
+	}
+	return net.IP(resp[8:12]), nil
+}
+
+func (c *natpmpClient) AddMapping(ctx context.Context, proto Protocol, port int, lifetime time.Duration) (int, time.Duration, error) {
+	if c.pcp {
+		return c.pcpMap(ctx, proto, port, lifetime)
+	}
+	return c.natpmpMap(ctx, proto, port, port, lifetime)
+}
+
+func (c *natpmpClient) DeleteMapping(ctx context.Context, proto Protocol, port int) error {
+	var err error
+	if c.pcp {
+		_, _, err = c.pcpMap(ctx, proto, port, 0)
+		c.m.Lock()
+		delete(c.nonces, mappingKey{proto, port})
+		c.m.Unlock()
+	} else {
+		_, _, err = c.natpmpMap(ctx, proto, port, 0, 0)
+	}
+	return err
+}
+
+func (c *natpmpClient) natpmpMap(ctx context.Context, proto Protocol, internalPort, externalPort int, lifetime time.Duration) (int, time.Duration, error) {
+	req := make([]byte, 12)
+	req[0] = natpmpVersion
+	req[1] = natpmpOpMapTCP
+	if proto == UDP {
+		req[1] = natpmpOpMapUDP
+	}
+	binary.BigEndian.PutUint16(req[4:6], uint16(internalPort))
+	binary.BigEndian.PutUint16(req[6:8], uint16(externalPort))
+	binary.BigEndian.PutUint32(req[8:12], uint32(lifetime/time.Second))
+	resp, err := c.natpmpRequest(ctx, req, 16)
+	if err != nil {
+		return 0, 0, err
+	}
+	mappedPort := int(binary.BigEndian.Uint16(resp[10:12]))
+	mappedLifetime := time.Duration(binary.BigEndian.Uint32(resp[12:16])) * time.Second
+	return mappedPort, mappedLifetime, nil
+}
+
+// natpmpRequest sends a NAT-PMP request and returns the response after checking the result code.
+func (c *natpmpClient) natpmpRequest(ctx context.Context, req []byte, respLen int) ([]byte, error) {
+	resp, err := c.roundTrip(ctx, req, func(resp []byte) bool {
+		return len(resp) >= 4 && resp[0] == natpmpVersion && resp[1] == req[1]|0x80
+	})
+	if err != nil {
+		return nil, err
+	}
+	result := binary.BigEndian.Uint16(resp[2:4])
+	if result != 0 {
+		return nil, resultError("nat-pmp", natpmpResults, int(result))
+	}
+	if len(resp) < respLen {
+		return nil, errors.New("nat-pmp: short response")
+	}
+	return resp, nil
+}
+
+func (c *natpmpClient) pcpAnnounce(ctx context.Context) error {
+	_, err := c.pcpRequest(ctx, pcpOpAnnounce, 0, nil)
+	return err
+}
+
+func (c *natpmpClient) pcpMap(ctx context.Context, proto Protocol, port int, lifetime time.Duration) (int, time.Duration, error) {
+	key := mappingKey{proto, port}
+	c.m.Lock()
+	nonce, ok := c.nonces[key]
+	if !ok {
+		_, _ = rand.Read(nonce[:])
+		c.nonces[key] = nonce
+	}
+	c.m.Unlock()
+
+	// nonce(12) + protocol(1) + reserved(3) + internal port(2) + suggested external port(2) + suggested external address(16)
+	payload := make([]byte, 36)
+	copy(payload[0:12], nonce[:])
+	payload[12] = 6
+	if proto == UDP {
+		payload[12] = 17
+	}
+	binary.BigEndian.PutUint16(payload[16:18], uint16(port))
+	binary.BigEndian.PutUint16(payload[18:20], uint16(port))
+	copy(payload[20:36], net.IPv6zero)
+	resp, err := c.pcpRequest(ctx, pcpOpMap, lifetime, payload)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(resp) < 60 {
+		return 0, 0, errors.New("pcp: short response")
+	}
+	mappedLifetime := time.Duration(binary.BigEndian.Uint32(resp[4:8])) * time.Second
+	mappedPort := int(binary.BigEndian.Uint16(resp[42:44]))
+	if lifetime > 0 {
+		externalIP := net.IP(resp[44:60]).To4()
+		c.m.Lock()
+		c.externalIP = externalIP
+		c.m.Unlock()
+	}
+	return mappedPort, mappedLifetime, nil
+}
+
+// pcpRequest sends a PCP request and returns the response after checking the result code.
+// errUnsupportedVersion is returned if the gateway only supports NAT-PMP.
+func (c *natpmpClient) pcpRequest(ctx context.Context, op byte, lifetime time.Duration, payload []byte) ([]byte, error) {
+	conn, err := net.DialUDP("udp4", nil, c.gateway)
+	if err != nil {
+		return nil, err
+	}
+	clientIP := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	// version(1) + opcode(1) + reserved(2) + lifetime(4) + client address(16)
+	req := make([]byte, 24, 24+len(payload))
+	req[0] = pcpVersion
+	req[1] = op
+	binary.BigEndian.PutUint32(req[4:8], uint32(lifetime/time.Second))
+	copy(req[8:24], clientIP.To16())
+	req = append(req, payload...)
+
+	resp, err := c.roundTrip(ctx, req, func(resp []byte) bool {
+		if len(resp) >= 4 && resp[0] == natpmpVersion {
+			return true
+		}
+		return len(resp) >= 24 && resp[0] == pcpVersion && resp[1] == op|0x80
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp[0] == natpmpVersion {
+		return nil, errUnsupportedVersion
+	}
+	if result := int(resp[3]); result != 0 {
+		if result == resultUnsupportedVersion {
+			return nil, errUnsupportedVersion
+		}
+		return nil, resultError("pcp", pcpResults, result)
+	}
+	return resp, nil
+}
+
+// roundTrip sends the request to the gateway until a response that is accepted by the match function is received.
+// The request is retransmitted with exponential backoff starting from 250ms until ctx is done.
+func (c *natpmpClient) roundTrip(ctx context.Context, req []byte, match func([]byte) bool) ([]byte, error) {
+	conn, err := net.DialUDP("udp4", nil, c.gateway)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now()) // nolint: errcheck
+		case <-done:
+		}
+	}()
+	buf := make([]byte, 1100)
+	wait := 250 * time.Millisecond
+	for {
+		_, err = conn.Write(req)
+		if err != nil {
+			return nil, err
+		}
+		deadline := time.Now().Add(wait)
+		if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+			deadline = d
+		}
+		err = conn.SetReadDeadline(deadline)
+		if err != nil {
+			return nil, err
+		}
+		for {
+			var n int
+			n, err = conn.Read(buf)
+			if err != nil {
+				break
+			}
+			if match(buf[:n]) {
+				return buf[:n], nil
+			}
+		}
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, ctxErr
+		}
+		if nerr, ok := err.(net.Error); !ok || !nerr.Timeout() {
+			return nil, err
+		}
+		wait *= 2
+	}
+}
+
+func resultError(prefix string, results []string, code int) error {
+	if code < len(results) {
+		return errors.New(prefix + ": " + results[code])
+	}
+	return fmt.Errorf("%s: unknown result code: %d", prefix, code)
+}
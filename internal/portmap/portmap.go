@@ -0,0 +1,323 @@
+// Package portmap maps ports on the gateway device with PCP, NAT-PMP and UPnP IGD protocols
+// so peers outside of the local network can connect to us.
+package portmap
+
+import (
+	"context"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/rain/internal/logger"
+)
+
+// Protocol of the mapped port.
+type Protocol string
+
+// Protocols that can be mapped.
+const (
+	TCP Protocol = "TCP"
+	UDP Protocol = "UDP"
+)
+
+const (
+	// Lifetime requested from the gateway. Mappings are renewed at the half of their lifetime.
+	mappingLifetime = 2 * time.Hour
+	// Wait duration before discovering the gateway again after a failure.
+	retryInterval = 5 * time.Minute
+	// Timeout for each discovery and mapping operation.
+	requestTimeout = 5 * time.Second
+)
+
+// client is implemented by each port mapping protocol.
+type client interface {
+	Name() string
+	ExternalIP(ctx context.Context) (net.IP, error)
+	// AddMapping maps the same port number on the gateway and returns the external port and the lifetime of the mapping.
+	// Zero lifetime means the mapping is permanent.
+	AddMapping(ctx context.Context, proto Protocol, port int, lifetime time.Duration) (int, time.Duration, error)
+	DeleteMapping(ctx context.Context, proto Protocol, port int) error
+}
+
+type mappingKey struct {
+	proto Protocol
+	port  int
+}
+
+type mapping struct {
+	// Mapping is deleted from the gateway on next update.
+	removed bool
+	// Zero if the port is not mapped yet.
+	renewAt time.Time
+}
+
+// PortMapper maintains port mappings on the gateway in a background goroutine.
+// Ports are mapped after they are added, renewed before their lifetime expires and deleted when they are removed.
+type PortMapper struct {
+	onExternalIP func(net.IP)
+	log          logger.Logger
+
+	// Overridden in tests.
+	natpmpAddr func() (*net.UDPAddr, error)
+	ssdpAddr   string
+
+	m          sync.Mutex
+	mappings   map[mappingKey]*mapping
+	externalIP net.IP
+
+	changedC chan struct{}
+	closeC   chan struct{}
+	doneC    chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+}
+
+// New returns a new PortMapper. onExternalIP is called when the external IP of the gateway is discovered or changed.
+func New(onExternalIP func(net.IP)) *PortMapper {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &PortMapper{
+		onExternalIP: onExternalIP,
+		log:          logger.New("portmap"),
+		natpmpAddr: func() (*net.UDPAddr, error) {
+			ip, err := defaultGateway()
+			if err != nil {
+				return nil, err
+			}
+			return &net.UDPAddr{IP: ip, Port: natpmpPort}, nil
+		},
+		ssdpAddr: ssdpMulticastAddr,
+		mappings: make(map[mappingKey]*mapping),
+		changedC: make(chan struct{}, 1),
+		closeC:   make(chan struct{}),
+		doneC:    make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+}
+
+// Start discovering the gateway and mapping ports.
+func (m *PortMapper) Start() {
+	go m.run()
+}
+
+// Close deletes all mappings from the gateway and stops the PortMapper.
+func (m *PortMapper) Close() {
+	close(m.closeC)
+	m.cancel()
+	<-m.doneC
+}
+
+// Add the port to the list of ports that are going to be mapped on the gateway.
+func (m *PortMapper) Add(proto Protocol, port int) {
+	m.m.Lock()
+	key := mappingKey{proto, port}
+	if mp, ok := m.mappings[key]; ok {
+		mp.removed = false
+	} else {
+		m.mappings[key] = &mapping{}
+	}
+	m.m.Unlock()
+	m.notify()
+}
+
+// Remove the port mapping from the gateway.
+func (m *PortMapper) Remove(proto Protocol, port int) {
+	m.m.Lock()
+	if mp, ok := m.mappings[mappingKey{proto, port}]; ok {
+		mp.removed = true
+	}
+	m.m.Unlock()
+	m.notify()
+}
+
+// ExternalIP returns the IP address of the gateway on the external network. Returns nil if it is not known.
+func (m *PortMapper) ExternalIP() net.IP {
+	m.m.Lock()
+	defer m.m.Unlock()
+	return m.externalIP
+}
+
+func (m *PortMapper) notify() {
+	select {
+	case m.changedC <- struct{}{}:
+	default:
+	}
+}
+
+func (m *PortMapper) run() {
+	defer close(m.doneC)
+	var c client
+	var timerC <-chan time.Time
+	for {
+		if c == nil {
+			c = m.discover()
+			if c == nil {
+				timerC = time.After(retryInterval)
+			}
+		}
+		if c != nil {
+			next, err := m.update(c)
+			if err != nil && m.ctx.Err() == nil {
+				m.log.Warningf("cannot map port with %s: %s", c.Name(), err)
+				// Gateway may have changed, discover it again on next try.
+				c = nil
+				next = retryInterval
+			}
+			timerC = time.After(next)
+		}
+		select {
+		case <-timerC:
+		case <-m.changedC:
+		case <-m.closeC:
+			if c != nil {
+				m.deleteAll(c)
+			}
+			return
+		}
+	}
+}
+
+// discover tries each protocol in order and returns the client of the first protocol that the gateway responds.
+func (m *PortMapper) discover() client {
+	ctx, cancel := context.WithTimeout(m.ctx, requestTimeout)
+	defer cancel()
+	addr, err := m.natpmpAddr()
+	if err == nil {
+		var c *natpmpClient
+		c, err = discoverNATPMP(ctx, addr)
+		if err == nil {
+			m.log.Infof("found gateway %s supporting %s", addr.IP, c.Name())
+			return c
+		}
+	}
+	m.log.Debugln("nat-pmp/pcp discovery failed:", err)
+	ctx, cancel = context.WithTimeout(m.ctx, requestTimeout)
+	defer cancel()
+	uc, err := discoverUPnP(ctx, m.ssdpAddr)
+	if err != nil {
+		m.log.Debugln("upnp discovery failed:", err)
+		return nil
+	}
+	m.log.Infoln("found gateway supporting UPnP at", uc.controlURL)
+	return uc
+}
+
+// update adds new mappings, renews expiring ones and deletes removed ones on the gateway.
+// Returns the duration until the next renewal.
+func (m *PortMapper) update(c client) (time.Duration, error) {
+	now := time.Now()
+	next := mappingLifetime / 2
+	m.m.Lock()
+	keys := make([]mappingKey, 0, len(m.mappings))
+	for key := range m.mappings {
+		keys = append(keys, key)
+	}
+	m.m.Unlock()
+	var changed bool
+	for _, key := range keys {
+		m.m.Lock()
+		mp, ok := m.mappings[key]
+		var removed bool
+		var renewAt time.Time
+		if ok {
+			removed, renewAt = mp.removed, mp.renewAt
+		}
+		m.m.Unlock()
+		if !ok {
+			continue
+		}
+		if removed {
+			if !renewAt.IsZero() {
+				ctx, cancel := context.WithTimeout(m.ctx, requestTimeout)
+				err := c.DeleteMapping(ctx, key.proto, key.port)
+				cancel()
+				if err != nil {
+					m.log.Debugf("cannot delete %s port mapping %d: %s", key.proto, key.port, err)
+				}
+			}
+			m.m.Lock()
+			if mp.removed {
+				delete(m.mappings, key)
+			} else {
+				// Added again while deleting, map it on next update.
+				mp.renewAt = time.Time{}
+				m.notify()
+			}
+			m.m.Unlock()
+			continue
+		}
+		if now.Before(renewAt) {
+			if d := renewAt.Sub(now); d < next {
+				next = d
+			}
+			continue
+		}
+		ctx, cancel := context.WithTimeout(m.ctx, requestTimeout)
+		externalPort, lifetime, err := c.AddMapping(ctx, key.proto, key.port, mappingLifetime)
+		cancel()
+		if err != nil {
+			return 0, err
+		}
+		if renewAt.IsZero() {
+			m.log.Infof("mapped %s port %d with %s", key.proto, key.port, c.Name())
+		}
+		if externalPort != key.port {
+			m.log.Warningf("gateway mapped %s port %d to a different external port: %d", key.proto, key.port, externalPort)
+		}
+		if lifetime <= 0 {
+			lifetime = mappingLifetime
+		}
+		m.m.Lock()
+		mp.renewAt = now.Add(lifetime / 2)
+		m.m.Unlock()
+		if lifetime/2 < next {
+			next = lifetime / 2
+		}
+		changed = true
+	}
+	if changed {
+		m.updateExternalIP(c)
+	}
+	return next, nil
+}
+
+func (m *PortMapper) updateExternalIP(c client) {
+	ctx, cancel := context.WithTimeout(m.ctx, requestTimeout)
+	defer cancel()
+	ip, err := c.ExternalIP(ctx)
+	if err != nil {
+		m.log.Debugln("cannot get external ip:", err)
+		return
+	}
+	m.m.Lock()
+	old := m.externalIP
+	m.externalIP = ip
+	m.m.Unlock()
+	if ip.Equal(old) {
+		return
+	}
+	m.log.Infoln("external ip of gateway:", ip)
+	if m.onExternalIP != nil {
+		m.onExternalIP(ip)
+	}
+}
+
+// deleteAll is called on Close and deletes all mappings from the gateway.
+func (m *PortMapper) deleteAll(c client) {
+	var keys []mappingKey
+	m.m.Lock()
+	for key, mp := range m.mappings {
+		if !mp.renewAt.IsZero() {
+			keys = append(keys, key)
+		}
+	}
+	m.m.Unlock()
+	for _, key := range keys {
+		ctx, cancel := context.WithTimeout(context.Background(), requestTimeout)
+		err := c.DeleteMapping(ctx, key.proto, key.port)
+		cancel()
+		if err != nil {
+			m.log.Debugf("cannot delete %s port mapping %d: %s", key.proto, key.port, err)
+		}
+	}
+}
@@ -0,0 +1,254 @@
+package portmap
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var testExternalIP = net.IPv4(203, 0, 113, 7).To4()
+
+const timeout = 5 * time.Second
+
+// fakeGateway records the mappings that are requested by the client.
+type fakeGateway struct {
+	m        sync.Mutex
+	mappings map[string]time.Duration
+	changedC chan struct{}
+}
+
+func newFakeGateway() *fakeGateway {
+	return &fakeGateway{
+		mappings: make(map[string]time.Duration),
+		changedC: make(chan struct{}, 100),
+	}
+}
+
+func (g *fakeGateway) set(proto string, port int, lifetime time.Duration) {
+	key := fmt.Sprintf("%s/%d", proto, port)
+	g.m.Lock()
+	if lifetime == 0 {
+		delete(g.mappings, key)
+	} else {
+		g.mappings[key] = lifetime
+	}
+	g.m.Unlock()
+	g.changedC <- struct{}{}
+}
+
+// waitFor waits until the set of mapped ports becomes equal to keys.
+func (g *fakeGateway) waitFor(t *testing.T, keys ...string) {
+	deadline := time.After(timeout)
+	for {
+		g.m.Lock()
+		ok := len(g.mappings) == len(keys)
+		for _, key := range keys {
+			if _, found := g.mappings[key]; !found {
+				ok = false
+			}
+		}
+		g.m.Unlock()
+		if ok {
+			return
+		}
+		select {
+		case <-g.changedC:
+		case <-deadline:
+			t.Fatalf("mappings are not updated, want: %v", keys)
+		}
+	}
+}
+
+// startNATPMPServer runs a fake gateway on loopback that responds to NAT-PMP requests.
+// If pcp is true, PCP requests are responded too. Otherwise, PCP requests are rejected with unsupported version.
+func startNATPMPServer(t *testing.T, g *fakeGateway, pcp bool) *net.UDPAddr {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	go func() {
+		buf := make([]byte, 1100)
+		for {
+			n, addr, err := conn.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			req := buf[:n]
+			var resp []byte
+			switch {
+			case req[0] == pcpVersion && !pcp:
+				resp = []byte{natpmpVersion, 0x80 | req[1], 0, resultUnsupportedVersion}
+			case req[0] == pcpVersion && req[1] == pcpOpAnnounce:
+				resp = make([]byte, 24)
+				resp[0], resp[1] = pcpVersion, 0x80|pcpOpAnnounce
+			case req[0] == pcpVersion && req[1] == pcpOpMap:
+				proto := "TCP"
+				if req[36] == 17 {
+					proto = "UDP"
+				}
+				port := int(binary.BigEndian.Uint16(req[40:42]))
+				lifetime := binary.BigEndian.Uint32(req[4:8])
+				g.set(proto, port, time.Duration(lifetime)*time.Second)
+				resp = make([]byte, 60)
+				resp[0], resp[1] = pcpVersion, 0x80|pcpOpMap
+				copy(resp[4:8], req[4:8])
+				copy(resp[24:40], req[24:40])
+				binary.BigEndian.PutUint16(resp[42:44], uint16(port))
+				copy(resp[44:60], testExternalIP.To16())
+			case req[1] == natpmpOpExternalAddress:
+				resp = make([]byte, 12)
+				resp[1] = 0x80
+				copy(resp[8:12], testExternalIP)
+			case req[1] == natpmpOpMapTCP || req[1] == natpmpOpMapUDP:
+				proto := "TCP"
+				if req[1] == natpmpOpMapUDP {
+					proto = "UDP"
+				}
+				port := int(binary.BigEndian.Uint16(req[4:6]))
+				lifetime := binary.BigEndian.Uint32(req[8:12])
+				g.set(proto, port, time.Duration(lifetime)*time.Second)
+				resp = make([]byte, 16)
+				resp[1] = 0x80 | req[1]
+				copy(resp[8:10], req[4:6])
+				copy(resp[10:12], req[4:6])
+				copy(resp[12:16], req[8:12])
+			default:
+				continue
+			}
+			_, _ = conn.WriteToUDP(resp, addr)
+		}
+	}()
+	return conn.LocalAddr().(*net.UDPAddr)
+}
+
+func testPortMapper(t *testing.T, m *PortMapper, g *fakeGateway) {
+	ipC := make(chan net.IP, 1)
+	m.onExternalIP = func(ip net.IP) { ipC <- ip }
+	m.Start()
+	m.Add(TCP, 6881)
+	m.Add(UDP, 7246)
+	g.waitFor(t, "TCP/6881", "UDP/7246")
+	select {
+	case ip := <-ipC:
+		assert.True(t, testExternalIP.Equal(ip))
+	case <-time.After(timeout):
+		t.Fatal("external ip is not discovered")
+	}
+	assert.True(t, testExternalIP.Equal(m.ExternalIP()))
+
+	m.Remove(TCP, 6881)
+	g.waitFor(t, "UDP/7246")
+
+	m.Close()
+	g.waitFor(t)
+}
+
+func noNATPMP() (*net.UDPAddr, error) {
+	return nil, io.EOF
+}
+
+func TestNATPMP(t *testing.T) {
+	g := newFakeGateway()
+	addr := startNATPMPServer(t, g, false)
+	m := New(nil)
+	m.natpmpAddr = func() (*net.UDPAddr, error) { return addr, nil }
+	testPortMapper(t, m, g)
+}
+
+func TestPCP(t *testing.T) {
+	g := newFakeGateway()
+	addr := startNATPMPServer(t, g, true)
+	m := New(nil)
+	m.natpmpAddr = func() (*net.UDPAddr, error) { return addr, nil }
+	testPortMapper(t, m, g)
+}
+
+func TestUPnP(t *testing.T) {
+	g := newFakeGateway()
+	const serviceType = "urn:schemas-upnp-org:service:WANIPConnection:1"
+	mux := http.NewServeMux()
+	mux.HandleFunc("/desc.xml", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, `<?xml version="1.0"?>
+<root xmlns="urn:schemas-upnp-org:device-1-0">
+<device>
+<deviceType>urn:schemas-upnp-org:device:InternetGatewayDevice:1</deviceType>
+<deviceList><device>
+<deviceType>urn:schemas-upnp-org:device:WANDevice:1</deviceType>
+<deviceList><device>
+<deviceType>urn:schemas-upnp-org:device:WANConnectionDevice:1</deviceType>
+<serviceList><service>
+<serviceType>`+serviceType+`</serviceType>
+<controlURL>/ctl/IPConn</controlURL>
+</service></serviceList>
+</device></deviceList>
+</device></deviceList>
+</device>
+</root>`)
+	})
+	mux.HandleFunc("/ctl/IPConn", func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		action := strings.TrimPrefix(strings.Trim(r.Header.Get("SOAPAction"), `"`), serviceType+"#")
+		switch action {
+		case "GetExternalIPAddress":
+			_, _ = io.WriteString(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body>`+
+				`<u:GetExternalIPAddressResponse xmlns:u="`+serviceType+`"><NewExternalIPAddress>`+testExternalIP.String()+`</NewExternalIPAddress>`+
+				`</u:GetExternalIPAddressResponse></s:Body></s:Envelope>`)
+		case "AddPortMapping":
+			if xmlValue(b, "NewLeaseDuration") != "0" {
+				w.WriteHeader(http.StatusInternalServerError)
+				_, _ = io.WriteString(w, `<?xml version="1.0"?><s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/"><s:Body><s:Fault>`+
+					`<detail><UPnPError xmlns="urn:schemas-upnp-org:control-1-0"><errorCode>725</errorCode>`+
+					`<errorDescription>OnlyPermanentLeasesSupported</errorDescription></UPnPError></detail></s:Fault></s:Body></s:Envelope>`)
+				return
+			}
+			port, _ := strconv.Atoi(xmlValue(b, "NewExternalPort"))
+			g.set(xmlValue(b, "NewProtocol"), port, time.Hour)
+		case "DeletePortMapping":
+			port, _ := strconv.Atoi(xmlValue(b, "NewExternalPort"))
+			g.set(xmlValue(b, "NewProtocol"), port, 0)
+		default:
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	})
+	srv := httptest.NewServer(mux)
+	defer srv.Close()
+
+	// Fake SSDP responder that replies to search requests with the location of the device description.
+	ssdp, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ssdp.Close()
+	go func() {
+		buf := make([]byte, 2048)
+		for {
+			n, addr, err := ssdp.ReadFromUDP(buf)
+			if err != nil {
+				return
+			}
+			if !strings.HasPrefix(string(buf[:n]), "M-SEARCH") {
+				continue
+			}
+			resp := "HTTP/1.1 200 OK\r\n" +
+				"ST: urn:schemas-upnp-org:device:InternetGatewayDevice:1\r\n" +
+				"LOCATION: " + srv.URL + "/desc.xml\r\n\r\n"
+			_, _ = ssdp.WriteToUDP([]byte(resp), addr)
+		}
+	}()
+
+	m := New(nil)
+	m.natpmpAddr = noNATPMP
+	m.ssdpAddr = ssdp.LocalAddr().String()
+	testPortMapper(t, m, g)
+}
@@ -0,0 +1,315 @@
+package portmap
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const ssdpMulticastAddr = "239.255.255.250:1900"
+
+var igdDeviceTypes = []string{
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:2",
+	"urn:schemas-upnp-org:device:InternetGatewayDevice:1",
+}
+
+// Services that can be used for port mapping in the order of preference.
+var wanServiceTypes = []string{
+	"urn:schemas-upnp-org:service:WANIPConnection:2",
+	"urn:schemas-upnp-org:service:WANIPConnection:1",
+	"urn:schemas-upnp-org:service:WANPPPConnection:1",
+}
+
+// UPnP error code returned from routers that do not support leases with a duration.
+const upnpErrOnlyPermanentLeasesSupported = 725
+
+const maxUPnPResponseSize = 1 << 20
+
+// upnpClient adds port mappings with the SOAP API of a UPnP Internet Gateway Device.
+type upnpClient struct {
+	controlURL  string
+	serviceType string
+	// Address of this host in the LAN. Mappings are forwarded to this address.
+	internalIP net.IP
+	httpClient http.Client
+}
+
+var _ client = (*upnpClient)(nil)
+
+// discoverUPnP finds a gateway device by sending SSDP search requests to the address and
+// returns a client for the first device that has a WAN connection service.
+func discoverUPnP(ctx context.Context, ssdpAddr string) (*upnpClient, error) {
+	raddr, err := net.ResolveUDPAddr("udp4", ssdpAddr)
+	if err != nil {
+		return nil, err
+	}
+	conn, err := net.ListenUDP("udp4", nil)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetReadDeadline(time.Now()) // nolint: errcheck
+		case <-done:
+		}
+	}()
+	for _, st := range igdDeviceTypes {
+		msg := "M-SEARCH * HTTP/1.1\r\n" +
+			"HOST: " + ssdpMulticastAddr + "\r\n" +
+			"ST: " + st + "\r\n" +
+			"MAN: \"ssdp:discover\"\r\n" +
+			"MX: 2\r\n\r\n"
+		_, err = conn.WriteToUDP([]byte(msg), raddr)
+		if err != nil {
+			return nil, err
+		}
+	}
+	seen := make(map[string]struct{})
+	buf := make([]byte, 2048)
+	for {
+		n, _, err := conn.ReadFromUDP(buf)
+		if err != nil {
+			if ctxErr := ctx.Err(); ctxErr != nil {
+				return nil, errors.New("upnp: no gateway device found")
+			}
+			return nil, err
+		}
+		resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(buf[:n])), nil)
+		if err != nil {
+			continue
+		}
+		resp.Body.Close()
+		location := resp.Header.Get("Location")
+		if location == "" {
+			continue
+		}
+		if _, ok := seen[location]; ok {
+			continue
+		}
+		seen[location] = struct{}{}
+		c, err := newUPnPClient(ctx, location)
+		if err != nil {
+			continue
+		}
+		return c, nil
+	}
+}
+
+type upnpRoot struct {
+	URLBase string     `xml:"URLBase"`
+	Device  upnpDevice `xml:"device"`
+}
+
+type upnpDevice struct {
+	DeviceType string        `xml:"deviceType"`
+	Services   []upnpService `xml:"serviceList>service"`
+	Devices    []upnpDevice  `xml:"deviceList>device"`
+}
+
+type upnpService struct {
+	ServiceType string `xml:"serviceType"`
+	ControlURL  string `xml:"controlURL"`
+}
+
+func (d *upnpDevice) findService(serviceType string) *upnpService {
+	for i := range d.Services {
+		if d.Services[i].ServiceType == serviceType {
+			return &d.Services[i]
+		}
+	}
+	for i := range d.Devices {
+		if s := d.Devices[i].findService(serviceType); s != nil {
+			return s
+		}
+	}
+	return nil
+}
+
+// newUPnPClient fetches the device description from location and finds the control URL of the WAN connection service.
+func newUPnPClient(ctx context.Context, location string) (*upnpClient, error) {
+	c := &upnpClient{}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, location, nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, errors.New("upnp: cannot get device description: " + resp.Status)
+	}
+	var root upnpRoot
+	err = xml.NewDecoder(io.LimitReader(resp.Body, maxUPnPResponseSize)).Decode(&root)
+	if err != nil {
+		return nil, err
+	}
+	var service *upnpService
+	for _, st := range wanServiceTypes {
+		service = root.Device.findService(st)
+		if service != nil {
+			break
+		}
+	}
+	if service == nil {
+		return nil, errors.New("upnp: device does not have a wan connection service")
+	}
+	base, err := url.Parse(location)
+	if err != nil {
+		return nil, err
+	}
+	if root.URLBase != "" {
+		base, err = url.Parse(root.URLBase)
+		if err != nil {
+			return nil, err
+		}
+	}
+	control, err := base.Parse(service.ControlURL)
+	if err != nil {
+		return nil, err
+	}
+	// Find out which local address is used for talking to the gateway.
+	conn, err := net.Dial("udp4", base.Host)
+	if err != nil {
+		return nil, err
+	}
+	c.internalIP = conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+	c.controlURL = control.String()
+	c.serviceType = service.ServiceType
+	return c, nil
+}
+
+func (c *upnpClient) Name() string {
+	return "UPnP"
+}
+
+func (c *upnpClient) ExternalIP(ctx context.Context) (net.IP, error) {
+	resp, err := c.soapRequest(ctx, "GetExternalIPAddress", nil)
+	if err != nil {
+		return nil, err
+	}
+	s := xmlValue(resp, "NewExternalIPAddress")
+	ip := net.ParseIP(s)
+	if ip == nil {
+		return nil, errors.New("upnp: invalid external address: " + s)
+	}
+	return ip, nil
+}
+
+func (c *upnpClient) AddMapping(ctx context.Context, proto Protocol, port int, lifetime time.Duration) (int, time.Duration, error) {
+	err := c.addPortMapping(ctx, proto, port, lifetime)
+	var uerr *upnpError
+	if errors.As(err, &uerr) && uerr.Code == upnpErrOnlyPermanentLeasesSupported {
+		lifetime = 0
+		err = c.addPortMapping(ctx, proto, port, lifetime)
+	}
+	if err != nil {
+		return 0, 0, err
+	}
+	return port, lifetime, nil
+}
+
+func (c *upnpClient) addPortMapping(ctx context.Context, proto Protocol, port int, lifetime time.Duration) error {
+	_, err := c.soapRequest(ctx, "AddPortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(port)},
+		{"NewProtocol", string(proto)},
+		{"NewInternalPort", strconv.Itoa(port)},
+		{"NewInternalClient", c.internalIP.String()},
+		{"NewEnabled", "1"},
+		{"NewPortMappingDescription", "rain " + string(proto) + " " + strconv.Itoa(port)},
+		{"NewLeaseDuration", strconv.Itoa(int(lifetime / time.Second))},
+	})
+	return err
+}
+
+func (c *upnpClient) DeleteMapping(ctx context.Context, proto Protocol, port int) error {
+	_, err := c.soapRequest(ctx, "DeletePortMapping", [][2]string{
+		{"NewRemoteHost", ""},
+		{"NewExternalPort", strconv.Itoa(port)},
+		{"NewProtocol", string(proto)},
+	})
+	return err
+}
+
+type upnpError struct {
+	Code        int
+	Description string
+}
+
+func (e *upnpError) Error() string {
+	return fmt.Sprintf("upnp: error %d: %s", e.Code, e.Description)
+}
+
+// soapRequest calls the action on the WAN connection service. Arguments must be given in the order defined in the specification.
+func (c *upnpClient) soapRequest(ctx context.Context, action string, args [][2]string) ([]byte, error) {
+	var body bytes.Buffer
+	body.WriteString(`<?xml version="1.0"?>` +
+		`<s:Envelope xmlns:s="http://schemas.xmlsoap.org/soap/envelope/" s:encodingStyle="http://schemas.xmlsoap.org/soap/encoding/">` +
+		`<s:Body><u:` + action + ` xmlns:u="` + c.serviceType + `">`)
+	for _, arg := range args {
+		body.WriteString("<" + arg[0] + ">")
+		_ = xml.EscapeText(&body, []byte(arg[1]))
+		body.WriteString("</" + arg[0] + ">")
+	}
+	body.WriteString(`</u:` + action + `></s:Body></s:Envelope>`)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.controlURL, &body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", `text/xml; charset="utf-8"`)
+	req.Header.Set("SOAPAction", `"`+c.serviceType+"#"+action+`"`)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(io.LimitReader(resp.Body, maxUPnPResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		code, err := strconv.Atoi(xmlValue(b, "errorCode"))
+		if err != nil {
+			return nil, errors.New("upnp: " + action + " failed: " + resp.Status)
+		}
+		return nil, &upnpError{Code: code, Description: xmlValue(b, "errorDescription")}
+	}
+	return b, nil
+}
+
+// xmlValue returns the text of the first element with the local name in the document.
+func xmlValue(doc []byte, name string) string {
+	d := xml.NewDecoder(bytes.NewReader(doc))
+	for {
+		tok, err := d.Token()
+		if err != nil {
+			return ""
+		}
+		se, ok := tok.(xml.StartElement)
+		if !ok || se.Name.Local != name {
+			continue
+		}
+		var s string
+		if d.DecodeElement(&s, &se) != nil {
+			return ""
+		}
+		return strings.TrimSpace(s)
+	}
+}
@@ -0,0 +1,189 @@
+// Package proxy implements opening TCP connections and relaying UDP packets through SOCKS5 and HTTP proxy servers.
+package proxy
+
+import (
+	"bufio"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// Dialer opens network connections. It is implemented by net.Dialer and Proxy.
+type Dialer interface {
+	DialContext(ctx context.Context, network, address string) (net.Conn, error)
+}
+
+// Proxy is a Dialer that opens connections through a proxy server.
+type Proxy struct {
+	url     *url.URL
+	forward Dialer
+}
+
+var _ Dialer = (*Proxy)(nil)
+
+// New returns a new Proxy from the URL of the proxy server.
+// Supported schemes are "socks5", "socks5h" and "http". Credentials can be given as the user info part of the URL.
+// Connections to the proxy server are made with the forward Dialer. If forward is nil, a default net.Dialer is used.
+func New(rawURL string, forward Dialer) (*Proxy, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	var defaultPort string
+	switch u.Scheme {
+	case "socks5", "socks5h":
+		defaultPort = "1080"
+	case "http":
+		defaultPort = "80"
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme: %q", u.Scheme)
+	}
+	if u.Hostname() == "" {
+		return nil, errors.New("proxy host is empty")
+	}
+	if u.Port() == "" {
+		u.Host = net.JoinHostPort(u.Hostname(), defaultPort)
+	}
+	if forward == nil {
+		forward = &net.Dialer{}
+	}
+	return &Proxy{
+		url:     u,
+		forward: forward,
+	}, nil
+}
+
+// Addr returns the address of the proxy server in host:port format.
+func (p *Proxy) Addr() string {
+	return p.url.Host
+}
+
+// SupportsUDP returns true if UDP packets can be relayed through the proxy.
+func (p *Proxy) SupportsUDP() bool {
+	return p.isSOCKS()
+}
+
+// RemoteDNS returns true if host names are resolved by the proxy server.
+// Only the "socks5" scheme requires the address to be resolved locally.
+func (p *Proxy) RemoteDNS() bool {
+	return p.url.Scheme != "socks5"
+}
+
+func (p *Proxy) isSOCKS() bool {
+	return p.url.Scheme == "socks5" || p.url.Scheme == "socks5h"
+}
+
+// DialContext connects to the address through the proxy server. Only TCP networks are supported.
+func (p *Proxy) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	switch network {
+	case "tcp", "tcp4", "tcp6":
+	default:
+		return nil, p.opError(network, address, errors.New("network not supported: "+network))
+	}
+	conn, err := p.forward.DialContext(ctx, "tcp", p.url.Host)
+	if err != nil {
+		return nil, err
+	}
+	err = p.handshake(ctx, conn, func() error {
+		if p.isSOCKS() {
+			_, err := p.socksRequest(conn, socksCmdConnect, address)
+			return err
+		}
+		c, err := p.httpConnect(conn, address)
+		conn = c
+		return err
+	})
+	if err != nil {
+		conn.Close()
+		return nil, p.opError(network, address, err)
+	}
+	return conn, nil
+}
+
+// handshake runs fn by respecting the deadline and the cancellation of ctx.
+func (p *Proxy) handshake(ctx context.Context, conn net.Conn, fn func() error) error {
+	if deadline, ok := ctx.Deadline(); ok {
+		err := conn.SetDeadline(deadline)
+		if err != nil {
+			return err
+		}
+		defer conn.SetDeadline(time.Time{}) // nolint: errcheck
+	}
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			conn.SetDeadline(time.Now()) // nolint: errcheck
+		case <-done:
+		}
+	}()
+	err := fn()
+	if ctxErr := ctx.Err(); ctxErr != nil {
+		return ctxErr
+	}
+	return err
+}
+
+func (p *Proxy) opError(network, address string, err error) error {
+	return &net.OpError{
+		Op:   "proxy",
+		Net:  network,
+		Addr: stringAddr{network, address},
+		Err:  err,
+	}
+}
+
+// httpConnect opens a tunnel to the address with the CONNECT method of HTTP.
+func (p *Proxy) httpConnect(conn net.Conn, address string) (net.Conn, error) {
+	req := &http.Request{
+		Method: http.MethodConnect,
+		URL:    &url.URL{Opaque: address},
+		Host:   address,
+		Header: make(http.Header),
+	}
+	if u := p.url.User; u != nil {
+		password, _ := u.Password()
+		auth := base64.StdEncoding.EncodeToString([]byte(u.Username() + ":" + password))
+		req.Header.Set("Proxy-Authorization", "Basic "+auth)
+	}
+	err := req.Write(conn)
+	if err != nil {
+		return conn, err
+	}
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		return conn, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return conn, errors.New("proxy responded with status: " + resp.Status)
+	}
+	if br.Buffered() > 0 {
+		// Server may have sent some bytes after the response header. Do not lose them.
+		return &bufferedConn{Conn: conn, r: br}, nil
+	}
+	return conn, nil
+}
+
+type bufferedConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+func (c *bufferedConn) Read(b []byte) (int, error) {
+	return c.r.Read(b)
+}
+
+type stringAddr struct {
+	network, address string
+}
+
+func (a stringAddr) Network() string { return a.network }
+func (a stringAddr) String() string  { return a.address }
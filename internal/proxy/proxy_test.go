@@ -0,0 +1,307 @@
+package proxy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSOCKS5Connect(t *testing.T) {
+	echo := startTCPEcho(t)
+	server := startSOCKSServer(t, "", "")
+	p, err := New("socks5://"+server, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := p.DialContext(context.Background(), "tcp", echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	assertEcho(t, conn)
+}
+
+func TestSOCKS5Auth(t *testing.T) {
+	echo := startTCPEcho(t)
+	server := startSOCKSServer(t, "user", "pass")
+
+	p, err := New("socks5://user:wrong@"+server, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = p.DialContext(context.Background(), "tcp", echo)
+	assert.Error(t, err)
+
+	p, err = New("socks5://user:pass@"+server, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := p.DialContext(context.Background(), "tcp", echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	assertEcho(t, conn)
+}
+
+func TestSOCKS5UDP(t *testing.T) {
+	echo, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer echo.Close()
+	go func() {
+		buf := make([]byte, 1500)
+		for {
+			n, addr, err := echo.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			_, _ = echo.WriteTo(buf[:n], addr)
+		}
+	}()
+	server := startSOCKSServer(t, "", "")
+	p, err := New("socks5://"+server, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pc, err := p.ListenPacket(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+	_, err = pc.WriteTo([]byte("hello"), echo.LocalAddr())
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = pc.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 100)
+	n, from, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "hello", string(buf[:n]))
+	assert.Equal(t, echo.LocalAddr().String(), from.String())
+}
+
+func TestHTTPConnect(t *testing.T) {
+	echo := startTCPEcho(t)
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		req, err := http.ReadRequest(bufio.NewReader(conn))
+		if err != nil || req.Method != http.MethodConnect {
+			return
+		}
+		if req.Header.Get("Proxy-Authorization") != "Basic dXNlcjpwYXNz" {
+			_, _ = io.WriteString(conn, "HTTP/1.1 407 Proxy Authentication Required\r\n\r\n")
+			return
+		}
+		target, err := net.Dial("tcp", req.Host)
+		if err != nil {
+			_, _ = io.WriteString(conn, "HTTP/1.1 502 Bad Gateway\r\n\r\n")
+			return
+		}
+		defer target.Close()
+		_, _ = io.WriteString(conn, "HTTP/1.1 200 OK\r\n\r\n")
+		pipe(conn, target)
+	}()
+	p, err := New("http://user:pass@"+l.Addr().String(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	conn, err := p.DialContext(context.Background(), "tcp", echo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	assertEcho(t, conn)
+
+	_, err = p.ListenPacket(context.Background())
+	assert.Error(t, err)
+}
+
+func assertEcho(t *testing.T, conn net.Conn) {
+	_, err := conn.Write([]byte("hello"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 5)
+	_, err = io.ReadFull(conn, buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, "hello", string(buf))
+}
+
+func startTCPEcho(t *testing.T) string {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				_, _ = io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return l.Addr().String()
+}
+
+// startSOCKSServer runs a minimal SOCKS5 server that supports CONNECT and UDP ASSOCIATE commands.
+func startSOCKSServer(t *testing.T, username, password string) string {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go serveSOCKS(conn, username, password)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func serveSOCKS(conn net.Conn, username, password string) {
+	defer conn.Close()
+	var b [2]byte
+	if _, err := io.ReadFull(conn, b[:]); err != nil {
+		return
+	}
+	methods := make([]byte, b[1])
+	if _, err := io.ReadFull(conn, methods); err != nil {
+		return
+	}
+	if username != "" {
+		_, _ = conn.Write([]byte{socksVersion, socksAuthPassword})
+		if _, err := io.ReadFull(conn, b[:]); err != nil {
+			return
+		}
+		user := make([]byte, b[1])
+		if _, err := io.ReadFull(conn, user); err != nil {
+			return
+		}
+		if _, err := io.ReadFull(conn, b[:1]); err != nil {
+			return
+		}
+		pass := make([]byte, b[0])
+		if _, err := io.ReadFull(conn, pass); err != nil {
+			return
+		}
+		if string(user) != username || string(pass) != password {
+			_, _ = conn.Write([]byte{1, 1})
+			return
+		}
+		_, _ = conn.Write([]byte{1, 0})
+	} else {
+		_, _ = conn.Write([]byte{socksVersion, socksAuthNone})
+	}
+	var header [3]byte
+	if _, err := io.ReadFull(conn, header[:]); err != nil {
+		return
+	}
+	addr, err := readSOCKSAddr(conn)
+	if err != nil {
+		return
+	}
+	switch header[1] {
+	case socksCmdConnect:
+		target, err := net.Dial("tcp", addr.String())
+		if err != nil {
+			_, _ = conn.Write([]byte{socksVersion, 5, 0, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+			return
+		}
+		defer target.Close()
+		reply, _ := appendSOCKSAddr([]byte{socksVersion, 0, 0}, target.LocalAddr().String())
+		_, _ = conn.Write(reply)
+		pipe(conn, target)
+	case socksCmdUDPAssociate:
+		relay, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+		if err != nil {
+			return
+		}
+		defer relay.Close()
+		reply, _ := appendSOCKSAddr([]byte{socksVersion, 0, 0}, relay.LocalAddr().String())
+		_, _ = conn.Write(reply)
+		go relayUDP(relay)
+		_, _ = io.Copy(io.Discard, conn)
+	default:
+		_, _ = conn.Write([]byte{socksVersion, 7, 0, socksAddrIPv4, 0, 0, 0, 0, 0, 0})
+	}
+}
+
+func relayUDP(relay *net.UDPConn) {
+	var client *net.UDPAddr
+	buf := make([]byte, 1500)
+	for {
+		n, from, err := relay.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		if client == nil || (from.IP.Equal(client.IP) && from.Port == client.Port) {
+			// Datagram from the client, strip the header and forward.
+			client = from
+			r := bytes.NewReader(buf[3:n])
+			dest, err := readSOCKSAddr(r)
+			if err != nil {
+				continue
+			}
+			_, _ = relay.WriteToUDP(buf[n-r.Len():n], dest)
+			continue
+		}
+		// Datagram from a remote host, prepend the header and send to the client.
+		packet, _ := appendSOCKSAddr([]byte{0, 0, 0}, from.String())
+		packet = append(packet, buf[:n]...)
+		_, _ = relay.WriteToUDP(packet, client)
+	}
+}
+
+func pipe(a, b net.Conn) {
+	done := make(chan struct{}, 2)
+	go func() {
+		_, _ = io.Copy(a, b)
+		done <- struct{}{}
+	}()
+	go func() {
+		_, _ = io.Copy(b, a)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+func TestRemoteDNS(t *testing.T) {
+	for scheme, remote := range map[string]bool{"socks5": false, "socks5h": true, "http": true} {
+		p, err := New(scheme+"://127.0.0.1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, remote, p.RemoteDNS(), scheme)
+	}
+}
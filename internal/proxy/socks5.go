@@ -0,0 +1,196 @@
+package proxy
+
+// https://www.rfc-editor.org/rfc/rfc1928
+// https://www.rfc-editor.org/rfc/rfc1929
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+)
+
+const socksVersion = 5
+
+const (
+	socksAuthNone         = 0x00
+	socksAuthPassword     = 0x02
+	socksAuthNoAcceptable = 0xff
+)
+
+const (
+	socksCmdConnect      = 0x01
+	socksCmdUDPAssociate = 0x03
+)
+
+const (
+	socksAddrIPv4   = 0x01
+	socksAddrDomain = 0x03
+	socksAddrIPv6   = 0x04
+)
+
+var socksReplies = []string{
+	"succeeded",
+	"general SOCKS server failure",
+	"connection not allowed by ruleset",
+	"network unreachable",
+	"host unreachable",
+	"connection refused",
+	"TTL expired",
+	"command not supported",
+	"address type not supported",
+}
+
+// socksRequest authenticates to the SOCKS server and sends the command.
+// Returns the address that is bound by the server for the command.
+func (p *Proxy) socksRequest(conn net.Conn, cmd byte, address string) (*net.UDPAddr, error) {
+	err := p.socksAuthenticate(conn)
+	if err != nil {
+		return nil, err
+	}
+	addr, err := appendSOCKSAddr([]byte{socksVersion, cmd, 0}, address)
+	if err != nil {
+		return nil, err
+	}
+	_, err = conn.Write(addr)
+	if err != nil {
+		return nil, err
+	}
+	var header [3]byte
+	_, err = io.ReadFull(conn, header[:])
+	if err != nil {
+		return nil, err
+	}
+	if header[0] != socksVersion {
+		return nil, fmt.Errorf("unexpected socks version: %d", header[0])
+	}
+	if header[1] != 0 {
+		if int(header[1]) < len(socksReplies) {
+			return nil, errors.New("socks: " + socksReplies[header[1]])
+		}
+		return nil, fmt.Errorf("socks: unknown reply code: %d", header[1])
+	}
+	return readSOCKSAddr(conn)
+}
+
+func (p *Proxy) socksAuthenticate(conn net.Conn) error {
+	methods := []byte{socksVersion, 1, socksAuthNone}
+	if p.url.User != nil {
+		methods = []byte{socksVersion, 2, socksAuthNone, socksAuthPassword}
+	}
+	_, err := conn.Write(methods)
+	if err != nil {
+		return err
+	}
+	var reply [2]byte
+	_, err = io.ReadFull(conn, reply[:])
+	if err != nil {
+		return err
+	}
+	if reply[0] != socksVersion {
+		return fmt.Errorf("unexpected socks version: %d", reply[0])
+	}
+	switch reply[1] {
+	case socksAuthNone:
+		return nil
+	case socksAuthPassword:
+		if p.url.User == nil {
+			return errors.New("socks: server requires authentication")
+		}
+		username := p.url.User.Username()
+		password, _ := p.url.User.Password()
+		if len(username) > 255 || len(password) > 255 {
+			return errors.New("socks: username or password is too long")
+		}
+		b := make([]byte, 0, 3+len(username)+len(password))
+		b = append(b, 1, byte(len(username)))
+		b = append(b, username...)
+		b = append(b, byte(len(password)))
+		b = append(b, password...)
+		_, err = conn.Write(b)
+		if err != nil {
+			return err
+		}
+		_, err = io.ReadFull(conn, reply[:])
+		if err != nil {
+			return err
+		}
+		if reply[1] != 0 {
+			return errors.New("socks: authentication failed")
+		}
+		return nil
+	case socksAuthNoAcceptable:
+		return errors.New("socks: no acceptable authentication methods")
+	default:
+		return fmt.Errorf("socks: unsupported authentication method: %d", reply[1])
+	}
+}
+
+// appendSOCKSAddr appends the address in the format that is used in SOCKS requests and UDP datagrams.
+func appendSOCKSAddr(b []byte, address string) ([]byte, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	port, err := strconv.ParseUint(portStr, 10, 16)
+	if err != nil {
+		return nil, err
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		if ip4 := ip.To4(); ip4 != nil {
+			b = append(b, socksAddrIPv4)
+			b = append(b, ip4...)
+		} else {
+			b = append(b, socksAddrIPv6)
+			b = append(b, ip.To16()...)
+		}
+	} else {
+		if len(host) > 255 {
+			return nil, errors.New("socks: host name is too long")
+		}
+		b = append(b, socksAddrDomain, byte(len(host)))
+		b = append(b, host...)
+	}
+	var portBytes [2]byte
+	binary.BigEndian.PutUint16(portBytes[:], uint16(port))
+	return append(b, portBytes[:]...), nil
+}
+
+func readSOCKSAddr(r io.Reader) (*net.UDPAddr, error) {
+	var atyp [1]byte
+	_, err := io.ReadFull(r, atyp[:])
+	if err != nil {
+		return nil, err
+	}
+	var ip net.IP
+	switch atyp[0] {
+	case socksAddrIPv4:
+		ip = make(net.IP, net.IPv4len)
+		_, err = io.ReadFull(r, ip)
+	case socksAddrIPv6:
+		ip = make(net.IP, net.IPv6len)
+		_, err = io.ReadFull(r, ip)
+	case socksAddrDomain:
+		var l [1]byte
+		_, err = io.ReadFull(r, l[:])
+		if err != nil {
+			return nil, err
+		}
+		// Domain names are not resolved. Bound address is only used for UDP relay and
+		// it must be an IP address in practice.
+		_, err = io.ReadFull(r, make([]byte, l[0]))
+	default:
+		return nil, fmt.Errorf("socks: unknown address type: %d", atyp[0])
+	}
+	if err != nil {
+		return nil, err
+	}
+	var port [2]byte
+	_, err = io.ReadFull(r, port[:])
+	if err != nil {
+		return nil, err
+	}
+	return &net.UDPAddr{IP: ip, Port: int(binary.BigEndian.Uint16(port[:]))}, nil
+}
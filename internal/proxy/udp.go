@@ -0,0 +1,134 @@
+package proxy
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net"
+	"time"
+)
+
+// ListenPacket returns a net.PacketConn that relays UDP packets through the SOCKS5 proxy with UDP ASSOCIATE command.
+// Only UDP addresses are accepted by the WriteTo method of the returned connection.
+func (p *Proxy) ListenPacket(ctx context.Context) (net.PacketConn, error) {
+	if !p.SupportsUDP() {
+		return nil, p.opError("udp", p.url.Host, errors.New("proxy does not support UDP: "+p.url.Scheme))
+	}
+	ctrl, err := p.forward.DialContext(ctx, "tcp", p.url.Host)
+	if err != nil {
+		return nil, err
+	}
+	var relay *net.UDPAddr
+	err = p.handshake(ctx, ctrl, func() error {
+		var err2 error
+		relay, err2 = p.socksRequest(ctrl, socksCmdUDPAssociate, "0.0.0.0:0")
+		return err2
+	})
+	if err != nil {
+		ctrl.Close()
+		return nil, p.opError("udp", p.url.Host, err)
+	}
+	if relay.IP == nil || relay.IP.IsUnspecified() {
+		// Server wants us to send packets to the same address that we are connected to.
+		relay.IP = ctrl.RemoteAddr().(*net.TCPAddr).IP
+	}
+	// Send packets from the same address that the control connection uses,
+	// so the relay is reached through the same interface as the proxy server.
+	var laddr *net.UDPAddr
+	if tcpAddr, ok := ctrl.LocalAddr().(*net.TCPAddr); ok && !tcpAddr.IP.IsLoopback() {
+		laddr = &net.UDPAddr{IP: tcpAddr.IP}
+	}
+	conn, err := net.ListenUDP("udp", laddr)
+	if err != nil {
+		ctrl.Close()
+		return nil, err
+	}
+	pc := &packetConn{
+		conn:  conn,
+		ctrl:  ctrl,
+		relay: relay,
+	}
+	// UDP association terminates when the TCP connection is closed.
+	go pc.watchControlConn()
+	return pc, nil
+}
+
+type packetConn struct {
+	conn  *net.UDPConn
+	ctrl  net.Conn
+	relay *net.UDPAddr
+}
+
+var _ net.PacketConn = (*packetConn)(nil)
+
+func (c *packetConn) watchControlConn() {
+	_, _ = io.Copy(io.Discard, c.ctrl)
+	c.conn.Close()
+}
+
+// ReadFrom reads a datagram sent from the relay and strips the SOCKS header.
+// Returned address is the address of the original sender.
+func (c *packetConn) ReadFrom(b []byte) (int, net.Addr, error) {
+	buf := make([]byte, len(b)+262)
+	for {
+		n, from, err := c.conn.ReadFromUDP(buf)
+		if err != nil {
+			return 0, nil, err
+		}
+		if !from.IP.Equal(c.relay.IP) || from.Port != c.relay.Port {
+			continue
+		}
+		// RSV(2) + FRAG(1) + ADDR
+		if n < 3 || buf[2] != 0 {
+			// Fragmented datagrams are not supported.
+			continue
+		}
+		r := bytes.NewReader(buf[3:n])
+		addr, err := readSOCKSAddr(r)
+		if err != nil {
+			continue
+		}
+		m := copy(b, buf[n-r.Len():n])
+		return m, addr, nil
+	}
+}
+
+// WriteTo prepends the SOCKS header to the datagram and sends it to the relay.
+func (c *packetConn) WriteTo(b []byte, addr net.Addr) (int, error) {
+	uaddr, ok := addr.(*net.UDPAddr)
+	if !ok {
+		return 0, errors.New("invalid address type")
+	}
+	buf, err := appendSOCKSAddr([]byte{0, 0, 0}, uaddr.String())
+	if err != nil {
+		return 0, err
+	}
+	buf = append(buf, b...)
+	_, err = c.conn.WriteToUDP(buf, c.relay)
+	if err != nil {
+		return 0, err
+	}
+	return len(b), nil
+}
+
+func (c *packetConn) Close() error {
+	c.ctrl.Close()
+	return c.conn.Close()
+}
+
+func (c *packetConn) LocalAddr() net.Addr {
+	return c.conn.LocalAddr()
+}
+
+func (c *packetConn) SetDeadline(t time.Time) error {
+	return c.conn.SetDeadline(t)
+}
+
+func (c *packetConn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *packetConn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
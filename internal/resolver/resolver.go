@@ -21,17 +21,10 @@ var (
 
 // Resolve `hostport` to an IPv4 address.
 func Resolve(ctx context.Context, hostport string, timeout time.Duration, bl *blocklist.Blocklist) (net.IP, int, error) {
-	host, portStr, err := net.SplitHostPort(hostport)
+	host, port, err := splitHostPort(hostport)
 	if err != nil {
 		return nil, 0, err
 	}
-	port, err := strconv.Atoi(portStr)
-	if err != nil {
-		return nil, 0, err
-	}
-	if port <= 0 || port > 65535 {
-		return nil, 0, ErrInvalidPort
-	}
 	ip := net.ParseIP(host)
 	if ip == nil {
 		ip, err = ResolveIPv4(ctx, timeout, host)
@@ -49,6 +42,41 @@ func Resolve(ctx context.Context, hostport string, timeout time.Duration, bl *bl
 	return i4, port, nil
 }
 
+// Check validates `hostport` like Resolve does but without resolving the host name,
+// so the name can be resolved by a proxy server. Only IP addresses are checked against the blocklist.
+func Check(hostport string, bl *blocklist.Blocklist) error {
+	host, _, err := splitHostPort(hostport)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return nil
+	}
+	if ip.To4() == nil {
+		return ErrNotIPv4Address
+	}
+	if bl != nil && bl.Blocked(ip) {
+		return ErrBlocked
+	}
+	return nil
+}
+
+func splitHostPort(hostport string) (string, int, error) {
+	host, portStr, err := net.SplitHostPort(hostport)
+	if err != nil {
+		return "", 0, err
+	}
+	port, err := strconv.Atoi(portStr)
+	if err != nil {
+		return "", 0, err
+	}
+	if port <= 0 || port > 65535 {
+		return "", 0, ErrInvalidPort
+	}
+	return host, port, nil
+}
+
 // ResolveIPv4 resolves `host` to and IPv4 address.
 func ResolveIPv4(ctx context.Context, timeout time.Duration, host string) (net.IP, error) {
 	var cancel func()
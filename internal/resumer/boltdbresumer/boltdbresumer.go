@@ -16,45 +16,55 @@ const LatestVersion = 3
 
 // Keys for the persisten storage.
 var Keys = struct {
-	InfoHash          []byte
-	Port              []byte
-	Name              []byte
-	Trackers          []byte
-	URLList           []byte
-	FixedPeers        []byte
-	Dest              []byte
-	Info              []byte
-	Bitfield          []byte
-	AddedAt           []byte
-	BytesDownloaded   []byte
-	BytesUploaded     []byte
-	BytesWasted       []byte
-	SeededFor         []byte
-	Started           []byte
-	StopAfterDownload []byte
-	StopAfterMetadata []byte
-	CompleteCmdRun    []byte
-	Version           []byte
+	InfoHash           []byte
+	Port               []byte
+	Name               []byte
+	Trackers           []byte
+	URLList            []byte
+	FixedPeers         []byte
+	Dest               []byte
+	Info               []byte
+	Bitfield           []byte
+	AddedAt            []byte
+	BytesDownloaded    []byte
+	BytesUploaded      []byte
+	BytesWasted        []byte
+	SeededFor          []byte
+	Started            []byte
+	StopAfterDownload  []byte
+	StopAfterMetadata  []byte
+	CompleteCmdRun     []byte
+	Encryption         []byte
+	SpeedLimitDownload []byte
+	SpeedLimitUpload   []byte
+	BandwidthPriority  []byte
+	AnnounceKey        []byte
+	Version            []byte
 }{
-	InfoHash:          []byte("info_hash"),
-	Port:              []byte("port"),
-	Name:              []byte("name"),
-	Trackers:          []byte("trackers"),
-	URLList:           []byte("url_list"),
-	FixedPeers:        []byte("fixed_peers"),
-	Dest:              []byte("dest"),
-	Info:              []byte("info"),
-	Bitfield:          []byte("bitfield"),
-	AddedAt:           []byte("added_at"),
-	BytesDownloaded:   []byte("bytes_downloaded"),
-	BytesUploaded:     []byte("bytes_uploaded"),
-	BytesWasted:       []byte("bytes_wasted"),
-	SeededFor:         []byte("seeded_for"),
-	Started:           []byte("started"),
-	StopAfterDownload: []byte("stop_after_download"),
-	StopAfterMetadata: []byte("stop_after_metadata"),
-	CompleteCmdRun:    []byte("complete_cmd_run"),
-	Version:           []byte("version"),
+	InfoHash:           []byte("info_hash"),
+	Port:               []byte("port"),
+	Name:               []byte("name"),
+	Trackers:           []byte("trackers"),
+	URLList:            []byte("url_list"),
+	FixedPeers:         []byte("fixed_peers"),
+	Dest:               []byte("dest"),
+	Info:               []byte("info"),
+	Bitfield:           []byte("bitfield"),
+	AddedAt:            []byte("added_at"),
+	BytesDownloaded:    []byte("bytes_downloaded"),
+	BytesUploaded:      []byte("bytes_uploaded"),
+	BytesWasted:        []byte("bytes_wasted"),
+	SeededFor:          []byte("seeded_for"),
+	Started:            []byte("started"),
+	StopAfterDownload:  []byte("stop_after_download"),
+	StopAfterMetadata:  []byte("stop_after_metadata"),
+	CompleteCmdRun:     []byte("complete_cmd_run"),
+	Encryption:         []byte("encryption"),
+	SpeedLimitDownload: []byte("speed_limit_download"),
+	SpeedLimitUpload:   []byte("speed_limit_upload"),
+	BandwidthPriority:  []byte("bandwidth_priority"),
+	AnnounceKey:        []byte("announce_key"),
+	Version:            []byte("version"),
 }
 
 // Resumer contains methods for saving/loading resume information of a torrent to a BoltDB database.
@@ -119,6 +129,11 @@ func (r *Resumer) Write(torrentID string, spec *Spec) error {
 		_ = b.Put(Keys.StopAfterDownload, []byte(strconv.FormatBool(spec.StopAfterDownload)))
 		_ = b.Put(Keys.StopAfterMetadata, []byte(strconv.FormatBool(spec.StopAfterMetadata)))
 		_ = b.Put(Keys.CompleteCmdRun, []byte(strconv.FormatBool(spec.CompleteCmdRun)))
+		_ = b.Put(Keys.Encryption, []byte(spec.Encryption))
+		_ = b.Put(Keys.SpeedLimitDownload, []byte(strconv.FormatInt(spec.SpeedLimitDownload, 10)))
+		_ = b.Put(Keys.SpeedLimitUpload, []byte(strconv.FormatInt(spec.SpeedLimitUpload, 10)))
+		_ = b.Put(Keys.BandwidthPriority, []byte(strconv.Itoa(spec.BandwidthPriority)))
+		_ = b.Put(Keys.AnnounceKey, []byte(strconv.FormatUint(uint64(spec.AnnounceKey), 10)))
 		_ = b.Put(Keys.Version, []byte(strconv.Itoa(version)))
 		return nil
 	})
@@ -187,6 +202,58 @@ func (r *Resumer) HandleStopAfterMetadata(torrentID string) error {
 	})
 }
 
+// WriteSpeedLimits writes the download and upload speed limits of a torrent.
+func (r *Resumer) WriteSpeedLimits(torrentID string, download, upload int64) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		err := b.Put(Keys.SpeedLimitDownload, []byte(strconv.FormatInt(download, 10)))
+		if err != nil {
+			return err
+		}
+		return b.Put(Keys.SpeedLimitUpload, []byte(strconv.FormatInt(upload, 10)))
+	})
+}
+
+// WriteTrackers writes the tracker tiers of a torrent.
+func (r *Resumer) WriteTrackers(torrentID string, trackers [][]string) error {
+	value, err := json.Marshal(trackers)
+	if err != nil {
+		return err
+	}
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.Trackers, value)
+	})
+}
+
+// WriteBandwidthPriority writes the bandwidth priority of a torrent.
+func (r *Resumer) WriteBandwidthPriority(torrentID string, priority int) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.BandwidthPriority, []byte(strconv.Itoa(priority)))
+	})
+}
+
+// WriteAnnounceKey writes the key that is sent to trackers in announce requests.
+func (r *Resumer) WriteAnnounceKey(torrentID string, key uint32) error {
+	return r.db.Update(func(tx *bbolt.Tx) error {
+		b := tx.Bucket(r.bucket).Bucket([]byte(torrentID))
+		if b == nil {
+			return nil
+		}
+		return b.Put(Keys.AnnounceKey, []byte(strconv.FormatUint(uint64(key), 10)))
+	})
+}
+
 // WriteCompleteCmdRun writes the start status of a torrent.
 func (r *Resumer) WriteCompleteCmdRun(torrentID string) error {
 	return r.db.Update(func(tx *bbolt.Tx) error {
@@ -359,6 +426,45 @@ func (r *Resumer) Read(torrentID string) (spec *Spec, err error) {
 			}
 		}
 
+		value = b.Get(Keys.Encryption)
+		if value != nil {
+			spec.Encryption = string(value)
+		}
+
+		value = b.Get(Keys.SpeedLimitDownload)
+		if value != nil {
+			spec.SpeedLimitDownload, err = strconv.ParseInt(string(value), 10, 64)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.SpeedLimitUpload)
+		if value != nil {
+			spec.SpeedLimitUpload, err = strconv.ParseInt(string(value), 10, 64)
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.BandwidthPriority)
+		if value != nil {
+			spec.BandwidthPriority, err = strconv.Atoi(string(value))
+			if err != nil {
+				return err
+			}
+		}
+
+		value = b.Get(Keys.AnnounceKey)
+		if value != nil {
+			var key uint64
+			key, err = strconv.ParseUint(string(value), 10, 32)
+			if err != nil {
+				return err
+			}
+			spec.AnnounceKey = uint32(key)
+		}
+
 		value = b.Get(Keys.Version)
 		if value != nil {
 			spec.Version, err = strconv.Atoi(string(value))
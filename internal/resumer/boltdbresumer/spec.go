@@ -8,41 +8,51 @@ import (
 
 // Spec contains fields for resuming an existing torrent.
 type Spec struct {
-	InfoHash          []byte
-	Port              int
-	Name              string
-	Trackers          [][]string
-	URLList           []string
-	FixedPeers        []string
-	Info              []byte
-	Bitfield          []byte
-	AddedAt           time.Time
-	BytesDownloaded   int64
-	BytesUploaded     int64
-	BytesWasted       int64
-	SeededFor         time.Duration
-	Started           bool
-	StopAfterDownload bool
-	StopAfterMetadata bool
-	CompleteCmdRun    bool
-	Version           int
+	InfoHash           []byte
+	Port               int
+	Name               string
+	Trackers           [][]string
+	URLList            []string
+	FixedPeers         []string
+	Info               []byte
+	Bitfield           []byte
+	AddedAt            time.Time
+	BytesDownloaded    int64
+	BytesUploaded      int64
+	BytesWasted        int64
+	SeededFor          time.Duration
+	Started            bool
+	StopAfterDownload  bool
+	StopAfterMetadata  bool
+	CompleteCmdRun     bool
+	Encryption         string
+	SpeedLimitDownload int64
+	SpeedLimitUpload   int64
+	BandwidthPriority  int
+	AnnounceKey        uint32
+	Version            int
 }
 
 type jsonSpec struct {
-	Port              int
-	Name              string
-	Trackers          [][]string
-	URLList           []string
-	FixedPeers        []string
-	AddedAt           time.Time
-	BytesDownloaded   int64
-	BytesUploaded     int64
-	BytesWasted       int64
-	Started           bool
-	StopAfterDownload bool
-	StopAfterMetadata bool
-	CompleteCmdRun    bool
-	Version           int
+	Port               int
+	Name               string
+	Trackers           [][]string
+	URLList            []string
+	FixedPeers         []string
+	AddedAt            time.Time
+	BytesDownloaded    int64
+	BytesUploaded      int64
+	BytesWasted        int64
+	Started            bool
+	StopAfterDownload  bool
+	StopAfterMetadata  bool
+	CompleteCmdRun     bool
+	Encryption         string
+	SpeedLimitDownload int64
+	SpeedLimitUpload   int64
+	BandwidthPriority  int
+	AnnounceKey        uint32
+	Version            int
 
 	// JSON unsafe types
 	InfoHash  string
@@ -54,20 +64,25 @@ type jsonSpec struct {
 // MarshalJSON converts the Spec to a JSON string.
 func (s Spec) MarshalJSON() ([]byte, error) {
 	j := jsonSpec{
-		Port:              s.Port,
-		Name:              s.Name,
-		Trackers:          s.Trackers,
-		URLList:           s.URLList,
-		FixedPeers:        s.FixedPeers,
-		AddedAt:           s.AddedAt,
-		BytesDownloaded:   s.BytesDownloaded,
-		BytesUploaded:     s.BytesUploaded,
-		BytesWasted:       s.BytesWasted,
-		Started:           s.Started,
-		StopAfterDownload: s.StopAfterDownload,
-		StopAfterMetadata: s.StopAfterMetadata,
-		CompleteCmdRun:    s.CompleteCmdRun,
-		Version:           s.Version,
+		Port:               s.Port,
+		Name:               s.Name,
+		Trackers:           s.Trackers,
+		URLList:            s.URLList,
+		FixedPeers:         s.FixedPeers,
+		AddedAt:            s.AddedAt,
+		BytesDownloaded:    s.BytesDownloaded,
+		BytesUploaded:      s.BytesUploaded,
+		BytesWasted:        s.BytesWasted,
+		Started:            s.Started,
+		StopAfterDownload:  s.StopAfterDownload,
+		StopAfterMetadata:  s.StopAfterMetadata,
+		CompleteCmdRun:     s.CompleteCmdRun,
+		Encryption:         s.Encryption,
+		SpeedLimitDownload: s.SpeedLimitDownload,
+		SpeedLimitUpload:   s.SpeedLimitUpload,
+		BandwidthPriority:  s.BandwidthPriority,
+		AnnounceKey:        s.AnnounceKey,
+		Version:            s.Version,
 
 		InfoHash:  base64.StdEncoding.EncodeToString(s.InfoHash),
 		Info:      base64.StdEncoding.EncodeToString(s.Info),
@@ -110,6 +125,11 @@ func (s *Spec) UnmarshalJSON(b []byte) error {
 	s.StopAfterDownload = j.StopAfterDownload
 	s.StopAfterMetadata = j.StopAfterMetadata
 	s.CompleteCmdRun = j.CompleteCmdRun
+	s.Encryption = j.Encryption
+	s.SpeedLimitDownload = j.SpeedLimitDownload
+	s.SpeedLimitUpload = j.SpeedLimitUpload
+	s.BandwidthPriority = j.BandwidthPriority
+	s.AnnounceKey = j.AnnounceKey
 	s.Version = j.Version
 	return nil
 }
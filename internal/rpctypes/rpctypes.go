@@ -25,6 +25,7 @@ type Peer struct {
 	Snubbed            bool
 	EncryptedHandshake bool
 	EncryptedStream    bool
+	UploadOnly         bool
 	DownloadSpeed      int
 	UploadSpeed        int
 }
@@ -48,6 +49,27 @@ type Tracker struct {
 	ErrorInternal string
 	LastAnnounce  Time
 	NextAnnounce  Time
+	Completed     int
+	LastScrape    Time
+	ScrapeError   string
+	Peers         int
+	PeersTotal    int
+	Health        int
+}
+
+// TrackerAnnounce is the result of an announce request in the history of a tracker.
+type TrackerAnnounce struct {
+	// URL of the tracker in the torrent.
+	Tracker string
+	// URL that the request is made. Differs from Tracker if another tracker in the same tier is announced.
+	URL   string
+	Time  Time
+	Event string
+	Peers int
+	// Response time in milliseconds.
+	Latency   int64
+	Error     string
+	ErrorType string
 }
 
 // SessionStats contains statistics about a Session.
@@ -57,6 +79,16 @@ type SessionStats struct {
 	Peers          int
 	PortsAvailable int
 
+	PeerConnections      int
+	PeerConnectionsLimit int
+	HalfOpenDials        int
+	HalfOpenDialsLimit   int
+
+	IncomingRejectedPerIP int64
+	IncomingRateLimited   int64
+	IncomingPenalized     int64
+	PenalizedIPs          int
+
 	BlockListRules   int
 	BlockListRecency int
 
@@ -81,10 +113,31 @@ type SessionStats struct {
 	SpeedRead     int
 	SpeedWrite    int
 
+	SpeedLimitDownload int64
+	SpeedLimitUpload   int64
+	SpeedLimitProfile  string
+	TurtleMode         bool
+
 	BytesDownloaded int64
 	BytesUploaded   int64
 	BytesRead       int64
 	BytesWritten    int64
+
+	BytesDownloadedToday     int64
+	BytesUploadedToday       int64
+	BytesDownloadedThisMonth int64
+	BytesUploadedThisMonth   int64
+	DailyTrafficQuota        int64
+	MonthlyTrafficQuota      int64
+	UploadsStoppedByQuota    bool
+	DownloadsStoppedByQuota  bool
+
+	DHTNodes           int
+	DHTGoodNodes       int
+	DHTBadNodes        int
+	DHTQueriesSent     int64
+	DHTQueriesReceived int64
+	DHTPeersFound      int64
 }
 
 // Stats contains statistics about a Torrent.
@@ -145,7 +198,12 @@ type Stats struct {
 		Download int
 		Upload   int
 	}
-	ETA int
+	SpeedLimit struct {
+		Download int64
+		Upload   int64
+	}
+	BandwidthPriority int
+	ETA               int
 }
 
 // GetMagnetRequest contains request arguments for Session.GetMagnet method.
@@ -183,6 +241,9 @@ type AddTorrentOptions struct {
 	Stopped           bool
 	StopAfterDownload bool
 	StopAfterMetadata bool
+	Encryption        string
+	BandwidthPriority int
+	NoDefaultTrackers bool
 }
 
 // AddTorrentRequest contains request arguments for Session.AddTorrent method.
@@ -253,6 +314,16 @@ type GetTorrentTrackersResponse struct {
 	Trackers []Tracker
 }
 
+// GetTorrentTrackerHistoryRequest contains request arguments for Session.GetTorrentTrackerHistory method.
+type GetTorrentTrackerHistoryRequest struct {
+	ID string
+}
+
+// GetTorrentTrackerHistoryResponse contains response arguments for Session.GetTorrentTrackerHistory method.
+type GetTorrentTrackerHistoryResponse struct {
+	History []TrackerAnnounce
+}
+
 // GetTorrentPeersRequest contains request arguments for Session.GetTorrentPeers method.
 type GetTorrentPeersRequest struct {
 	ID string
@@ -329,6 +400,15 @@ type AddPeerRequest struct {
 type AddPeerResponse struct {
 }
 
+// AddDHTNodeRequest contains request arguments for Session.AddDHTNode method.
+type AddDHTNodeRequest struct {
+	Addr string
+}
+
+// AddDHTNodeResponse contains response arguments for Session.AddDHTNode method.
+type AddDHTNodeResponse struct {
+}
+
 // AddTrackerRequest contains request arguments for Session.AddTracker method.
 type AddTrackerRequest struct {
 	ID  string
@@ -339,6 +419,67 @@ type AddTrackerRequest struct {
 type AddTrackerResponse struct {
 }
 
+// RemoveTrackerRequest contains request arguments for Session.RemoveTracker method.
+type RemoveTrackerRequest struct {
+	ID  string
+	URL string
+}
+
+// RemoveTrackerResponse contains response arguments for Session.RemoveTracker method.
+type RemoveTrackerResponse struct {
+}
+
+// ReplaceTrackerRequest contains request arguments for Session.ReplaceTracker method.
+type ReplaceTrackerRequest struct {
+	ID     string
+	OldURL string
+	NewURL string
+}
+
+// ReplaceTrackerResponse contains response arguments for Session.ReplaceTracker method.
+type ReplaceTrackerResponse struct {
+}
+
+// MoveTrackerTierRequest contains request arguments for Session.MoveTrackerTier method.
+type MoveTrackerTierRequest struct {
+	ID   string
+	From int
+	To   int
+}
+
+// MoveTrackerTierResponse contains response arguments for Session.MoveTrackerTier method.
+type MoveTrackerTierResponse struct {
+}
+
+// GetTorrentTrackerTiersRequest contains request arguments for Session.GetTorrentTrackerTiers method.
+type GetTorrentTrackerTiersRequest struct {
+	ID string
+}
+
+// GetTorrentTrackerTiersResponse contains response arguments for Session.GetTorrentTrackerTiers method.
+type GetTorrentTrackerTiersResponse struct {
+	Tiers [][]string
+}
+
+// ReplaceTrackerURLsRequest contains request arguments for Session.ReplaceTrackerURLs method.
+type ReplaceTrackerURLsRequest struct {
+	Pattern     string
+	Replacement string
+	DryRun      bool
+}
+
+// ReplaceTrackerURLsResponse contains response arguments for Session.ReplaceTrackerURLs method.
+type ReplaceTrackerURLsResponse struct {
+	Changes []TrackerURLChange
+}
+
+// TrackerURLChange is a tracker URL of a torrent that is rewritten by Session.ReplaceTrackerURLs method.
+type TrackerURLChange struct {
+	ID     string
+	OldURL string
+	NewURL string
+}
+
 // StartAllTorrentsRequest contains request arguments for Session.StartAllTorrents method.
 type StartAllTorrentsRequest struct {
 }
@@ -354,3 +495,43 @@ type StopAllTorrentsRequest struct {
 // StopAllTorrentsResponse contains response arguments for Session.StopAllTorrents method.
 type StopAllTorrentsResponse struct {
 }
+
+// SetSpeedLimitsRequest contains request arguments for Session.SetSpeedLimits method.
+type SetSpeedLimitsRequest struct {
+	Download int64
+	Upload   int64
+}
+
+// SetSpeedLimitsResponse contains response arguments for Session.SetSpeedLimits method.
+type SetSpeedLimitsResponse struct {
+}
+
+// SetTurtleModeRequest contains request arguments for Session.SetTurtleMode method.
+type SetTurtleModeRequest struct {
+	Enabled bool
+}
+
+// SetTurtleModeResponse contains response arguments for Session.SetTurtleMode method.
+type SetTurtleModeResponse struct {
+}
+
+// SetTorrentSpeedLimitsRequest contains request arguments for Session.SetTorrentSpeedLimits method.
+type SetTorrentSpeedLimitsRequest struct {
+	ID       string
+	Download int64
+	Upload   int64
+}
+
+// SetTorrentSpeedLimitsResponse contains response arguments for Session.SetTorrentSpeedLimits method.
+type SetTorrentSpeedLimitsResponse struct {
+}
+
+// SetTorrentBandwidthPriorityRequest contains request arguments for Session.SetTorrentBandwidthPriority method.
+type SetTorrentBandwidthPriorityRequest struct {
+	ID       string
+	Priority int
+}
+
+// SetTorrentBandwidthPriorityResponse contains response arguments for Session.SetTorrentBandwidthPriority method.
+type SetTorrentBandwidthPriorityResponse struct {
+}
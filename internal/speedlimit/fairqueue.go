@@ -0,0 +1,120 @@
+package speedlimit
+
+import (
+	"sort"
+	"time"
+)
+
+// Backlog below this number of bytes is considered empty. Prevents float rounding errors from keeping flows in queue.
+const minBacklog = 1e-6
+
+// fairQueue divides a rate between flows in proportion to their weights (weighted fair queuing).
+// It simulates an ideal fluid scheduler in which every flow that has a backlog is served at the same time,
+// with a rate proportional to its weight. Unused share of idle flows is distributed to the others.
+// The time a transfer is finished in the simulation is the time that the caller must wait.
+type fairQueue struct {
+	// Bytes per second
+	rate float64
+	// Bytes that can be transferred without waiting. Accumulated while the queue is idle, up to one second worth of rate.
+	credit float64
+	last   time.Time
+	// Flows that have a backlog
+	flows map[*Limiter]*flow
+}
+
+type flow struct {
+	weight  float64
+	backlog float64
+}
+
+func newFairQueue(rate int64, now time.Time) *fairQueue {
+	return &fairQueue{
+		rate:   float64(rate),
+		credit: float64(rate),
+		last:   now,
+		flows:  make(map[*Limiter]*flow),
+	}
+}
+
+// Take n bytes for the flow identified by key. Returns the duration to wait before transferring the bytes.
+func (q *fairQueue) Take(key *Limiter, weight int, n int64, now time.Time) time.Duration {
+	q.advance(now)
+	b := float64(n)
+	if q.credit > 0 {
+		c := q.credit
+		if c > b {
+			c = b
+		}
+		q.credit -= c
+		b -= c
+	}
+	if b < minBacklog {
+		return 0
+	}
+	f, ok := q.flows[key]
+	if !ok {
+		f = &flow{}
+		q.flows[key] = f
+	}
+	f.weight = float64(weight)
+	f.backlog += b
+	return time.Duration(q.finishTime(f) * float64(time.Second))
+}
+
+// advance serves the backlogs of flows until now.
+func (q *fairQueue) advance(now time.Time) {
+	dt := now.Sub(q.last).Seconds()
+	if dt <= 0 {
+		return
+	}
+	q.last = now
+	for dt > 0 && len(q.flows) > 0 {
+		var total float64
+		first := -1.0
+		for _, f := range q.flows {
+			total += f.weight
+			if x := f.backlog / f.weight; first < 0 || x < first {
+				first = x
+			}
+		}
+		// Time until the first flow has no backlog.
+		step := first * total / q.rate
+		if step > dt {
+			step = dt
+		}
+		for key, f := range q.flows {
+			f.backlog -= step * q.rate * f.weight / total
+			if f.backlog < minBacklog {
+				delete(q.flows, key)
+			}
+		}
+		dt -= step
+	}
+	q.credit += dt * q.rate
+	if q.credit > q.rate {
+		q.credit = q.rate
+	}
+}
+
+// finishTime returns the number of seconds until the backlog of f is served if no more bytes are taken.
+// Flows finish in the order of their backlog to weight ratio.
+func (q *fairQueue) finishTime(f *flow) float64 {
+	flows := make([]*flow, 0, len(q.flows))
+	var total float64
+	for _, fl := range q.flows {
+		flows = append(flows, fl)
+		total += fl.weight
+	}
+	sort.Slice(flows, func(i, j int) bool { return flows[i].backlog/flows[i].weight < flows[j].backlog/flows[j].weight })
+	var t, prev float64
+	for _, fl := range flows {
+		x := fl.backlog / fl.weight
+		t += (x - prev) * total / q.rate
+		if fl == f {
+			break
+		}
+		total -= fl.weight
+		prev = x
+	}
+	return t
+}
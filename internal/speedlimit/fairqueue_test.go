@@ -0,0 +1,45 @@
+package speedlimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFairQueue(t *testing.T) {
+	now := time.Now()
+	q := newFairQueue(1000, now)
+	high, low := &Limiter{}, &Limiter{}
+
+	// Initial burst is allowed.
+	assert.Equal(t, time.Duration(0), q.Take(high, 3, 1000, now))
+
+	// High gets 3/4 of the rate while both flows have backlog.
+	assert.Equal(t, time.Second, q.Take(low, 1, 1000, now).Round(time.Millisecond))
+	assert.Equal(t, 1333*time.Millisecond, q.Take(high, 3, 1000, now).Truncate(time.Millisecond))
+
+	// After high is finished, low gets the whole rate.
+	now = now.Add(1500 * time.Millisecond)
+	q.advance(now)
+	assert.Len(t, q.flows, 1)
+	assert.InDelta(t, 500, q.flows[low].backlog, 1)
+
+	// Credit is accumulated while idle.
+	now = now.Add(time.Second)
+	assert.Equal(t, time.Duration(0), q.Take(low, 1, 400, now))
+	assert.Equal(t, 300*time.Millisecond, q.Take(low, 1, 400, now).Round(time.Millisecond))
+}
+
+func TestLimiterWeight(t *testing.T) {
+	parent := New(1000, nil)
+	high, low := New(0, parent), New(0, parent)
+	high.SetWeight(3)
+	assert.Equal(t, 3, high.Weight())
+
+	assert.Equal(t, time.Duration(0), high.Take(1000))
+	dl := low.Take(1000)
+	dh := high.Take(1000)
+	assert.InDelta(t, float64(time.Second), float64(dl), float64(100*time.Millisecond))
+	assert.InDelta(t, float64(1333*time.Millisecond), float64(dh), float64(100*time.Millisecond))
+}
@@ -0,0 +1,87 @@
+package speedlimit
+
+import (
+	"errors"
+	"strings"
+	"time"
+)
+
+// TimeRange is a recurring time range in a week.
+type TimeRange struct {
+	// Days that the range starts. Empty means every day.
+	Days []time.Weekday
+	// Start and End are offsets from midnight.
+	// If End is before Start, the range continues until End on the next day.
+	// If End is equal to Start, the range covers the whole day.
+	Start, End time.Duration
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// ParseTimeRange parses a TimeRange from day names (e.g. "mon" or "monday") and times of day in "15:04" format.
+func ParseTimeRange(days []string, start, end string) (TimeRange, error) {
+	var r TimeRange
+	for _, s := range days {
+		s = strings.ToLower(s)
+		if len(s) > 3 {
+			s = s[:3]
+		}
+		d, ok := weekdays[s]
+		if !ok {
+			return r, errors.New("invalid day: " + s)
+		}
+		r.Days = append(r.Days, d)
+	}
+	var err error
+	r.Start, err = parseClock(start)
+	if err != nil {
+		return r, err
+	}
+	r.End, err = parseClock(end)
+	return r, err
+}
+
+func parseClock(s string) (time.Duration, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, errors.New("invalid time of day: " + s)
+	}
+	return time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute, nil
+}
+
+// Contains returns true if t is in the range. Day and time of day are taken from the location of t.
+func (r TimeRange) Contains(t time.Time) bool {
+	clock := time.Duration(t.Hour())*time.Hour + time.Duration(t.Minute())*time.Minute + time.Duration(t.Second())*time.Second
+	switch {
+	case r.Start == r.End:
+		return r.hasDay(t.Weekday())
+	case r.Start < r.End:
+		return r.hasDay(t.Weekday()) && clock >= r.Start && clock < r.End
+	default:
+		// Range wraps around midnight.
+		if clock >= r.Start {
+			return r.hasDay(t.Weekday())
+		}
+		return clock < r.End && r.hasDay((t.Weekday()+6)%7)
+	}
+}
+
+func (r TimeRange) hasDay(d time.Weekday) bool {
+	if len(r.Days) == 0 {
+		return true
+	}
+	for _, day := range r.Days {
+		if day == d {
+			return true
+		}
+	}
+	return false
+}
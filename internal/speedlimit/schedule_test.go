@@ -0,0 +1,42 @@
+package speedlimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTimeRange(t *testing.T) {
+	// 2021-03-01 is a Monday.
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2021, 3, day, hour, min, 0, 0, time.UTC)
+	}
+
+	r, err := ParseTimeRange([]string{"Monday", "fri"}, "09:00", "17:30")
+	assert.NoError(t, err)
+	assert.False(t, r.Contains(at(1, 8, 59)))
+	assert.True(t, r.Contains(at(1, 9, 0)))
+	assert.True(t, r.Contains(at(1, 17, 29)))
+	assert.False(t, r.Contains(at(1, 17, 30)))
+	assert.False(t, r.Contains(at(2, 12, 0)))
+	assert.True(t, r.Contains(at(5, 12, 0)))
+
+	// Range that continues on the next day belongs to the start day.
+	r, err = ParseTimeRange([]string{"sun"}, "22:00", "06:00")
+	assert.NoError(t, err)
+	assert.True(t, r.Contains(at(7, 23, 0)))
+	assert.True(t, r.Contains(at(8, 5, 59)))
+	assert.False(t, r.Contains(at(8, 6, 0)))
+	assert.False(t, r.Contains(at(7, 5, 0)))
+
+	// Equal start and end means the whole day.
+	r, err = ParseTimeRange(nil, "00:00", "00:00")
+	assert.NoError(t, err)
+	assert.True(t, r.Contains(at(3, 15, 0)))
+
+	_, err = ParseTimeRange([]string{"xyz"}, "00:00", "01:00")
+	assert.Error(t, err)
+	_, err = ParseTimeRange(nil, "25:00", "01:00")
+	assert.Error(t, err)
+}
@@ -0,0 +1,127 @@
+// Package speedlimit provides token bucket rate limiters whose rate can be changed at runtime.
+// Limiters can be layered, so a transfer is limited by both of the torrent and the session limits.
+// Children of a Limiter share its rate in proportion to their weights.
+package speedlimit
+
+import (
+	"sync"
+	"time"
+
+	"github.com/juju/ratelimit"
+)
+
+// Limiter limits the number of bytes transferred per second.
+// Methods are safe to call on a nil Limiter. A nil Limiter does not limit.
+type Limiter struct {
+	parent *Limiter
+
+	m      sync.Mutex
+	rate   int64
+	weight int
+	bucket *ratelimit.Bucket
+	// Shares the rate between children.
+	queue *fairQueue
+}
+
+// New returns a new Limiter that allows rate bytes per second. Zero rate means unlimited.
+// If parent is not nil, bytes taken from the Limiter are also taken from the parent.
+// Weight of the new Limiter is 1.
+func New(rate int64, parent *Limiter) *Limiter {
+	l := &Limiter{parent: parent, weight: 1}
+	l.SetRate(rate)
+	return l
+}
+
+// SetRate changes the rate of the Limiter in bytes per second. Zero rate means unlimited.
+// Setting the same rate again does nothing, so the tokens and the queued shares of the children are preserved.
+func (l *Limiter) SetRate(rate int64) {
+	if l == nil {
+		return
+	}
+	if rate < 0 {
+		rate = 0
+	}
+	l.m.Lock()
+	defer l.m.Unlock()
+	if rate == l.rate {
+		return
+	}
+	l.rate = rate
+	l.bucket = nil
+	l.queue = nil
+	if rate > 0 {
+		l.bucket = ratelimit.NewBucketWithRate(float64(rate), rate)
+		l.queue = newFairQueue(rate, time.Now())
+	}
+}
+
+// Rate returns the rate of the Limiter in bytes per second. Limits of parents are not included.
+func (l *Limiter) Rate() int64 {
+	if l == nil {
+		return 0
+	}
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.rate
+}
+
+// SetWeight changes the share of the Limiter in the rate of its parent.
+// When the parent limit is reached, a Limiter with weight 2 gets twice the bandwidth of a Limiter with weight 1.
+func (l *Limiter) SetWeight(weight int) {
+	if l == nil {
+		return
+	}
+	if weight < 1 {
+		weight = 1
+	}
+	l.m.Lock()
+	l.weight = weight
+	l.m.Unlock()
+}
+
+// Weight returns the share of the Limiter in the rate of its parent.
+func (l *Limiter) Weight() int {
+	if l == nil {
+		return 0
+	}
+	l.m.Lock()
+	defer l.m.Unlock()
+	return l.weight
+}
+
+// Take n bytes from the Limiter and its parents.
+// Returns the duration that the caller must wait before transferring the bytes.
+func (l *Limiter) Take(n int64) time.Duration {
+	if l == nil {
+		return 0
+	}
+	var d time.Duration
+	l.m.Lock()
+	if l.bucket != nil {
+		d = l.bucket.Take(n)
+	}
+	weight := l.weight
+	l.m.Unlock()
+	if pd := l.parent.takeChild(l, weight, n); pd > d {
+		d = pd
+	}
+	return d
+}
+
+// takeChild takes n bytes for the child from the share of the child.
+func (l *Limiter) takeChild(child *Limiter, weight int, n int64) time.Duration {
+	if l == nil {
+		return 0
+	}
+	var d time.Duration
+	l.m.Lock()
+	if l.queue != nil {
+		d = l.queue.Take(child, weight, n, time.Now())
+	}
+	ownWeight := l.weight
+	l.m.Unlock()
+	if pd := l.parent.takeChild(l, ownWeight, n); pd > d {
+		d = pd
+	}
+	return d
+}
@@ -0,0 +1,37 @@
+package speedlimit
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimiter(t *testing.T) {
+	var nilLimiter *Limiter
+	assert.Equal(t, time.Duration(0), nilLimiter.Take(1000))
+	nilLimiter.SetRate(1000)
+	nilLimiter.SetWeight(2)
+	assert.Equal(t, int64(0), nilLimiter.Rate())
+
+	parent := New(0, nil)
+	l := New(1000, parent)
+	assert.Equal(t, int64(1000), l.Rate())
+
+	// Initial burst is allowed.
+	assert.Equal(t, time.Duration(0), l.Take(1000))
+	assert.InDelta(t, float64(time.Second), float64(l.Take(1000)), float64(100*time.Millisecond))
+
+	// Setting the same rate does not refill the bucket.
+	l.SetRate(1000)
+	assert.InDelta(t, float64(2*time.Second), float64(l.Take(1000)), float64(100*time.Millisecond))
+
+	l.SetRate(0)
+	assert.Equal(t, time.Duration(0), l.Take(1000))
+
+	// Parent limit applies to children.
+	parent.SetRate(100)
+	assert.Equal(t, time.Duration(0), l.Take(100))
+	assert.InDelta(t, float64(time.Second), float64(l.Take(100)), float64(100*time.Millisecond))
+	assert.Equal(t, int64(0), l.Rate())
+}
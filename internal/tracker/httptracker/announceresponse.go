@@ -16,3 +16,14 @@ type announceResponse struct {
 	Peers          bencode.RawMessage `bencode:"peers"`
 	ExternalIP     []byte             `bencode:"external ip"`
 }
+
+type scrapeResponse struct {
+	FailureReason string                `bencode:"failure reason"`
+	Files         map[string]scrapeFile `bencode:"files"`
+}
+
+type scrapeFile struct {
+	Complete   int32 `bencode:"complete"`
+	Incomplete int32 `bencode:"incomplete"`
+	Downloaded int32 `bencode:"downloaded"`
+}
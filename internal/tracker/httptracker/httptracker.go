@@ -9,6 +9,7 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -27,18 +28,30 @@ type HTTPTracker struct {
 	trackerID         string
 	userAgent         string
 	maxResponseLength int64
+	settings          Settings
 }
 
 var _ tracker.Tracker = (*HTTPTracker)(nil)
 
+// Settings contains the extra values that are sent to the tracker.
+type Settings struct {
+	// HTTP headers sent in announce and scrape requests.
+	Headers map[string]string
+	// Cookies sent in announce and scrape requests.
+	Cookies map[string]string
+	// Query parameters added to the announce URL, e.g. "supportcrypto", "ip" or "key".
+	Params map[string]string
+}
+
 // New returns a new HTTPTracker.
-func New(rawURL string, u *url.URL, timeout time.Duration, t *http.Transport, userAgent string, maxResponseLength int64) *HTTPTracker {
+func New(rawURL string, u *url.URL, timeout time.Duration, t *http.Transport, userAgent string, maxResponseLength int64, settings Settings) *HTTPTracker {
 	return &HTTPTracker{
 		rawURL:            rawURL,
 		log:               logger.New("tracker " + u.Host),
 		transport:         t,
 		userAgent:         userAgent,
 		maxResponseLength: maxResponseLength,
+		settings:          settings,
 		http: &http.Client{
 			Timeout:   timeout,
 			Transport: t,
@@ -86,42 +99,24 @@ func (t *HTTPTracker) Announce(ctx context.Context, req tracker.AnnounceRequest)
 		sb.WriteString("&trackerid=")
 		sb.WriteString(t.trackerID)
 	}
-	sb.WriteString("&key=")
-	sb.WriteString(hex.EncodeToString(req.Torrent.PeerID[16:20]))
-
-	t.log.Debugf("making request to: %q", sb.String())
-
-	httpReq, err := http.NewRequest(http.MethodGet, sb.String(), nil)
-	if err != nil {
-		return nil, err
+	// A key in settings replaces the generated one.
+	if _, ok := t.settings.Params["key"]; !ok {
+		sb.WriteString("&key=")
+		sb.WriteString(fmt.Sprintf("%08x", req.Torrent.Key))
 	}
-	httpReq = httpReq.WithContext(ctx)
-
-	httpReq.Header.Set("User-Agent", t.userAgent)
-
-	doReq := func() (int, http.Header, []byte, error) {
-		resp, err := t.http.Do(httpReq)
-		if err != nil {
-			return 0, nil, nil, err
-		}
-		t.log.Debugf("tracker responded %d with %d bytes body", resp.StatusCode, resp.ContentLength)
-		defer resp.Body.Close()
-		if resp.ContentLength > t.maxResponseLength {
-			return 0, resp.Header, nil, fmt.Errorf("tracker respsonse too large: %d", resp.ContentLength)
-		}
-		r := io.LimitReader(resp.Body, t.maxResponseLength)
-		data, err := io.ReadAll(r)
-		return resp.StatusCode, resp.Header, data, err
+	for _, k := range sortedKeys(t.settings.Params) {
+		sb.WriteString("&")
+		sb.WriteString(url.QueryEscape(k))
+		sb.WriteString("=")
+		sb.WriteString(url.QueryEscape(t.settings.Params[k]))
 	}
 
-	code, header, body, err := doReq()
-	if uerr, ok := err.(*url.Error); ok && uerr.Err == context.Canceled {
-		return nil, context.Canceled
-	}
+	t.log.Debugf("making request to: %q", sb.String())
+
+	code, header, body, err := t.get(ctx, sb.String())
 	if err != nil {
 		return nil, err
 	}
-	t.log.Debugf("read %d bytes from body", len(body))
 
 	var response announceResponse
 	err = bencode.DecodeBytes(body, &response)
@@ -189,6 +184,130 @@ func (t *HTTPTracker) Announce(ctx context.Context, req tracker.AnnounceRequest)
 	}, nil
 }
 
+// Scrape the torrents by doing a GET request to the scrape URL of the tracker.
+// The scrape URL is found by replacing the "announce" in the last path segment of the announce URL with "scrape".
+func (t *HTTPTracker) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]tracker.ScrapeResult, error) {
+	scrapeURL, ok := ScrapeURL(t.rawURL)
+	if !ok {
+		return nil, tracker.ErrScrapeNotSupported
+	}
+	var sb strings.Builder
+	sb.WriteString(scrapeURL)
+	for i, ih := range infoHashes {
+		if i == 0 && !strings.ContainsRune(scrapeURL, '?') {
+			sb.WriteString("?info_hash=")
+		} else {
+			sb.WriteString("&info_hash=")
+		}
+		sb.WriteString(percentEscape(ih))
+	}
+
+	t.log.Debugf("making request to: %q", sb.String())
+
+	code, header, body, err := t.get(ctx, sb.String())
+	if err != nil {
+		return nil, err
+	}
+
+	var response scrapeResponse
+	err = bencode.DecodeBytes(body, &response)
+	if err != nil {
+		if code != 200 {
+			return nil, &StatusError{
+				Code:   code,
+				Header: header,
+				Body:   string(body),
+			}
+		}
+		return nil, tracker.ErrDecode
+	}
+	if response.FailureReason != "" {
+		return nil, &tracker.Error{FailureReason: response.FailureReason}
+	}
+
+	ret := make(map[[20]byte]tracker.ScrapeResult, len(response.Files))
+	for k, f := range response.Files {
+		if len(k) != 20 {
+			continue
+		}
+		var ih [20]byte
+		copy(ih[:], k)
+		ret[ih] = tracker.ScrapeResult{
+			Seeders:   f.Complete,
+			Leechers:  f.Incomplete,
+			Completed: f.Downloaded,
+		}
+	}
+	return ret, nil
+}
+
+// ScrapeURL converts an announce URL to a scrape URL.
+// Returns false if the tracker does not support scraping by convention.
+func ScrapeURL(announceURL string) (string, bool) {
+	u, err := url.Parse(announceURL)
+	if err != nil {
+		return "", false
+	}
+	p := u.EscapedPath()
+	i := strings.LastIndexByte(p, '/')
+	if i < 0 || !strings.HasPrefix(p[i+1:], "announce") {
+		return "", false
+	}
+	u.RawPath = p[:i+1] + "scrape" + strings.TrimPrefix(p[i+1:], "announce")
+	u.Path, err = url.PathUnescape(u.RawPath)
+	if err != nil {
+		return "", false
+	}
+	return u.String(), true
+}
+
+func (t *HTTPTracker) get(ctx context.Context, rawURL string) (code int, header http.Header, body []byte, err error) {
+	httpReq, err := http.NewRequest(http.MethodGet, rawURL, nil)
+	if err != nil {
+		return
+	}
+	httpReq = httpReq.WithContext(ctx)
+
+	httpReq.Header.Set("User-Agent", t.userAgent)
+	for _, k := range sortedKeys(t.settings.Headers) {
+		httpReq.Header.Set(k, t.settings.Headers[k])
+	}
+	for _, k := range sortedKeys(t.settings.Cookies) {
+		httpReq.AddCookie(&http.Cookie{Name: k, Value: t.settings.Cookies[k]})
+	}
+
+	resp, err := t.http.Do(httpReq)
+	if uerr, ok := err.(*url.Error); ok && uerr.Err == context.Canceled {
+		err = context.Canceled
+		return
+	}
+	if err != nil {
+		return
+	}
+	t.log.Debugf("tracker responded %d with %d bytes body", resp.StatusCode, resp.ContentLength)
+	defer resp.Body.Close()
+	if resp.ContentLength > t.maxResponseLength {
+		err = fmt.Errorf("tracker respsonse too large: %d", resp.ContentLength)
+		return
+	}
+	r := io.LimitReader(resp.Body, t.maxResponseLength)
+	body, err = io.ReadAll(r)
+	if err != nil {
+		return
+	}
+	t.log.Debugf("read %d bytes from body", len(body))
+	return resp.StatusCode, resp.Header, body, nil
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
 // percentEscape puts `%` before every byte.
 // Some trackers don't like the output of url.QueryEscape function because it may skip encoding safe characters.
 // This function escapes every byte explicitly.
@@ -3,6 +3,7 @@ package httptracker_test
 import (
 	"context"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
 	"time"
@@ -56,7 +57,7 @@ func TestHTTPTracker(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	trk := httptracker.New(rawURL, u, timeout, new(http.Transport), "Mozilla/5.0", 2*1024*1024)
+	trk := httptracker.New(rawURL, u, timeout, new(http.Transport), "Mozilla/5.0", 2*1024*1024, httptracker.Settings{})
 
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
@@ -98,4 +99,84 @@ func TestHTTPTracker(t *testing.T) {
 		t.Log(addr.String())
 		t.FailNow()
 	}
+
+	scrape, err := trk.Scrape(ctx, [][20]byte{[20]byte{6}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := scrape[[20]byte{6}]; r.Seeders != 1 || r.Leechers != 1 {
+		t.Logf("%#v", scrape)
+		t.FailNow()
+	}
+}
+
+func TestScrapeURL(t *testing.T) {
+	cases := []struct {
+		announce, scrape string
+	}{
+		{"http://example.com/announce", "http://example.com/scrape"},
+		{"http://example.com/x/announce.php?passkey=abc", "http://example.com/x/scrape.php?passkey=abc"},
+		{"http://example.com/a", ""},
+		{"http://example.com/announce/x", ""},
+	}
+	for _, c := range cases {
+		s, ok := httptracker.ScrapeURL(c.announce)
+		if s != c.scrape || ok != (c.scrape != "") {
+			t.Errorf("%s: got %q, %t", c.announce, s, ok)
+		}
+	}
+}
+
+func TestSettings(t *testing.T) {
+	var query url.Values
+	var header http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		header = r.Header
+		_, _ = w.Write([]byte("d8:intervali60e5:peers0:e"))
+	}))
+	defer srv.Close()
+
+	rawURL := srv.URL + "/announce?passkey=abc"
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	settings := httptracker.Settings{
+		Headers: map[string]string{"X-Api-Key": "secret"},
+		Cookies: map[string]string{"uid": "42"},
+		Params:  map[string]string{"supportcrypto": "1"},
+	}
+	trk := httptracker.New(rawURL, u, timeout, new(http.Transport), "Mozilla/5.0", 2*1024*1024, settings)
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	req := tracker.AnnounceRequest{
+		Torrent: tracker.Torrent{
+			InfoHash: [20]byte{6},
+			PeerID:   [20]byte{1},
+			Key:      0xdeadbeef,
+			Port:     1111,
+		},
+	}
+	_, err = trk.Announce(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if v := query.Get("passkey"); v != "abc" {
+		t.Errorf("passkey: %q", v)
+	}
+	if v := query.Get("supportcrypto"); v != "1" {
+		t.Errorf("param: %q", v)
+	}
+	if v := query.Get("key"); v != "deadbeef" {
+		t.Errorf("key: %q", v)
+	}
+	if v := header.Get("X-Api-Key"); v != "secret" {
+		t.Errorf("header: %q", v)
+	}
+	if v := header.Get("Cookie"); v != "uid=42" {
+		t.Errorf("cookie: %q", v)
+	}
 }
@@ -3,13 +3,21 @@ package tracker
 import (
 	"context"
 	"math/rand"
+	"sync"
 	"sync/atomic"
 )
 
+// NeutralHealth is the health score of a tracker that has not been announced to yet.
+// Health scores are between 0 and 100.
+const NeutralHealth = 50
+
 // Tier implements the Tracker interface and contains multiple Trackers which tries to announce to the working Tracker.
 type Tier struct {
 	Trackers []Tracker
 	index    int32
+
+	mHealth sync.Mutex
+	health  map[string]int
 }
 
 var _ Tracker = (*Tier)(nil)
@@ -19,20 +27,54 @@ func NewTier(trackers []Tracker) *Tier {
 	rand.Shuffle(len(trackers), func(i, j int) { trackers[i], trackers[j] = trackers[j], trackers[i] })
 	return &Tier{
 		Trackers: trackers,
+		health:   make(map[string]int),
 	}
 }
 
 // Announce a torrent to the tracker.
-// If annouce fails, the next announce will be made to the next Tracker in the tier.
+// If annouce fails, the next announce will be made to the healthiest of the other Trackers in the tier.
 func (t *Tier) Announce(ctx context.Context, req AnnounceRequest) (*AnnounceResponse, error) {
 	index := t.loadIndex()
 	resp, err := t.Trackers[index].Announce(ctx, req)
 	if err != nil {
-		atomic.CompareAndSwapInt32(&t.index, index, index+1)
+		atomic.CompareAndSwapInt32(&t.index, index, t.nextIndex(index))
 	}
 	return resp, err
 }
 
+// SetHealth sets the health score of the Tracker with the URL.
+// Scores are used for choosing the next Tracker when an announce fails.
+func (t *Tier) SetHealth(url string, score int) {
+	t.mHealth.Lock()
+	t.health[url] = score
+	t.mHealth.Unlock()
+}
+
+// nextIndex returns the index of the Tracker with the highest health score, excluding the current one.
+// Ties are broken in round-robin order.
+func (t *Tier) nextIndex(current int32) int32 {
+	t.mHealth.Lock()
+	defer t.mHealth.Unlock()
+	n := int32(len(t.Trackers))
+	best, bestScore := (current+1)%n, -1
+	for i := int32(1); i < n; i++ {
+		j := (current + i) % n
+		score, ok := t.health[t.Trackers[j].URL()]
+		if !ok {
+			score = NeutralHealth
+		}
+		if score > bestScore {
+			best, bestScore = j, score
+		}
+	}
+	return best
+}
+
+// Scrape torrents from the current Tracker in the Tier.
+func (t *Tier) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]ScrapeResult, error) {
+	return t.Trackers[t.loadIndex()].Scrape(ctx, infoHashes)
+}
+
 // URL returns the current Tracker in the Tier.
 func (t *Tier) URL() string {
 	return t.Trackers[t.loadIndex()].URL()
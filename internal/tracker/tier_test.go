@@ -0,0 +1,49 @@
+package tracker
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+type testTracker struct {
+	url string
+	err error
+}
+
+func (t *testTracker) Announce(ctx context.Context, req AnnounceRequest) (*AnnounceResponse, error) {
+	return &AnnounceResponse{}, t.err
+}
+
+func (t *testTracker) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]ScrapeResult, error) {
+	return nil, ErrScrapeNotSupported
+}
+
+func (t *testTracker) URL() string {
+	return t.url
+}
+
+func TestTierHealth(t *testing.T) {
+	errAnnounce := errors.New("announce error")
+	tier := NewTier([]Tracker{
+		&testTracker{url: "a", err: errAnnounce},
+		&testTracker{url: "b", err: errAnnounce},
+		&testTracker{url: "c"},
+	})
+	tier.SetHealth("a", 10)
+	tier.SetHealth("b", 0)
+	tier.SetHealth("c", 90)
+	for i := 0; i < 2; i++ {
+		_, _ = tier.Announce(context.Background(), AnnounceRequest{})
+	}
+	if tier.URL() != "c" {
+		t.Fatalf("healthiest tracker is not selected: %s", tier.URL())
+	}
+	_, err := tier.Announce(context.Background(), AnnounceRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tier.URL() != "c" {
+		t.Fatalf("working tracker is changed: %s", tier.URL())
+	}
+}
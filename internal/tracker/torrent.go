@@ -8,4 +8,7 @@ type Torrent struct {
 	InfoHash        [20]byte
 	PeerID          [20]byte
 	Port            int
+	// Random value that identifies the client to the tracker when the IP address changes.
+	// It must not change for the same torrent.
+	Key uint32
 }
@@ -15,6 +15,10 @@ type Tracker interface {
 	// Announce should also be called on specific events.
 	Announce(ctx context.Context, req AnnounceRequest) (*AnnounceResponse, error)
 
+	// Scrape returns the swarm sizes of torrents without announcing.
+	// Torrents that are not known by the tracker are not included in the result.
+	Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]ScrapeResult, error)
+
 	// URL of the tracker.
 	URL() string
 }
@@ -36,6 +40,16 @@ type AnnounceResponse struct {
 	Peers          []*net.TCPAddr
 }
 
+// ScrapeResult contains the swarm information of a torrent.
+type ScrapeResult struct {
+	Seeders   int32
+	Leechers  int32
+	Completed int32
+}
+
+// ErrScrapeNotSupported is returned from Tracker.Scrape method if the tracker does not support scraping.
+var ErrScrapeNotSupported = errors.New("tracker does not support scrape")
+
 // ErrDecode is returned from Tracker.Announce method when there is problem with the encoding of response.
 var ErrDecode = errors.New("cannot decode response")
 
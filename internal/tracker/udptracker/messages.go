@@ -20,6 +20,8 @@ type udpRequestHeader struct {
 	udpMessageHeader
 }
 
+func (h *udpRequestHeader) SetConnectionID(id int64) { h.ConnectionID = id }
+
 type connectRequest struct {
 	udpRequestHeader
 }
@@ -52,7 +54,6 @@ type announceRequest struct {
 	Key        uint32
 	NumWant    int32
 	Port       uint16
-	Extensions uint16
 }
 
 type transferAnnounceRequest struct {
@@ -60,9 +61,17 @@ type transferAnnounceRequest struct {
 	urlData string
 }
 
+// Option types in BEP 41.
+const (
+	optionEndOfOptions = 0x0
+	optionURLData      = 0x2
+)
+
+// WriteTo writes the announce request followed by the URL data options in BEP 41.
+// Options must begin right after the 98 bytes of announce request.
 func (r *transferAnnounceRequest) WriteTo(w io.Writer) (int64, error) {
 	// Add 255 extra spece to packet buffer since most UDP tracker addresses contains URL data.
-	b := make([]byte, 0, 98+2+255)
+	b := make([]byte, 0, 98+2+255+1)
 	buf := bytes.NewBuffer(b)
 
 	err := binary.Write(buf, binary.BigEndian, r.announceRequest)
@@ -80,7 +89,7 @@ func (r *transferAnnounceRequest) WriteTo(w io.Writer) (int64, error) {
 			} else {
 				size = remaining
 			}
-			_, err = buf.Write([]byte{0x2, byte(size)})
+			_, err = buf.Write([]byte{optionURLData, byte(size)})
 			if err != nil {
 				return 0, err
 			}
@@ -90,7 +99,34 @@ func (r *transferAnnounceRequest) WriteTo(w io.Writer) (int64, error) {
 			}
 			pos += size
 		}
+		buf.WriteByte(optionEndOfOptions)
 	}
 
 	return buf.WriteTo(w)
 }
+
+// Max number of info hashes in a single scrape request. Limited by the size of a UDP packet.
+const maxScrapeInfoHashes = 74
+
+type scrapeRequest struct {
+	udpRequestHeader
+	InfoHashes [][20]byte
+}
+
+func (r *scrapeRequest) WriteTo(w io.Writer) (int64, error) {
+	buf := bytes.NewBuffer(make([]byte, 0, 16+20*len(r.InfoHashes)))
+	err := binary.Write(buf, binary.BigEndian, r.udpRequestHeader)
+	if err != nil {
+		return 0, err
+	}
+	for _, ih := range r.InfoHashes {
+		buf.Write(ih[:])
+	}
+	return buf.WriteTo(w)
+}
+
+type scrapeResponseTorrent struct {
+	Seeders   int32
+	Completed int32
+	Leechers  int32
+}
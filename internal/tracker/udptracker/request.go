@@ -2,18 +2,25 @@ package udptracker
 
 import (
 	"context"
-	"encoding/binary"
+	"io"
 
 	"github.com/cenkalti/rain/internal/tracker"
 )
 
 type transportRequest struct {
 	*requestBase
-	transferAnnounceRequest
+	udpMessage
 }
 
 var _ udpRequest = (*transportRequest)(nil)
 
+// udpMessage is a request that is sent after the connection ID is received from the tracker.
+type udpMessage interface {
+	io.WriterTo
+	SetTransactionID(int32)
+	SetConnectionID(int64)
+}
+
 func newTransportRequest(ctx context.Context, req tracker.AnnounceRequest, dest string, urlData string) *transportRequest {
 	request := &announceRequest{
 		InfoHash:   req.Torrent.InfoHash,
@@ -24,15 +31,24 @@ func newTransportRequest(ctx context.Context, req tracker.AnnounceRequest, dest
 		Event:      req.Event,
 		NumWant:    int32(req.NumWant),
 		Port:       uint16(req.Torrent.Port),
+		Key:        req.Torrent.Key,
 	}
-	binary.BigEndian.PutUint32(request.PeerID[16:20], request.Key)
 	request.Action = actionAnnounce
 
 	return &transportRequest{
 		requestBase: newRequestBase(ctx, dest),
-		transferAnnounceRequest: transferAnnounceRequest{
+		udpMessage: &transferAnnounceRequest{
 			announceRequest: request,
 			urlData:         urlData,
 		},
 	}
 }
+
+func newScrapeTransportRequest(ctx context.Context, infoHashes [][20]byte, dest string) *transportRequest {
+	request := &scrapeRequest{InfoHashes: infoHashes}
+	request.Action = actionScrape
+	return &transportRequest{
+		requestBase: newRequestBase(ctx, dest),
+		udpMessage:  request,
+	}
+}
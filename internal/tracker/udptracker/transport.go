@@ -20,15 +20,18 @@ import (
 )
 
 const (
-	connectionIDMagic    = 0x41727101980
+	connectionIDMagic = 0x41727101980
+	// A connection ID can be used until one minute after it is received (BEP 15).
+	// It is shared by all requests made to the same tracker address in this interval.
 	connectionIDInterval = time.Minute
 )
 
 // Transport for UDP tracker implementation.
 type Transport struct {
-	blocklist  *blocklist.Blocklist
-	log        logger.Logger
-	dnsTimeout time.Duration
+	blocklist    *blocklist.Blocklist
+	log          logger.Logger
+	dnsTimeout   time.Duration
+	listenPacket ListenFunc
 
 	// Transport.Do will send messages to this channel.
 	requestC chan *transportRequest
@@ -36,6 +39,9 @@ type Transport struct {
 	// read loop will send data read from UDP connection and send them to this channel.
 	readC chan []byte
 
+	// read loop will send the error to this channel if the connection cannot be read anymore.
+	readErrC chan error
+
 	// Will be closed by Transport.Close to end Transport.Run loop.
 	closeC chan struct{}
 
@@ -43,16 +49,28 @@ type Transport struct {
 	doneC chan struct{}
 }
 
+// ListenFunc returns the connection that is used for sending and receiving UDP packets.
+type ListenFunc func() (net.PacketConn, error)
+
 // NewTransport returns a new UDP tracker transport.
-func NewTransport(bl *blocklist.Blocklist, dnsTimeout time.Duration) *Transport {
+// If listen is nil, packets are sent from a UDP port selected by the operating system.
+func NewTransport(bl *blocklist.Blocklist, dnsTimeout time.Duration, listen ListenFunc) *Transport {
+	if listen == nil {
+		listen = func() (net.PacketConn, error) {
+			var laddr net.UDPAddr
+			return net.ListenUDP("udp4", &laddr)
+		}
+	}
 	return &Transport{
-		blocklist:  bl,
-		log:        logger.New("udp tracker transport"),
-		dnsTimeout: dnsTimeout,
-		requestC:   make(chan *transportRequest),
-		readC:      make(chan []byte),
-		closeC:     make(chan struct{}),
-		doneC:      make(chan struct{}),
+		blocklist:    bl,
+		log:          logger.New("udp tracker transport"),
+		dnsTimeout:   dnsTimeout,
+		listenPacket: listen,
+		requestC:     make(chan *transportRequest),
+		readC:        make(chan []byte),
+		readErrC:     make(chan error),
+		closeC:       make(chan struct{}),
+		doneC:        make(chan struct{}),
 	}
 }
 
@@ -88,22 +106,26 @@ func (t *Transport) Do(req *transportRequest) ([]byte, error) {
 func (t *Transport) Run() {
 	t.log.Debugln("Starting transport run loop")
 	var listening bool
-	var laddr net.UDPAddr
-	udpConn, listenErr := net.ListenUDP("udp4", &laddr)
-	if listenErr != nil {
-		t.log.Error(listenErr)
-	} else {
+	var udpConn net.PacketConn
+	var listenErr error
+	listen := func() {
+		udpConn, listenErr = t.listenPacket()
+		if listenErr != nil {
+			t.log.Error(listenErr)
+			return
+		}
 		listening = true
 		t.log.Debugln("Starting transport read loop")
 		go t.readLoop(udpConn)
 	}
+	listen()
 
 	// All transaction are saved with their ID as key.
 	transactions := make(map[int32]*transaction)
 	// Connections can be either connecting or connected.
 	connections := make(map[string]*connection)
 	connectDone := make(chan *connectionResult)
-	connectionExpired := make(chan string)
+	connectionExpired := make(chan *connection)
 
 	// Transaction can be either a connection request or announce request.
 	beginTransaction := func(i udpRequest) (*transaction, error) {
@@ -119,6 +141,10 @@ func (t *Transport) Run() {
 	for {
 		select {
 		case req := <-t.requestC:
+			// Listening may fail temporarily, e.g. when the proxy server is not reachable.
+			if !listening {
+				listen()
+			}
 			// Early break if we couldn't listen on the UDP port.
 			if !listening {
 				req.SetResponse(nil, listenErr)
@@ -130,16 +156,17 @@ func (t *Transport) Run() {
 				connections[req.dest] = conn
 				trx, err := beginTransaction(conn)
 				if err != nil {
-					trx.request.SetResponse(nil, err)
+					delete(connections, req.dest)
+					req.SetResponse(nil, err)
 				} else {
 					go resolveDestinationAndConnect(trx, req.dest, udpConn, t.dnsTimeout, t.blocklist, connectDone, t.closeC)
 				}
 			} else {
 				if !conn.connectedAt.IsZero() {
-					req.ConnectionID = conn.id
+					req.SetConnectionID(conn.id)
 					trx, err := beginTransaction(req)
 					if err != nil {
-						trx.request.SetResponse(nil, err)
+						req.SetResponse(nil, err)
 					} else {
 						go retryTransaction(trx, udpConn, conn.addr)
 					}
@@ -170,24 +197,24 @@ func (t *Transport) Run() {
 			conn.connectedAt = res.connectedAt
 
 			// Expire the connection after defined period.
-			go func(dest string) {
+			go func(conn *connection) {
 				select {
 				case <-time.After(connectionIDInterval):
 				case <-t.closeC:
 					return
 				}
 				select {
-				case connectionExpired <- dest:
+				case connectionExpired <- conn:
 				case <-t.closeC:
 				}
-			}(res.dest)
+			}(conn)
 
 			// Start announce transaction for all waiting requests.
 			for _, req := range conn.requests {
-				req.ConnectionID = conn.id
+				req.SetConnectionID(conn.id)
 				trx, err := beginTransaction(req)
 				if err != nil {
-					trx.request.SetResponse(nil, err)
+					req.SetResponse(nil, err)
 				} else {
 					go retryTransaction(trx, udpConn, conn.addr)
 				}
@@ -195,8 +222,18 @@ func (t *Transport) Run() {
 
 			// All requests are sent, clear waiting request list.
 			conn.requests = nil
-		case dest := <-connectionExpired:
-			delete(connections, dest)
+		case err := <-t.readErrC:
+			t.log.Errorln("cannot read from udp connection:", err)
+			udpConn.Close()
+			listening = false
+			listenErr = err
+			// Connection IDs are bound to our address. They need to be requested again after listening on a new address.
+			connections = make(map[string]*connection)
+		case conn := <-connectionExpired:
+			// Connection may be already replaced by a new one.
+			if connections[conn.dest] == conn {
+				delete(connections, conn.dest)
+			}
 		case buf := <-t.readC:
 			var header udpMessageHeader
 			err := binary.Read(bytes.NewReader(buf), binary.BigEndian, &header)
@@ -232,6 +269,13 @@ func (t *Transport) Run() {
 						RetryIn:       time.Duration(retryIn) * time.Minute,
 					}
 				}
+				// The error may be caused by a connection ID that is no longer accepted by the tracker.
+				// Next request connects again instead of using the cached ID.
+				if req, ok := trx.request.(*transportRequest); ok {
+					if conn := connections[req.dest]; conn != nil && !conn.connectedAt.IsZero() {
+						delete(connections, req.dest)
+					}
+				}
 			}
 			trx.request.SetResponse(buf, err)
 			trx.cancel()
@@ -254,17 +298,16 @@ func (t *Transport) Run() {
 }
 
 // readLoop reads datagrams from connection and sends to the run loop.
-func (t *Transport) readLoop(conn net.Conn) {
+func (t *Transport) readLoop(conn net.PacketConn) {
 	// Read buffer must be big enough to hold a UDP packet of maximum expected size.
 	const maxNumWant = 1000
 	bigBuf := make([]byte, 20+6*maxNumWant)
 	for {
-		n, err := conn.Read(bigBuf)
+		n, _, err := conn.ReadFrom(bigBuf)
 		if err != nil {
 			select {
+			case t.readErrC <- err:
 			case <-t.closeC:
-			default:
-				t.log.Error(err)
 			}
 			return
 		}
@@ -289,7 +332,7 @@ type connectionResult struct {
 	connectedAt time.Time
 }
 
-func resolveDestinationAndConnect(trx *transaction, dest string, udpConn *net.UDPConn, dnsTimeout time.Duration, blocklist *blocklist.Blocklist, resultC chan *connectionResult, stopC chan struct{}) {
+func resolveDestinationAndConnect(trx *transaction, dest string, udpConn net.PacketConn, dnsTimeout time.Duration, blocklist *blocklist.Blocklist, resultC chan *connectionResult, stopC chan struct{}) {
 	res := &connectionResult{
 		trx:  trx,
 		dest: dest,
@@ -318,7 +361,7 @@ func resolveDestinationAndConnect(trx *transaction, dest string, udpConn *net.UD
 	}
 }
 
-func sendAndReceiveConnect(trx *transaction, conn *net.UDPConn, addr net.Addr) (connectionID int64, err error) {
+func sendAndReceiveConnect(trx *transaction, conn net.PacketConn, addr net.Addr) (connectionID int64, err error) {
 	// Send request until the transaction is canceled.
 	go retryTransaction(trx, conn, addr)
 
@@ -350,7 +393,7 @@ func sendAndReceiveConnect(trx *transaction, conn *net.UDPConn, addr net.Addr) (
 // Send the request until the transaction is canceled.
 // Transaction canceled by either via outer context or when response is received from the tracker.
 // It backs off with the algorithm described in BEP15 and retries.
-func retryTransaction(trx *transaction, conn *net.UDPConn, addr net.Addr) {
+func retryTransaction(trx *transaction, conn net.PacketConn, addr net.Addr) {
 	var b bytes.Buffer
 	_, _ = trx.request.WriteTo(&b)
 	data := b.Bytes()
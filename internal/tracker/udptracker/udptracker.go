@@ -28,11 +28,16 @@ type UDPTracker struct {
 var _ tracker.Tracker = (*UDPTracker)(nil)
 
 // New returns a new UDPTracker.
+// Path and query of the URL are sent in announce requests as URL data option (BEP 41).
 func New(rawURL string, u *url.URL, t *Transport) *UDPTracker {
+	var urlData string
+	if u.Path != "" || u.RawQuery != "" {
+		urlData = u.RequestURI()
+	}
 	return &UDPTracker{
 		rawURL:    rawURL,
 		dest:      u.Host,
-		urlData:   u.RequestURI(),
+		urlData:   urlData,
 		log:       logger.New("tracker " + u.Host),
 		transport: t,
 	}
@@ -66,6 +71,50 @@ func (t *UDPTracker) Announce(ctx context.Context, req tracker.AnnounceRequest)
 	}, nil
 }
 
+// Scrape the torrents from UDP tracker. Info hashes are sent in batches that fit in a UDP packet.
+func (t *UDPTracker) Scrape(ctx context.Context, infoHashes [][20]byte) (map[[20]byte]tracker.ScrapeResult, error) {
+	ret := make(map[[20]byte]tracker.ScrapeResult, len(infoHashes))
+	for len(infoHashes) > 0 {
+		batch := infoHashes
+		if len(batch) > maxScrapeInfoHashes {
+			batch = batch[:maxScrapeInfoHashes]
+		}
+		infoHashes = infoHashes[len(batch):]
+
+		reply, err := t.transport.Do(newScrapeTransportRequest(ctx, batch, t.dest))
+		if err != nil {
+			return nil, err
+		}
+		results, err := parseScrapeResponse(reply, len(batch))
+		if err != nil {
+			return nil, tracker.ErrDecode
+		}
+		for i, r := range results {
+			ret[batch[i]] = tracker.ScrapeResult{
+				Seeders:   r.Seeders,
+				Leechers:  r.Leechers,
+				Completed: r.Completed,
+			}
+		}
+	}
+	return ret, nil
+}
+
+func parseScrapeResponse(data []byte, count int) ([]scrapeResponseTorrent, error) {
+	r := bytes.NewReader(data)
+	var header udpMessageHeader
+	err := binary.Read(r, binary.BigEndian, &header)
+	if err != nil {
+		return nil, err
+	}
+	if header.Action != actionScrape {
+		return nil, errors.New("invalid action")
+	}
+	results := make([]scrapeResponseTorrent, count)
+	err = binary.Read(r, binary.BigEndian, results)
+	return results, err
+}
+
 func (t *UDPTracker) parseAnnounceResponse(data []byte) (*udpAnnounceResponse, []*net.TCPAddr, error) {
 	var response udpAnnounceResponse
 	err := binary.Read(bytes.NewReader(data), binary.BigEndian, &response)
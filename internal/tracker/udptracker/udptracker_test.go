@@ -1,7 +1,10 @@
 package udptracker_test
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"net"
 	"net/url"
 	"strconv"
 	"testing"
@@ -13,6 +16,7 @@ import (
 	"github.com/chihaya/chihaya/middleware"
 	"github.com/chihaya/chihaya/storage"
 	_ "github.com/chihaya/chihaya/storage/memory"
+	"github.com/stretchr/testify/assert"
 )
 
 const timeout = 2 * time.Second
@@ -55,7 +59,7 @@ func TestUDPTracker(t *testing.T) {
 	if err != nil {
 		t.Fatal(err)
 	}
-	tr := udptracker.NewTransport(nil, 5*time.Second)
+	tr := udptracker.NewTransport(nil, 5*time.Second, nil)
 	go tr.Run()
 	defer tr.Close()
 	trk := udptracker.New(rawURL, u, tr)
@@ -95,4 +99,90 @@ func TestUDPTracker(t *testing.T) {
 		t.Log(addr.String())
 		t.FailNow()
 	}
+
+	scrape, err := trk.Scrape(ctx, [][20]byte{[20]byte{}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r := scrape[[20]byte{}]; r.Seeders != 1 || r.Leechers != 1 {
+		t.Logf("%#v", scrape)
+		t.FailNow()
+	}
+}
+
+// fakeTracker counts the connect requests and records URL data sent in announce requests.
+type fakeTracker struct {
+	conn     *net.UDPConn
+	connects int
+	urlData  chan string
+}
+
+func startFakeTracker(t *testing.T) *fakeTracker {
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{IP: net.IPv4(127, 0, 0, 1)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft := &fakeTracker{conn: conn, urlData: make(chan string, 10)}
+	go ft.serve()
+	return ft
+}
+
+func (f *fakeTracker) serve() {
+	const connectionID = 42
+	buf := make([]byte, 2048)
+	for {
+		n, addr, err := f.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		action := binary.BigEndian.Uint32(buf[8:12])
+		var resp bytes.Buffer
+		switch action {
+		case 0: // connect
+			f.connects++
+			_ = binary.Write(&resp, binary.BigEndian, []int32{0, int32(binary.BigEndian.Uint32(buf[12:16]))})
+			_ = binary.Write(&resp, binary.BigEndian, int64(connectionID))
+		case 1: // announce
+			if binary.BigEndian.Uint64(buf[0:8]) != connectionID {
+				continue
+			}
+			var urlData string
+			for opts := buf[98:n]; len(opts) > 0 && opts[0] != 0; {
+				size := int(opts[1])
+				urlData += string(opts[2 : 2+size])
+				opts = opts[2+size:]
+			}
+			f.urlData <- urlData
+			_ = binary.Write(&resp, binary.BigEndian, []int32{1, int32(binary.BigEndian.Uint32(buf[12:16])), 60, 0, 0})
+		}
+		_, _ = f.conn.WriteTo(resp.Bytes(), addr)
+	}
+}
+
+func TestURLDataAndConnectionReuse(t *testing.T) {
+	ft := startFakeTracker(t)
+	defer ft.conn.Close()
+
+	tr := udptracker.NewTransport(nil, time.Second, nil)
+	go tr.Run()
+	defer tr.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	longPath := "/" + string(bytes.Repeat([]byte("a"), 300))
+	for _, path := range []string{"/announce?passkey=abc", longPath, ""} {
+		rawURL := "udp://" + ft.conn.LocalAddr().String() + path
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = udptracker.New(rawURL, u, tr).Announce(ctx, tracker.AnnounceRequest{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		assert.Equal(t, path, <-ft.urlData)
+	}
+	// Single connect request is made for all announces to the same address.
+	assert.Equal(t, 1, ft.connects)
 }
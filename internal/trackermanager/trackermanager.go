@@ -7,9 +7,11 @@ import (
 	"net"
 	"net/http"
 	"net/url"
+	"strings"
 	"time"
 
 	"github.com/cenkalti/rain/internal/blocklist"
+	"github.com/cenkalti/rain/internal/proxy"
 	"github.com/cenkalti/rain/internal/resolver"
 	"github.com/cenkalti/rain/internal/tracker"
 	"github.com/cenkalti/rain/internal/tracker/httptracker"
@@ -21,25 +23,41 @@ import (
 type TrackerManager struct {
 	httpTransport *http.Transport
 	udpTransport  *udptracker.Transport
+	httpSettings  map[string]httptracker.Settings
 }
 
 // New returns a new TrackerManager.
-func New(bl *blocklist.Blocklist, dnsTimeout time.Duration, tlsSkipVerify bool) *TrackerManager {
+// HTTP trackers are connected with the dialer and UDP packets are sent from the connection returned by listenUDP.
+// If dialer or listenUDP is nil, default implementations from net package are used.
+// If remoteDNS is true, host names of HTTP trackers are not resolved locally but passed to the dialer,
+// so they can be resolved by a proxy server.
+// httpSettings are keyed by domain names and they are applied to the HTTP trackers in the domain and its subdomains.
+func New(bl *blocklist.Blocklist, dnsTimeout time.Duration, tlsSkipVerify bool, dialer proxy.Dialer, remoteDNS bool, listenUDP udptracker.ListenFunc, httpSettings map[string]httptracker.Settings) *TrackerManager {
+	if dialer == nil {
+		dialer = &net.Dialer{}
+	}
 	m := &TrackerManager{
 		httpTransport: &http.Transport{
 			TLSClientConfig: &tls.Config{InsecureSkipVerify: tlsSkipVerify}, // nolint: gosec
 		},
-		udpTransport: udptracker.NewTransport(bl, dnsTimeout),
+		udpTransport: udptracker.NewTransport(bl, dnsTimeout, listenUDP),
+		httpSettings: httpSettings,
 	}
 	go m.udpTransport.Run()
 	m.httpTransport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		if remoteDNS {
+			err := resolver.Check(addr, bl)
+			if err != nil {
+				return nil, err
+			}
+			return dialer.DialContext(ctx, network, addr)
+		}
 		ip, port, err := resolver.Resolve(ctx, addr, dnsTimeout, bl)
 		if err != nil {
 			return nil, err
 		}
-		var d net.Dialer
 		taddr := &net.TCPAddr{IP: ip, Port: port}
-		return d.DialContext(ctx, network, taddr.String())
+		return dialer.DialContext(ctx, network, taddr.String())
 	}
 	return m
 }
@@ -57,7 +75,7 @@ func (m *TrackerManager) Get(s string, httpTimeout time.Duration, httpUserAgent
 	}
 	switch u.Scheme {
 	case "http", "https":
-		tr := httptracker.New(s, u, httpTimeout, m.httpTransport, httpUserAgent, httpMaxResponseLength)
+		tr := httptracker.New(s, u, httpTimeout, m.httpTransport, httpUserAgent, httpMaxResponseLength, m.settingsFor(u.Hostname()))
 		return tr, nil
 	case "udp":
 		tr := udptracker.New(s, u, m.udpTransport)
@@ -66,3 +84,18 @@ func (m *TrackerManager) Get(s string, httpTimeout time.Duration, httpUserAgent
 		return nil, fmt.Errorf("unsupported tracker scheme: %s", u.Scheme)
 	}
 }
+
+// settingsFor returns the HTTP tracker settings of the most specific domain that matches the host.
+func (m *TrackerManager) settingsFor(host string) httptracker.Settings {
+	host = strings.ToLower(host)
+	for {
+		if s, ok := m.httpSettings[host]; ok {
+			return s
+		}
+		i := strings.IndexByte(host, '.')
+		if i < 0 {
+			return httptracker.Settings{}
+		}
+		host = host[i+1:]
+	}
+}
@@ -0,0 +1,38 @@
+package trackermanager
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/resolver"
+	"github.com/stretchr/testify/assert"
+)
+
+var errDial = errors.New("dial error")
+
+type recordingDialer struct {
+	addrs []string
+}
+
+func (d *recordingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	d.addrs = append(d.addrs, address)
+	return nil, errDial
+}
+
+func TestRemoteDNS(t *testing.T) {
+	d := &recordingDialer{}
+	m := New(nil, time.Second, false, d, true, nil, nil)
+	defer m.Close()
+
+	// Host name must be passed to the proxy without resolving.
+	_, err := m.httpTransport.DialContext(context.Background(), "tcp", "tracker.invalid:80")
+	assert.Equal(t, errDial, err)
+	assert.Equal(t, []string{"tracker.invalid:80"}, d.addrs)
+
+	_, err = m.httpTransport.DialContext(context.Background(), "tcp", "tracker.invalid:0")
+	assert.Equal(t, resolver.ErrInvalidPort, err)
+	assert.Len(t, d.addrs, 1)
+}
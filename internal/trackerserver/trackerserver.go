@@ -0,0 +1,182 @@
+// Package trackerserver implements a BitTorrent tracker that serves announce and scrape requests over HTTP and UDP.
+package trackerserver
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/chihaya/chihaya/bittorrent"
+	fhttp "github.com/chihaya/chihaya/frontend/http"
+	"github.com/chihaya/chihaya/frontend/udp"
+	"github.com/chihaya/chihaya/middleware"
+	"github.com/chihaya/chihaya/pkg/stop"
+	"github.com/chihaya/chihaya/storage"
+	"github.com/chihaya/chihaya/storage/memory"
+)
+
+// ErrTorrentNotAllowed is returned to the clients announcing a torrent that is not in the allowlist.
+var ErrTorrentNotAllowed = bittorrent.ClientError("torrent is not allowed on this tracker")
+
+// Config for the tracker Server.
+type Config struct {
+	// Address to listen for HTTP requests. Tracker is not served over HTTP if empty.
+	HTTPAddr string
+	// Address to listen for UDP requests. Tracker is not served over UDP if empty.
+	UDPAddr string
+	// Interval sent to the clients to announce again.
+	AnnounceInterval time.Duration
+	// Clients must not announce more frequently than this interval.
+	MinAnnounceInterval time.Duration
+	// Peers that are not announced in this duration are removed from the swarm.
+	PeerLifetime time.Duration
+	// Maximum number of peers returned in an announce response.
+	MaxNumWant uint32
+	// Serve all torrents instead of the ones in the allowlist.
+	AllowAll bool
+}
+
+// Server is a BitTorrent tracker.
+type Server struct {
+	store    storage.PeerStore
+	http     *fhttp.Frontend
+	udp      *udp.Frontend
+	allowAll bool
+
+	mAllowed sync.RWMutex
+	allowed  map[[20]byte]struct{}
+}
+
+// New starts listening the addresses in cfg and returns a new Server.
+func New(cfg Config) (*Server, error) {
+	if cfg.HTTPAddr == "" && cfg.UDPAddr == "" {
+		return nil, errors.New("no address to listen")
+	}
+	s := &Server{
+		allowAll: cfg.AllowAll,
+		allowed:  make(map[[20]byte]struct{}),
+	}
+	var err error
+	s.store, err = memory.New(memory.Config{
+		GarbageCollectionInterval:   time.Minute,
+		PrometheusReportingInterval: time.Minute,
+		PeerLifetime:                cfg.PeerLifetime,
+		ShardCount:                  16,
+	})
+	if err != nil {
+		return nil, err
+	}
+	logic := middleware.NewLogic(middleware.ResponseConfig{
+		AnnounceInterval:    cfg.AnnounceInterval,
+		MinAnnounceInterval: cfg.MinAnnounceInterval,
+	}, s.store, []middleware.Hook{s}, nil)
+	defer func() {
+		if err != nil {
+			s.Close()
+		}
+	}()
+	if cfg.HTTPAddr != "" {
+		s.http, err = fhttp.NewFrontend(logic, fhttp.Config{
+			Addr:         cfg.HTTPAddr,
+			ReadTimeout:  10 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  time.Minute,
+			ParseOptions: fhttp.ParseOptions{
+				MaxNumWant:          cfg.MaxNumWant,
+				DefaultNumWant:      cfg.MaxNumWant,
+				MaxScrapeInfoHashes: 100,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.UDPAddr != "" {
+		key := make([]byte, 32)
+		_, err = rand.Read(key)
+		if err != nil {
+			return nil, err
+		}
+		s.udp, err = udp.NewFrontend(logic, udp.Config{
+			Addr:         cfg.UDPAddr,
+			PrivateKey:   hex.EncodeToString(key),
+			MaxClockSkew: 10 * time.Second,
+			ParseOptions: udp.ParseOptions{
+				MaxNumWant:          cfg.MaxNumWant,
+				DefaultNumWant:      cfg.MaxNumWant,
+				MaxScrapeInfoHashes: 74,
+			},
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return s, nil
+}
+
+// Close stops listening and releases the resources.
+func (s *Server) Close() error {
+	var results []stop.Result
+	if s.http != nil {
+		results = append(results, s.http.Stop())
+	}
+	if s.udp != nil {
+		results = append(results, s.udp.Stop())
+	}
+	results = append(results, s.store.Stop())
+	var err error
+	for _, res := range results {
+		if errs := <-res; len(errs) > 0 && err == nil {
+			err = errs[0]
+		}
+	}
+	return err
+}
+
+// Allow the torrent to be announced to the tracker.
+func (s *Server) Allow(infoHash [20]byte) {
+	s.mAllowed.Lock()
+	s.allowed[infoHash] = struct{}{}
+	s.mAllowed.Unlock()
+}
+
+// Disallow the torrent that is previously allowed.
+func (s *Server) Disallow(infoHash [20]byte) {
+	s.mAllowed.Lock()
+	delete(s.allowed, infoHash)
+	s.mAllowed.Unlock()
+}
+
+func (s *Server) isAllowed(infoHash bittorrent.InfoHash) bool {
+	if s.allowAll {
+		return true
+	}
+	s.mAllowed.RLock()
+	defer s.mAllowed.RUnlock()
+	_, ok := s.allowed[infoHash]
+	return ok
+}
+
+// HandleAnnounce implements middleware.Hook interface for rejecting torrents that are not allowed.
+func (s *Server) HandleAnnounce(ctx context.Context, req *bittorrent.AnnounceRequest, resp *bittorrent.AnnounceResponse) (context.Context, error) {
+	if !s.isAllowed(req.InfoHash) {
+		return ctx, ErrTorrentNotAllowed
+	}
+	return ctx, nil
+}
+
+// HandleScrape implements middleware.Hook interface. Torrents that are not allowed are reported as empty swarms.
+// Response is generated here instead of the default response hook, which reports the peers of all torrents in the store.
+func (s *Server) HandleScrape(ctx context.Context, req *bittorrent.ScrapeRequest, resp *bittorrent.ScrapeResponse) (context.Context, error) {
+	for _, infoHash := range req.InfoHashes {
+		if s.isAllowed(infoHash) {
+			resp.Files = append(resp.Files, s.store.ScrapeSwarm(infoHash, req.AddressFamily))
+		} else {
+			resp.Files = append(resp.Files, bittorrent.Scrape{InfoHash: infoHash})
+		}
+	}
+	return context.WithValue(ctx, middleware.SkipResponseHookKey, struct{}{}), nil
+}
@@ -0,0 +1,106 @@
+package trackerserver
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/cenkalti/rain/internal/tracker"
+	"github.com/cenkalti/rain/internal/tracker/httptracker"
+	"github.com/cenkalti/rain/internal/tracker/udptracker"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer(t *testing.T) {
+	s, err := New(Config{
+		HTTPAddr:            "127.0.0.1:5010",
+		UDPAddr:             "127.0.0.1:5010",
+		AnnounceInterval:    time.Minute,
+		MinAnnounceInterval: time.Second,
+		PeerLifetime:        time.Hour,
+		MaxNumWant:          50,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	allowed := [20]byte{1}
+	s.Allow(allowed)
+
+	const httpURL = "http://127.0.0.1:5010/announce"
+	u, err := url.Parse(httpURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	htr := httptracker.New(httpURL, u, time.Second, new(http.Transport), "rain", 1<<20, httptracker.Settings{})
+
+	const udpURL = "udp://127.0.0.1:5010/announce"
+	u, err = url.Parse(udpURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	transport := udptracker.NewTransport(nil, time.Second, nil)
+	go transport.Run()
+	defer transport.Close()
+	utr := udptracker.New(udpURL, u, transport)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	req := tracker.AnnounceRequest{
+		Torrent: tracker.Torrent{
+			InfoHash:  allowed,
+			PeerID:    [20]byte{1},
+			Port:      1111,
+			BytesLeft: 0,
+		},
+		NumWant: 50,
+	}
+	resp, err := htr.Announce(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, time.Minute, resp.Interval)
+
+	req.Torrent.PeerID = [20]byte{2}
+	req.Torrent.Port = 2222
+	req.Torrent.BytesLeft = 100
+	resp, err = utr.Announce(ctx, req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Len(t, resp.Peers, 1)
+	assert.Equal(t, 1111, resp.Peers[0].Port)
+
+	scrapes, err := htr.Scrape(ctx, [][20]byte{allowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tracker.ScrapeResult{Seeders: 1, Leechers: 1}, scrapes[allowed])
+
+	req.Torrent.InfoHash = [20]byte{2}
+	_, err = htr.Announce(ctx, req)
+	assert.Error(t, err)
+	_, err = utr.Announce(ctx, req)
+	assert.Error(t, err)
+
+	s.Disallow(allowed)
+	req.Torrent.InfoHash = allowed
+	_, err = htr.Announce(ctx, req)
+	assert.Error(t, err)
+
+	// Peers announced before are not reported after the torrent is disallowed.
+	scrapes, err = htr.Scrape(ctx, [][20]byte{allowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tracker.ScrapeResult{}, scrapes[allowed])
+	scrapes, err = utr.Scrape(ctx, [][20]byte{allowed})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, tracker.ScrapeResult{}, scrapes[allowed])
+}
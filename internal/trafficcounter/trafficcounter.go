@@ -0,0 +1,96 @@
+// Package trafficcounter counts the bytes transferred in the current day and month.
+package trafficcounter
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Counter keeps the number of downloaded and uploaded bytes in daily and monthly periods.
+// Counts are reset when a new period begins. Counter is not safe for concurrent use.
+type Counter struct {
+	monthStartDay int
+
+	Day   Period
+	Month Period
+}
+
+// Period is a time range that the traffic is counted in.
+type Period struct {
+	// Beginning of the period in local time.
+	Start      time.Time
+	Downloaded int64
+	Uploaded   int64
+}
+
+// Total returns the sum of downloaded and uploaded bytes in the period.
+func (p Period) Total() int64 {
+	return p.Downloaded + p.Uploaded
+}
+
+// New returns a new Counter. Monthly periods begin at monthStartDay of each month, between 1 and 28.
+func New(monthStartDay int) *Counter {
+	if monthStartDay < 1 {
+		monthStartDay = 1
+	} else if monthStartDay > 28 {
+		monthStartDay = 28
+	}
+	return &Counter{monthStartDay: monthStartDay}
+}
+
+// Add bytes to the periods that contain now.
+func (c *Counter) Add(downloaded, uploaded int64, now time.Time) {
+	c.Rollover(now)
+	c.Day.Downloaded += downloaded
+	c.Day.Uploaded += uploaded
+	c.Month.Downloaded += downloaded
+	c.Month.Uploaded += uploaded
+}
+
+// Rollover resets the counts if a new period has begun. Returns true if any of the periods is changed.
+func (c *Counter) Rollover(now time.Time) bool {
+	var changed bool
+	if day := dayStart(now); !day.Equal(c.Day.Start) {
+		c.Day = Period{Start: day}
+		changed = true
+	}
+	if month := monthStart(now, c.monthStartDay); !month.Equal(c.Month.Start) {
+		c.Month = Period{Start: month}
+		changed = true
+	}
+	return changed
+}
+
+func dayStart(t time.Time) time.Time {
+	y, m, d := t.Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, t.Location())
+}
+
+func monthStart(t time.Time, startDay int) time.Time {
+	y, m, d := t.Date()
+	if d < startDay {
+		m--
+	}
+	return time.Date(y, m, startDay, 0, 0, 0, 0, t.Location())
+}
+
+type jsonCounter struct {
+	Day   Period
+	Month Period
+}
+
+// MarshalJSON encodes the periods for saving the Counter.
+func (c *Counter) MarshalJSON() ([]byte, error) {
+	return json.Marshal(jsonCounter{Day: c.Day, Month: c.Month})
+}
+
+// UnmarshalJSON loads the periods that are previously saved with MarshalJSON.
+func (c *Counter) UnmarshalJSON(b []byte) error {
+	var j jsonCounter
+	err := json.Unmarshal(b, &j)
+	if err != nil {
+		return err
+	}
+	c.Day, c.Month = j.Day, j.Month
+	return nil
+}
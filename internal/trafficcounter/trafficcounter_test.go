@@ -0,0 +1,46 @@
+package trafficcounter
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	c := New(15)
+	now := time.Date(2021, 3, 20, 10, 0, 0, 0, time.UTC)
+	c.Add(100, 10, now)
+	c.Add(100, 10, now.Add(time.Hour))
+	assert.Equal(t, time.Date(2021, 3, 20, 0, 0, 0, 0, time.UTC), c.Day.Start)
+	assert.Equal(t, time.Date(2021, 3, 15, 0, 0, 0, 0, time.UTC), c.Month.Start)
+	assert.Equal(t, int64(200), c.Day.Downloaded)
+	assert.Equal(t, int64(220), c.Month.Total())
+
+	// New day
+	now = time.Date(2021, 3, 21, 0, 0, 1, 0, time.UTC)
+	assert.True(t, c.Rollover(now))
+	assert.False(t, c.Rollover(now))
+	assert.Equal(t, int64(0), c.Day.Total())
+	assert.Equal(t, int64(220), c.Month.Total())
+
+	// Save and load
+	b, err := json.Marshal(c)
+	assert.NoError(t, err)
+	c = New(15)
+	assert.NoError(t, json.Unmarshal(b, c))
+	assert.Equal(t, int64(220), c.Month.Total())
+
+	// New month begins at the start day.
+	c.Add(1, 1, time.Date(2021, 4, 14, 23, 0, 0, 0, time.UTC))
+	assert.Equal(t, int64(222), c.Month.Total())
+	c.Add(1, 1, time.Date(2021, 4, 15, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2021, 4, 15, 0, 0, 0, 0, time.UTC), c.Month.Start)
+	assert.Equal(t, int64(2), c.Month.Total())
+
+	// Month start in January belongs to the previous year.
+	c = New(15)
+	c.Rollover(time.Date(2021, 1, 10, 0, 0, 0, 0, time.UTC))
+	assert.Equal(t, time.Date(2020, 12, 15, 0, 0, 0, 0, time.UTC), c.Month.Start)
+}
@@ -0,0 +1,52 @@
+package unchoker
+
+const (
+	// Upload is considered saturated if the rate is above this ratio of the capacity.
+	saturationRatio = 0.9
+	// Measured capacity decays at every update, so the capacity is probed again if the network conditions change.
+	capacityDecay = 0.99
+	// A slot is removed if the average upload rate per slot drops below this value while upload is saturated.
+	minSlotRate = 2 << 10
+)
+
+// SlotTuner adjusts the number of upload slots by comparing the upload rate to the upload capacity.
+// A slot is added while there is unused capacity and removed when slots get too slow to be useful.
+type SlotTuner struct {
+	min, max int
+	slots    int
+	// Highest upload rate that is measured in bytes/s.
+	peak float64
+}
+
+// NewSlotTuner returns a new SlotTuner that keeps the number of slots between min and max.
+func NewSlotTuner(min, max int) *SlotTuner {
+	if max < min {
+		max = min
+	}
+	return &SlotTuner{
+		min:   min,
+		max:   max,
+		slots: min,
+	}
+}
+
+// Update is called periodically with the current upload rate and the upload limit in bytes/s and returns the new number of slots.
+// If limit is zero, the capacity is the highest rate that is measured.
+func (s *SlotTuner) Update(rate, limit int) int {
+	s.peak *= capacityDecay
+	if float64(rate) > s.peak {
+		s.peak = float64(rate)
+	}
+	capacity := s.peak
+	if limit > 0 {
+		capacity = float64(limit)
+	}
+	saturated := capacity > 0 && float64(rate) >= capacity*saturationRatio
+	switch {
+	case !saturated && s.slots < s.max:
+		s.slots++
+	case saturated && s.slots > s.min && rate/s.slots < minSlotRate:
+		s.slots--
+	}
+	return s.slots
+}
@@ -0,0 +1,151 @@
+package unchoker
+
+import (
+	"math"
+	"sort"
+)
+
+// Strategy decides which interested peers get the regular unchoke slots.
+type Strategy interface {
+	// Sort peers in place by their priority. Peers at the beginning of the slice are unchoked.
+	// Only interested peers are passed. completed is true if the torrent is seeding.
+	// Sort is called from a single goroutine every 10 seconds.
+	Sort(peers []PeerStats, completed bool)
+}
+
+// Fastest unchokes the peers that we download from fastest.
+// While seeding, peers that we upload to fastest are unchoked.
+type Fastest struct{}
+
+var _ Strategy = Fastest{}
+
+// NewFastest returns a new Fastest strategy.
+func NewFastest() Fastest {
+	return Fastest{}
+}
+
+// Sort implements Strategy interface.
+func (Fastest) Sort(peers []PeerStats, completed bool) {
+	if completed {
+		sortByUploadSpeed(peers)
+	} else {
+		sortByDownloadSpeed(peers)
+	}
+}
+
+func sortByDownloadSpeed(peers []PeerStats) {
+	sort.SliceStable(peers, func(i, j int) bool { return peers[i].DownloadSpeed() > peers[j].DownloadSpeed() })
+}
+
+func sortByUploadSpeed(peers []PeerStats) {
+	sort.SliceStable(peers, func(i, j int) bool { return peers[i].UploadSpeed() > peers[j].UploadSpeed() })
+}
+
+// RoundRobin gives every interested peer a turn while seeding.
+// A peer is kept unchoked for a number of rounds, then the peer that has waited longest takes its slot.
+// While downloading, peers are ranked same as the Fastest strategy.
+type RoundRobin struct {
+	rounds int
+	tick   int
+	// Number of consecutive rounds the peer has been unchoked.
+	unchokedRounds map[PeerStats]int
+	// Round number when the peer was choked last time.
+	chokedAt map[PeerStats]int
+}
+
+var _ Strategy = (*RoundRobin)(nil)
+
+// NewRoundRobin returns a new RoundRobin strategy that keeps peers unchoked for the given number of rounds.
+func NewRoundRobin(rounds int) *RoundRobin {
+	if rounds < 1 {
+		rounds = 1
+	}
+	return &RoundRobin{
+		rounds:         rounds,
+		unchokedRounds: make(map[PeerStats]int),
+		chokedAt:       make(map[PeerStats]int),
+	}
+}
+
+// Sort implements Strategy interface.
+func (s *RoundRobin) Sort(peers []PeerStats, completed bool) {
+	if !completed {
+		sortByDownloadSpeed(peers)
+		return
+	}
+	s.tick++
+	unchokedRounds := make(map[PeerStats]int, len(peers))
+	chokedAt := make(map[PeerStats]int, len(peers))
+	for _, pe := range peers {
+		if !pe.Choking() && !pe.Optimistic() {
+			unchokedRounds[pe] = s.unchokedRounds[pe] + 1
+		} else if _, ok := s.unchokedRounds[pe]; ok {
+			chokedAt[pe] = s.tick
+		} else {
+			chokedAt[pe] = s.chokedAt[pe]
+		}
+	}
+	s.unchokedRounds, s.chokedAt = unchokedRounds, chokedAt
+
+	// Unchoked peers that have not used their turn come first,
+	// then the choked peers that have waited longest, then the peers that have used their turn.
+	group := func(pe PeerStats) int {
+		n, ok := s.unchokedRounds[pe]
+		switch {
+		case ok && n < s.rounds:
+			return 0
+		case !ok:
+			return 1
+		default:
+			return 2
+		}
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		gi, gj := group(peers[i]), group(peers[j])
+		if gi != gj {
+			return gi < gj
+		}
+		if gi == 1 {
+			return s.chokedAt[peers[i]] < s.chokedAt[peers[j]]
+		}
+		return peers[i].UploadSpeed() > peers[j].UploadSpeed()
+	})
+	// Peers that are going to be choked start waiting from this round.
+	for _, pe := range peers {
+		if n, ok := s.unchokedRounds[pe]; ok && n >= s.rounds {
+			delete(s.unchokedRounds, pe)
+			s.chokedAt[pe] = s.tick
+		}
+	}
+}
+
+// AntiLeech unchokes the peers that have just started downloading or are about to finish while seeding.
+// Peers in the middle of their download are likely to get the pieces from other peers.
+// While downloading, peers are ranked same as the Fastest strategy.
+type AntiLeech struct{}
+
+var _ Strategy = AntiLeech{}
+
+// NewAntiLeech returns a new AntiLeech strategy.
+func NewAntiLeech() AntiLeech {
+	return AntiLeech{}
+}
+
+// Sort implements Strategy interface.
+func (AntiLeech) Sort(peers []PeerStats, completed bool) {
+	if !completed {
+		sortByDownloadSpeed(peers)
+		return
+	}
+	scores := make(map[PeerStats]float64, len(peers))
+	for _, pe := range peers {
+		scores[pe] = math.Abs(pe.Completion() - 0.5)
+	}
+	sort.SliceStable(peers, func(i, j int) bool {
+		si, sj := scores[peers[i]], scores[peers[j]]
+		if si != sj {
+			return si > sj
+		}
+		return peers[i].UploadSpeed() > peers[j].UploadSpeed()
+	})
+}
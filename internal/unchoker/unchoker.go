@@ -2,13 +2,14 @@ package unchoker
 
 import (
 	"math/rand"
-	"sort"
 )
 
-// Unchoker implements an algorithm to select peers to unchoke based on their download speed.
+// Unchoker implements an algorithm to select peers to unchoke.
+// Regular unchoke slots are given to the peers in the order decided by a Strategy.
 type Unchoker struct {
 	numUnchoked           int
 	numOptimisticUnchoked int
+	strategy              Strategy
 
 	// Every 3rd round an optimistic unchoke logic is applied.
 	round uint8
@@ -17,37 +18,58 @@ type Unchoker struct {
 	peersUnchokedOptimistic map[Peer]struct{}
 }
 
+// PeerStats is the read-only view of a Peer that is passed to a Strategy.
+type PeerStats interface {
+	// Choking returns choke status of local peer
+	Choking() bool
+	// OptimisticUnchoked returns the value previously set by SetOptimistic
+	Optimistic() bool
+
+	DownloadSpeed() int
+	UploadSpeed() int
+	// Completion returns the ratio of pieces that the remote peer has, between 0 and 1.
+	Completion() float64
+}
+
 // Peer of a torrent.
 type Peer interface {
+	PeerStats
+
 	// Sends messages and set choking status of local peeer
 	Choke()
 	Unchoke()
 
-	// Choking returns choke status of local peer
-	Choking() bool
-
 	// Interested returns interest status of remote peer
 	Interested() bool
 
 	// SetOptimistic sets the uptimistic unchoke status of peer
 	SetOptimistic(value bool)
-	// OptimisticUnchoked returns the value previously set by SetOptimistic
-	Optimistic() bool
-
-	DownloadSpeed() int
-	UploadSpeed() int
 }
 
-// New returns a new Unchoker.
-func New(numUnchoked, numOptimisticUnchoked int) *Unchoker {
+// New returns a new Unchoker. If strategy is nil, the Fastest strategy is used.
+func New(numUnchoked, numOptimisticUnchoked int, strategy Strategy) *Unchoker {
+	if strategy == nil {
+		strategy = NewFastest()
+	}
 	return &Unchoker{
 		numUnchoked:             numUnchoked,
 		numOptimisticUnchoked:   numOptimisticUnchoked,
+		strategy:                strategy,
 		peersUnchoked:           make(map[Peer]struct{}, numUnchoked),
 		peersUnchokedOptimistic: make(map[Peer]struct{}, numUnchoked),
 	}
 }
 
+// NumUnchoked returns the number of regular unchoke slots.
+func (u *Unchoker) NumUnchoked() int {
+	return u.numUnchoked
+}
+
+// SetNumUnchoked changes the number of regular unchoke slots. New value is applied on next TickUnchoke call.
+func (u *Unchoker) SetNumUnchoked(n int) {
+	u.numUnchoked = n
+}
+
 // HandleDisconnect must be called to remove the peer from internal indexes.
 func (u *Unchoker) HandleDisconnect(pe Peer) {
 	delete(u.peersUnchoked, pe)
@@ -65,12 +87,13 @@ func (u *Unchoker) candidatesUnchoke(allPeers []Peer) []Peer {
 }
 
 func (u *Unchoker) sortPeers(peers []Peer, completed bool) {
-	byUploadSpeed := func(i, j int) bool { return peers[i].UploadSpeed() > peers[j].UploadSpeed() }
-	byDownloadSpeed := func(i, j int) bool { return peers[i].DownloadSpeed() > peers[j].DownloadSpeed() }
-	if completed {
-		sort.Slice(peers, byUploadSpeed)
-	} else {
-		sort.Slice(peers, byDownloadSpeed)
+	stats := make([]PeerStats, len(peers))
+	for i, pe := range peers {
+		stats[i] = pe
+	}
+	u.strategy.Sort(stats, completed)
+	for i, pe := range stats {
+		peers[i] = pe.(Peer)
 	}
 }
 
@@ -102,6 +125,13 @@ func (u *Unchoker) TickUnchoke(allPeers []Peer, torrentCompleted bool) {
 	u.round = (u.round + 1) % 3
 }
 
+// ChokeAll chokes all peers, e.g. when uploading is not allowed.
+func (u *Unchoker) ChokeAll(allPeers []Peer) {
+	for _, pe := range allPeers {
+		u.chokePeer(pe)
+	}
+}
+
 func (u *Unchoker) chokePeer(pe Peer) {
 	if pe.Choking() {
 		return
@@ -33,7 +33,7 @@ func TestTickUnchoke(t *testing.T) {
 		}
 		return peers
 	}
-	u := New(2, 1)
+	u := New(2, 1, nil)
 
 	// Must unchoke fastest downloading 2 peers
 	u.round = 1
@@ -149,6 +149,7 @@ type TestPeer struct {
 	optimistic    bool
 	downloadSpeed int
 	uploadSpeed   int
+	completion    float64
 }
 
 func (p *TestPeer) Choke()                   { p.choking = true }
@@ -159,3 +160,89 @@ func (p *TestPeer) Optimistic() bool         { return p.optimistic }
 func (p *TestPeer) SetOptimistic(value bool) { p.optimistic = value }
 func (p *TestPeer) DownloadSpeed() int       { return p.downloadSpeed }
 func (p *TestPeer) UploadSpeed() int         { return p.uploadSpeed }
+func (p *TestPeer) Completion() float64      { return p.completion }
+
+func getPeers(testPeers []*TestPeer) []Peer {
+	peers := make([]Peer, len(testPeers))
+	for i := range peers {
+		peers[i] = testPeers[i]
+	}
+	return peers
+}
+
+func unchokedPeers(testPeers []*TestPeer) []int {
+	var ret []int
+	for i, pe := range testPeers {
+		if !pe.choking && !pe.optimistic {
+			ret = append(ret, i)
+		}
+	}
+	return ret
+}
+
+func TestRoundRobin(t *testing.T) {
+	testPeers := make([]*TestPeer, 4)
+	for i := range testPeers {
+		testPeers[i] = &TestPeer{interested: true, choking: true, uploadSpeed: 4 - i}
+	}
+	u := New(2, 0, NewRoundRobin(2))
+
+	u.round = 1
+	u.TickUnchoke(getPeers(testPeers), true)
+	assert.Equal(t, []int{0, 1}, unchokedPeers(testPeers))
+
+	// Unchoked peers keep their slots until they use their turn.
+	u.round = 1
+	u.TickUnchoke(getPeers(testPeers), true)
+	assert.Equal(t, []int{0, 1}, unchokedPeers(testPeers))
+
+	// Waiting peers are unchoked even though they are slower.
+	u.round = 1
+	u.TickUnchoke(getPeers(testPeers), true)
+	assert.Equal(t, []int{2, 3}, unchokedPeers(testPeers))
+
+	u.round = 1
+	u.TickUnchoke(getPeers(testPeers), true)
+	assert.Equal(t, []int{2, 3}, unchokedPeers(testPeers))
+
+	u.round = 1
+	u.TickUnchoke(getPeers(testPeers), true)
+	assert.Equal(t, []int{0, 1}, unchokedPeers(testPeers))
+}
+
+func TestAntiLeech(t *testing.T) {
+	testPeers := []*TestPeer{
+		{interested: true, choking: true, completion: 0.5, uploadSpeed: 10},
+		{interested: true, choking: true, completion: 0.95},
+		{interested: true, choking: true, completion: 0.4, uploadSpeed: 10},
+		{interested: true, choking: true, completion: 0.02},
+	}
+	u := New(2, 0, NewAntiLeech())
+	u.round = 1
+	u.TickUnchoke(getPeers(testPeers), true)
+	assert.Equal(t, []int{1, 3}, unchokedPeers(testPeers))
+
+	// Download speed is used while downloading.
+	testPeers[0].downloadSpeed = 10
+	testPeers[2].downloadSpeed = 5
+	u.round = 1
+	u.TickUnchoke(getPeers(testPeers), false)
+	assert.Equal(t, []int{0, 2}, unchokedPeers(testPeers))
+}
+
+func TestSlotTuner(t *testing.T) {
+	s := NewSlotTuner(2, 4)
+
+	// Slots are added while upload is below the limit.
+	assert.Equal(t, 3, s.Update(50<<10, 100<<10))
+	assert.Equal(t, 4, s.Update(60<<10, 100<<10))
+	assert.Equal(t, 4, s.Update(70<<10, 100<<10))
+
+	// Slots are kept while they are fast enough.
+	assert.Equal(t, 4, s.Update(100<<10, 100<<10))
+
+	// Slow slots are removed when upload is saturated.
+	assert.Equal(t, 3, s.Update(4<<10, 4<<10))
+	assert.Equal(t, 2, s.Update(4<<10, 4<<10))
+	assert.Equal(t, 2, s.Update(4<<10, 4<<10))
+}
@@ -11,14 +11,14 @@ import (
 
 	"github.com/cenkalti/rain/internal/bufferpool"
 	"github.com/cenkalti/rain/internal/piece"
-	"github.com/juju/ratelimit"
+	"github.com/cenkalti/rain/internal/speedlimit"
 )
 
 // URLDownloader downloads files from a HTTP source.
 type URLDownloader struct {
 	URL                 string
 	Begin, End, current uint32 // piece index
-	bucket              *ratelimit.Bucket
+	bucket              *speedlimit.Limiter
 	closeC, doneC       chan struct{}
 }
 
@@ -32,7 +32,7 @@ type PieceResult struct {
 }
 
 // New returns a new URLDownloader for the given source and piece range.
-func New(source string, begin, end uint32, b *ratelimit.Bucket) *URLDownloader {
+func New(source string, begin, end uint32, b *speedlimit.Limiter) *URLDownloader {
 	return &URLDownloader{
 		URL:     source,
 		Begin:   begin,
@@ -142,6 +142,22 @@ func main() {
 			},
 			Action: handleServer,
 		},
+		{
+			Name:  "tracker",
+			Usage: "run a standalone bittorrent tracker",
+			Flags: []cli.Flag{
+				cli.StringFlag{
+					Name:  "config,c",
+					Usage: "read config from `FILE`",
+					Value: "~/rain/config.yaml",
+				},
+				cli.BoolFlag{
+					Name:  "allow-all",
+					Usage: "serve all torrents instead of the ones in allowlist",
+				},
+			},
+			Action: handleTracker,
+		},
 		{
 			Name:  "client",
 			Usage: "send rpc request to server",
@@ -194,6 +210,18 @@ func main() {
 							Name:  "stop-after-metadata",
 							Usage: "stop the torrent after metadata download is finished",
 						},
+						cli.StringFlag{
+							Name:  "encryption",
+							Usage: "override encryption settings of the server: prefer, disable or force",
+						},
+						cli.StringFlag{
+							Name:  "bandwidth-priority",
+							Usage: "share of the torrent in global speed limits: low, normal, high or a number between 1 and 1000",
+						},
+						cli.BoolFlag{
+							Name:  "no-default-trackers",
+							Usage: "do not add default trackers of the server to the torrent",
+						},
 						cli.StringFlag{
 							Name:  "id",
 							Usage: "if id is not given, a unique id is automatically generated",
@@ -258,6 +286,30 @@ func main() {
 						},
 					},
 				},
+				{
+					Name:     "tracker-tiers",
+					Usage:    "get tracker tiers of torrent",
+					Category: "Getters",
+					Action:   handleTrackerTiers,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+					},
+				},
+				{
+					Name:     "tracker-history",
+					Usage:    "get recent announce results of torrent trackers",
+					Category: "Getters",
+					Action:   handleTrackerHistory,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+					},
+				},
 				{
 					Name:     "webseeds",
 					Usage:    "get webseed sources of torrent",
@@ -299,6 +351,19 @@ func main() {
 						},
 					},
 				},
+				{
+					Name:     "add-dht-node",
+					Usage:    "add node to DHT routing table",
+					Category: "Actions",
+					Action:   handleAddDHTNode,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "addr",
+							Usage:    "node address in host:port format",
+							Required: true,
+						},
+					},
+				},
 				{
 					Name:     "add-tracker",
 					Usage:    "add tracker to torrent",
@@ -316,6 +381,106 @@ func main() {
 						},
 					},
 				},
+				{
+					Name:     "remove-tracker",
+					Usage:    "remove tracker from torrent",
+					Category: "Actions",
+					Action:   handleRemoveTracker,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:     "tracker,t",
+							Required: true,
+							Usage:    "tracker URL",
+						},
+					},
+				},
+				{
+					Name:     "replace-tracker",
+					Usage:    "change URL of a tracker of torrent",
+					Category: "Actions",
+					Action:   handleReplaceTracker,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:     "old",
+							Required: true,
+							Usage:    "current tracker URL",
+						},
+						cli.StringFlag{
+							Name:     "new",
+							Required: true,
+							Usage:    "new tracker URL",
+						},
+					},
+				},
+				{
+					Name:     "replace-tracker-urls",
+					Usage:    "rewrite tracker URLs of all torrents",
+					Category: "Actions",
+					Action:   handleReplaceTrackerURLs,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "pattern,p",
+							Required: true,
+							Usage:    "regular expression to match tracker URLs",
+						},
+						cli.StringFlag{
+							Name:     "replacement,r",
+							Required: true,
+							Usage:    "replacement string, $1 denotes the first submatch",
+						},
+						cli.BoolFlag{
+							Name:  "dry-run",
+							Usage: "print changes without applying them",
+						},
+					},
+				},
+				{
+					Name:     "move-tracker-tier",
+					Usage:    "change order of tracker tiers of torrent",
+					Category: "Actions",
+					Action:   handleMoveTrackerTier,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+						cli.IntFlag{
+							Name:     "from",
+							Required: true,
+							Usage:    "current index of the tier",
+						},
+						cli.IntFlag{
+							Name:     "to",
+							Required: true,
+							Usage:    "new index of the tier",
+						},
+					},
+				},
+				{
+					Name:     "set-bandwidth-priority",
+					Usage:    "change share of torrent in global speed limits",
+					Category: "Actions",
+					Action:   handleSetBandwidthPriority,
+					Flags: []cli.Flag{
+						cli.StringFlag{
+							Name:     "id",
+							Required: true,
+						},
+						cli.StringFlag{
+							Name:     "priority,p",
+							Required: true,
+							Usage:    "low, normal, high or a number between 1 and 1000",
+						},
+					},
+				},
 				{
 					Name:     "announce",
 					Usage:    "announce to tracker",
@@ -674,6 +839,26 @@ func handleServer(c *cli.Context) error {
 	return ses.Close()
 }
 
+func handleTracker(c *cli.Context) error {
+	cfg, err := prepareConfig(c)
+	if err != nil {
+		return err
+	}
+	if c.Bool("allow-all") {
+		cfg.TrackerServerAllowAll = true
+	}
+	srv, err := torrent.NewTrackerServer(cfg)
+	if err != nil {
+		return err
+	}
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, syscall.SIGINT, syscall.SIGTERM)
+	s := <-ch
+	log.Noticef("received %s, stopping tracker", s)
+
+	return srv.Close()
+}
+
 func handleDownload(c *cli.Context) error {
 	arg := c.String("torrent")
 	seed := c.Bool("seed")
@@ -894,10 +1079,21 @@ func handleAdd(c *cli.Context) error {
 	var b []byte
 	var marshalErr error
 	arg := c.String("torrent")
+	var priority torrent.BandwidthPriority
+	if s := c.String("bandwidth-priority"); s != "" {
+		var err error
+		priority, err = torrent.ParseBandwidthPriority(s)
+		if err != nil {
+			return err
+		}
+	}
 	addOpt := &rainrpc.AddTorrentOptions{
 		Stopped:           c.Bool("stopped"),
 		StopAfterDownload: c.Bool("stop-after-download"),
 		StopAfterMetadata: c.Bool("stop-after-metadata"),
+		Encryption:        c.String("encryption"),
+		BandwidthPriority: int(priority),
+		NoDefaultTrackers: c.Bool("no-default-trackers"),
 		ID:                c.String("id"),
 	}
 	if isURI(arg) {
@@ -984,6 +1180,34 @@ func handleTrackers(c *cli.Context) error {
 	return nil
 }
 
+func handleTrackerTiers(c *cli.Context) error {
+	resp, err := clt.GetTorrentTrackerTiers(c.String("id"))
+	if err != nil {
+		return err
+	}
+	b, err := prettyjson.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, _ = os.Stdout.Write(b)
+	_, _ = os.Stdout.WriteString("\n")
+	return nil
+}
+
+func handleTrackerHistory(c *cli.Context) error {
+	resp, err := clt.GetTorrentTrackerHistory(c.String("id"))
+	if err != nil {
+		return err
+	}
+	b, err := prettyjson.Marshal(resp)
+	if err != nil {
+		return err
+	}
+	_, _ = os.Stdout.Write(b)
+	_, _ = os.Stdout.WriteString("\n")
+	return nil
+}
+
 func handleWebseeds(c *cli.Context) error {
 	resp, err := clt.GetTorrentWebseeds(c.String("id"))
 	if err != nil {
@@ -1016,10 +1240,45 @@ func handleAddPeer(c *cli.Context) error {
 	return clt.AddPeer(c.String("id"), c.String("addr"))
 }
 
+func handleAddDHTNode(c *cli.Context) error {
+	return clt.AddDHTNode(c.String("addr"))
+}
+
 func handleAddTracker(c *cli.Context) error {
 	return clt.AddTracker(c.String("id"), c.String("tracker"))
 }
 
+func handleRemoveTracker(c *cli.Context) error {
+	return clt.RemoveTracker(c.String("id"), c.String("tracker"))
+}
+
+func handleReplaceTracker(c *cli.Context) error {
+	return clt.ReplaceTracker(c.String("id"), c.String("old"), c.String("new"))
+}
+
+func handleReplaceTrackerURLs(c *cli.Context) error {
+	changes, err := clt.ReplaceTrackerURLs(c.String("pattern"), c.String("replacement"), c.Bool("dry-run"))
+	if err != nil {
+		return err
+	}
+	for _, ch := range changes {
+		fmt.Printf("%s: %s -> %s\n", ch.ID, ch.OldURL, ch.NewURL)
+	}
+	return nil
+}
+
+func handleMoveTrackerTier(c *cli.Context) error {
+	return clt.MoveTrackerTier(c.String("id"), c.Int("from"), c.Int("to"))
+}
+
+func handleSetBandwidthPriority(c *cli.Context) error {
+	priority, err := torrent.ParseBandwidthPriority(c.String("priority"))
+	if err != nil {
+		return err
+	}
+	return clt.SetTorrentBandwidthPriority(c.String("id"), int(priority))
+}
+
 func handleAnnounce(c *cli.Context) error {
 	return clt.AnnounceTorrent(c.String("id"))
 }
@@ -63,6 +63,12 @@ type AddTorrentOptions struct {
 	Stopped           bool
 	StopAfterDownload bool
 	StopAfterMetadata bool
+	// One of "prefer", "disable" or "force". Empty value uses the encryption settings of the server.
+	Encryption string
+	// Share of the torrent in the global speed limits, between 1 and 1000. Zero value means normal priority (4).
+	BandwidthPriority int
+	// Do not add the default trackers of the server to the torrent.
+	NoDefaultTrackers bool
 }
 
 // AddTorrent adds a new torrent by reading .torrent file.
@@ -77,6 +83,9 @@ func (c *Client) AddTorrent(f io.Reader, options *AddTorrentOptions) (*rpctypes.
 		args.AddTorrentOptions.Stopped = options.Stopped
 		args.AddTorrentOptions.StopAfterDownload = options.StopAfterDownload
 		args.AddTorrentOptions.StopAfterMetadata = options.StopAfterMetadata
+		args.AddTorrentOptions.Encryption = options.Encryption
+		args.AddTorrentOptions.BandwidthPriority = options.BandwidthPriority
+		args.AddTorrentOptions.NoDefaultTrackers = options.NoDefaultTrackers
 	}
 	var reply rpctypes.AddTorrentResponse
 	return &reply.Torrent, c.client.Call("Session.AddTorrent", args, &reply)
@@ -90,6 +99,9 @@ func (c *Client) AddURI(uri string, options *AddTorrentOptions) (*rpctypes.Torre
 		args.AddTorrentOptions.Stopped = options.Stopped
 		args.AddTorrentOptions.StopAfterDownload = options.StopAfterDownload
 		args.AddTorrentOptions.StopAfterMetadata = options.StopAfterMetadata
+		args.AddTorrentOptions.Encryption = options.Encryption
+		args.AddTorrentOptions.BandwidthPriority = options.BandwidthPriority
+		args.AddTorrentOptions.NoDefaultTrackers = options.NoDefaultTrackers
 	}
 	var reply rpctypes.AddURIResponse
 	return &reply.Torrent, c.client.Call("Session.AddURI", args, &reply)
@@ -220,9 +232,87 @@ func (c *Client) AddPeer(id string, addr string) error {
 	return c.client.Call("Session.AddPeer", args, &reply)
 }
 
+// AddDHTNode adds a node to the DHT routing table of the session.
+func (c *Client) AddDHTNode(addr string) error {
+	args := rpctypes.AddDHTNodeRequest{Addr: addr}
+	var reply rpctypes.AddDHTNodeResponse
+	return c.client.Call("Session.AddDHTNode", args, &reply)
+}
+
 // AddTracker adds a new tracker to a torrent.
 func (c *Client) AddTracker(id string, uri string) error {
 	args := rpctypes.AddTrackerRequest{ID: id, URL: uri}
 	var reply rpctypes.AddTrackerResponse
 	return c.client.Call("Session.AddTracker", args, &reply)
 }
+
+// RemoveTracker removes a tracker from a torrent.
+func (c *Client) RemoveTracker(id string, uri string) error {
+	args := rpctypes.RemoveTrackerRequest{ID: id, URL: uri}
+	var reply rpctypes.RemoveTrackerResponse
+	return c.client.Call("Session.RemoveTracker", args, &reply)
+}
+
+// ReplaceTracker changes the URL of a tracker of a torrent.
+func (c *Client) ReplaceTracker(id string, oldURI, newURI string) error {
+	args := rpctypes.ReplaceTrackerRequest{ID: id, OldURL: oldURI, NewURL: newURI}
+	var reply rpctypes.ReplaceTrackerResponse
+	return c.client.Call("Session.ReplaceTracker", args, &reply)
+}
+
+// MoveTrackerTier changes the order of tracker tiers of a torrent.
+func (c *Client) MoveTrackerTier(id string, from, to int) error {
+	args := rpctypes.MoveTrackerTierRequest{ID: id, From: from, To: to}
+	var reply rpctypes.MoveTrackerTierResponse
+	return c.client.Call("Session.MoveTrackerTier", args, &reply)
+}
+
+// GetTorrentTrackerHistory returns the results of the recent announce requests to the trackers of the torrent.
+func (c *Client) GetTorrentTrackerHistory(id string) ([]rpctypes.TrackerAnnounce, error) {
+	args := rpctypes.GetTorrentTrackerHistoryRequest{ID: id}
+	var reply rpctypes.GetTorrentTrackerHistoryResponse
+	return reply.History, c.client.Call("Session.GetTorrentTrackerHistory", args, &reply)
+}
+
+// GetTorrentTrackerTiers returns the tracker URLs of a torrent grouped in tiers.
+func (c *Client) GetTorrentTrackerTiers(id string) ([][]string, error) {
+	args := rpctypes.GetTorrentTrackerTiersRequest{ID: id}
+	var reply rpctypes.GetTorrentTrackerTiersResponse
+	return reply.Tiers, c.client.Call("Session.GetTorrentTrackerTiers", args, &reply)
+}
+
+// ReplaceTrackerURLs rewrites the tracker URLs matching the regular expression in all torrents.
+// If dryRun is true, the changes are returned without applying them.
+func (c *Client) ReplaceTrackerURLs(pattern, replacement string, dryRun bool) ([]rpctypes.TrackerURLChange, error) {
+	args := rpctypes.ReplaceTrackerURLsRequest{Pattern: pattern, Replacement: replacement, DryRun: dryRun}
+	var reply rpctypes.ReplaceTrackerURLsResponse
+	return reply.Changes, c.client.Call("Session.ReplaceTrackerURLs", args, &reply)
+}
+
+// SetSpeedLimits changes the global download and upload speed limits in KB/s. Zero means unlimited.
+func (c *Client) SetSpeedLimits(download, upload int64) error {
+	args := rpctypes.SetSpeedLimitsRequest{Download: download, Upload: upload}
+	var reply rpctypes.SetSpeedLimitsResponse
+	return c.client.Call("Session.SetSpeedLimits", args, &reply)
+}
+
+// SetTorrentBandwidthPriority changes the share of a torrent in the global speed limits.
+func (c *Client) SetTorrentBandwidthPriority(id string, priority int) error {
+	args := rpctypes.SetTorrentBandwidthPriorityRequest{ID: id, Priority: priority}
+	var reply rpctypes.SetTorrentBandwidthPriorityResponse
+	return c.client.Call("Session.SetTorrentBandwidthPriority", args, &reply)
+}
+
+// SetTurtleMode enables or disables the turtle mode that applies alternative global speed limits.
+func (c *Client) SetTurtleMode(enabled bool) error {
+	args := rpctypes.SetTurtleModeRequest{Enabled: enabled}
+	var reply rpctypes.SetTurtleModeResponse
+	return c.client.Call("Session.SetTurtleMode", args, &reply)
+}
+
+// SetTorrentSpeedLimits changes the download and upload speed limits of a torrent in KB/s. Zero means unlimited.
+func (c *Client) SetTorrentSpeedLimits(id string, download, upload int64) error {
+	args := rpctypes.SetTorrentSpeedLimitsRequest{ID: id, Download: download, Upload: upload}
+	var reply rpctypes.SetTorrentSpeedLimitsResponse
+	return c.client.Call("Session.SetTorrentSpeedLimits", args, &reply)
+}
@@ -9,22 +9,29 @@ import (
 	"net/http"
 	"os"
 	"path/filepath"
-	"strings"
 	"sync"
 	"time"
 
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/blocklist"
+	"github.com/cenkalti/rain/internal/connguard"
+	"github.com/cenkalti/rain/internal/connlimiter"
+	"github.com/cenkalti/rain/internal/encryptioncache"
+	"github.com/cenkalti/rain/internal/externalip"
 	"github.com/cenkalti/rain/internal/logger"
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/piececache"
+	"github.com/cenkalti/rain/internal/portmap"
+	"github.com/cenkalti/rain/internal/proxy"
 	"github.com/cenkalti/rain/internal/resolver"
 	"github.com/cenkalti/rain/internal/resourcemanager"
 	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
 	"github.com/cenkalti/rain/internal/semaphore"
+	"github.com/cenkalti/rain/internal/speedlimit"
 	"github.com/cenkalti/rain/internal/tracker"
+	"github.com/cenkalti/rain/internal/tracker/udptracker"
 	"github.com/cenkalti/rain/internal/trackermanager"
-	"github.com/juju/ratelimit"
+	"github.com/cenkalti/rain/internal/trafficcounter"
 	"github.com/mitchellh/go-homedir"
 	"github.com/nictuku/dht"
 	"go.etcd.io/bbolt"
@@ -45,22 +52,50 @@ type Session struct {
 	resumer        *boltdbresumer.Resumer
 	log            logger.Logger
 	extensions     [8]byte
-	dht            *dht.DHT
 	rpc            *rpcServer
+	trackerServer  *TrackerServer
 	trackerManager *trackermanager.TrackerManager
 	ram            *resourcemanager.ResourceManager[*peer.Peer]
 	pieceCache     *piececache.Cache
 	webseedClient  http.Client
+	dialer         proxy.Dialer
+	// Binds outgoing connections to the configured interface or IP. Nil if not configured.
+	outgoingDialer *outgoingDialer
+	portMapper     *portmap.PortMapper
+	connLimiter    *connlimiter.Limiter
+	connGuard      *connguard.Guard
+	// Remembers the handshake type accepted by peer addresses in all torrents.
+	encryptionCache *encryptioncache.Cache
+	proxy           *proxy.Proxy
+	// Transport for HTTP clients other than trackers and WebSeed sources. Nil if default dialer is used.
+	httpTransport  http.RoundTripper
 	createdAt      time.Time
 	semWrite       *semaphore.Semaphore
 	metrics        *sessionMetrics
-	bucketDownload *ratelimit.Bucket
-	bucketUpload   *ratelimit.Bucket
+	bucketDownload *speedlimit.Limiter
+	bucketUpload   *speedlimit.Limiter
 	closeC         chan struct{}
 
+	mDHT sync.RWMutex
+	// Nil while the outgoing interface is not available.
+	dht *dht.DHT
+	// Address that the DHT node is bound to if an outgoing interface is configured.
+	dhtAddress string
+	// Closed to stop the processDHTResults goroutine when the DHT node is replaced.
+	dhtStopC chan struct{}
+
+	mInterfaceStopped sync.Mutex
+	// IDs of the torrents that are stopped because the outgoing interface is not available.
+	interfaceStopped map[string]struct{}
+
 	mPeerRequests   sync.Mutex
 	dhtPeerRequests map[*torrent]struct{}
 
+	mDHTNodes sync.Mutex
+	// DHT nodes learned from peers or added manually and the last time they are seen. Saved to be used on next start.
+	// These are not the nodes in the routing table of the DHT.
+	dhtNodes map[string]time.Time
+
 	mTorrents          sync.RWMutex
 	torrents           map[string]*Torrent
 	torrentsByInfoHash map[dht.InfoHash][]*Torrent
@@ -72,6 +107,28 @@ type Session struct {
 	mBlocklist         sync.RWMutex
 	blocklist          *blocklist.Blocklist
 	blocklistTimestamp time.Time
+
+	mCustomExtensions sync.RWMutex
+	customExtensions  []registeredExtension
+
+	mDefaultTrackers sync.RWMutex
+	defaultTrackers  [][]string
+
+	mSpeedLimits sync.Mutex
+	// Global limits in KB/s that are used when turtle mode is disabled and no schedule entry is active.
+	speedLimitDownload      int64
+	speedLimitUpload        int64
+	speedLimitSchedule      []scheduledSpeedLimit
+	turtleMode              bool
+	activeSpeedLimitProfile string
+
+	mTraffic sync.Mutex
+	traffic  *trafficcounter.Counter
+	// Values of download and upload meters when traffic is counted last time.
+	trafficLastDownloaded   int64
+	trafficLastUploaded     int64
+	uploadsStoppedByQuota   bool
+	downloadsStoppedByQuota bool
 }
 
 // NewSession creates a new Session for downloading and seeding torrents.
@@ -80,13 +137,25 @@ func NewSession(cfg Config) (*Session, error) {
 	if cfg.PortBegin >= cfg.PortEnd {
 		return nil, errors.New("invalid port range")
 	}
+	if cfg.ProxyOnly && cfg.Proxy == "" {
+		return nil, errors.New("proxy-only mode requires a proxy")
+	}
+	if !validSeedChokingAlgorithm(cfg.SeedChokingAlgorithm) {
+		return nil, errors.New("unknown seed choking algorithm: " + cfg.SeedChokingAlgorithm)
+	}
+	if !validTrafficQuotaAction(cfg.TrafficQuotaAction) {
+		return nil, errors.New("unknown traffic quota action: " + cfg.TrafficQuotaAction)
+	}
+	speedLimitSchedule, err := parseSpeedLimitSchedule(cfg.SpeedLimitSchedule)
+	if err != nil {
+		return nil, errors.New("invalid speed limit schedule: " + err.Error())
+	}
 	if cfg.MaxOpenFiles > 0 {
 		err := setNoFile(cfg.MaxOpenFiles)
 		if err != nil {
 			return nil, errors.New("cannot change max open files limit: " + err.Error())
 		}
 	}
-	var err error
 	cfg.Database, err = homedir.Expand(cfg.Database)
 	if err != nil {
 		return nil, err
@@ -100,6 +169,61 @@ func NewSession(cfg Config) (*Session, error) {
 		return nil, err
 	}
 	l := logger.New("session")
+	var dialer proxy.Dialer = &net.Dialer{}
+	var px *proxy.Proxy
+	// Host names of trackers and WebSeed sources are resolved by the proxy server if true.
+	var remoteDNS bool
+	var httpTransport http.RoundTripper
+	var listenUDP udptracker.ListenFunc
+	var od *outgoingDialer
+	if cfg.OutgoingInterface != "" || cfg.OutgoingIP != "" {
+		od, err = newOutgoingDialer(cfg.OutgoingInterface, cfg.OutgoingIP)
+		if err != nil {
+			return nil, err
+		}
+		dialer = od
+		httpTransport = &http.Transport{DialContext: od.DialContext}
+		listenUDP = od.ListenUDP
+	}
+	if cfg.Proxy != "" {
+		px, err = proxy.New(cfg.Proxy, dialer)
+		if err != nil {
+			return nil, err
+		}
+		dialer = px
+		httpTransport = &http.Transport{DialContext: px.DialContext}
+		switch {
+		case px.SupportsUDP():
+			listenUDP = func() (net.PacketConn, error) {
+				ctx, cancel := context.WithTimeout(context.Background(), cfg.TrackerHTTPTimeout)
+				defer cancel()
+				return px.ListenPacket(ctx)
+			}
+		case cfg.ProxyOnly:
+			listenUDP = func() (net.PacketConn, error) {
+				return nil, errors.New("udp trackers cannot be used with http proxy in proxy-only mode")
+			}
+		}
+		remoteDNS = px.RemoteDNS() || cfg.ProxyOnly
+		if cfg.ProxyOnly {
+			l.Info("proxy-only mode is enabled, incoming connections, DHT and port mapping are disabled")
+			cfg.DHTEnabled = false
+			cfg.PortMappingEnabled = false
+		}
+	}
+	// Tracker server is created before other resources are acquired because its ports may be in use.
+	var trackerServer *TrackerServer
+	if cfg.TrackerServerEnabled {
+		trackerServer, err = NewTrackerServer(cfg)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			if err != nil {
+				trackerServer.Close()
+			}
+		}()
+	}
 	db, err := bbolt.Open(cfg.Database, cfg.FilePermissions&^0111, &bbolt.Options{Timeout: time.Second})
 	if err == bbolt.ErrTimeout {
 		return nil, errors.New("resume database is locked by another process")
@@ -134,20 +258,31 @@ func NewSession(cfg Config) (*Session, error) {
 		return nil, err
 	}
 	var dhtNode *dht.DHT
+	var dhtAddress string
 	if cfg.DHTEnabled {
-		dhtConfig := dht.NewConfig()
-		dhtConfig.Address = cfg.DHTHost
-		dhtConfig.Port = int(cfg.DHTPort)
-		dhtConfig.DHTRouters = strings.Join(cfg.DHTBootstrapNodes, ",")
-		dhtConfig.SaveRoutingTable = false
-		dhtConfig.NumTargetPeers = 0
-		dhtNode, err = dht.New(dhtConfig)
-		if err != nil {
-			return nil, err
+		dhtAddress = cfg.DHTHost
+		var ipErr error
+		if od != nil {
+			var ip net.IP
+			ip, ipErr = od.localIP()
+			if ipErr != nil {
+				// Only possible if an interface is configured. DHT is started by watchOutgoingInterface when it becomes available.
+				l.Warningln("DHT is not started, outgoing interface is not available:", ipErr.Error())
+				dhtAddress = ""
+			} else {
+				dhtAddress = ip.String()
+			}
 		}
-		err = dhtNode.Start()
-		if err != nil {
-			return nil, err
+		if ipErr == nil {
+			dhtNode, err = newDHTNode(&cfg, dhtAddress)
+			if err != nil {
+				return nil, err
+			}
+			defer func() {
+				if err != nil {
+					dhtNode.Stop()
+				}
+			}()
 		}
 	}
 	ports := make(map[int]struct{})
@@ -164,29 +299,52 @@ func NewSession(cfg Config) (*Session, error) {
 		db:                 db,
 		resumer:            res,
 		blocklist:          bl,
-		trackerManager:     trackermanager.New(blTracker, cfg.DNSResolveTimeout, !cfg.TrackerHTTPVerifyTLS),
+		trackerManager:     trackermanager.New(blTracker, cfg.DNSResolveTimeout, !cfg.TrackerHTTPVerifyTLS, dialer, remoteDNS, listenUDP, trackerHTTPSettings(cfg.TrackerHTTPSettings)),
 		log:                l,
 		torrents:           make(map[string]*Torrent),
 		torrentsByInfoHash: make(map[dht.InfoHash][]*Torrent),
 		availablePorts:     ports,
 		dht:                dhtNode,
+		dhtAddress:         dhtAddress,
+		trackerServer:      trackerServer,
+		interfaceStopped:   make(map[string]struct{}),
 		pieceCache:         piececache.New(cfg.ReadCacheSize, cfg.ReadCacheTTL, cfg.ParallelReads),
 		ram:                resourcemanager.New[*peer.Peer](cfg.WriteCacheSize),
 		createdAt:          time.Now(),
 		semWrite:           semaphore.New(int(cfg.ParallelWrites)),
+		traffic:            trafficcounter.New(cfg.TrafficMonthStartDay),
 		closeC:             make(chan struct{}),
+		dialer:             dialer,
+		outgoingDialer:     od,
+		proxy:              px,
+		httpTransport:      httpTransport,
+		connLimiter:        connlimiter.New(cfg.MaxSessionPeers, cfg.MaxSessionHalfOpen, cfg.MinPeersPerTorrent),
+		encryptionCache:    encryptioncache.New(cfg.EncryptionCacheSize, cfg.EncryptionCacheTTL),
+		connGuard: connguard.New(connguard.Config{
+			MaxPerIP:    cfg.MaxIncomingPerIP,
+			Rate:        cfg.IncomingRatePerIP,
+			Burst:       cfg.IncomingBurstPerIP,
+			MaxFailures: cfg.MaxHandshakeFailures,
+			Penalty:     cfg.HandshakePenaltyDuration,
+		}),
 		webseedClient: http.Client{
 			Transport: &http.Transport{
 				DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
-					ip, port, err := resolver.Resolve(ctx, addr, cfg.DNSResolveTimeout, bl)
-					if err != nil {
-						return nil, err
+					if remoteDNS {
+						err := resolver.Check(addr, bl)
+						if err != nil {
+							return nil, err
+						}
+					} else {
+						ip, port, err := resolver.Resolve(ctx, addr, cfg.DNSResolveTimeout, bl)
+						if err != nil {
+							return nil, err
+						}
+						addr = (&net.TCPAddr{IP: ip, Port: port}).String()
 					}
-					var d net.Dialer
-					taddr := &net.TCPAddr{IP: ip, Port: port}
 					dctx, cancel := context.WithTimeout(ctx, cfg.WebseedDialTimeout)
 					defer cancel()
-					return d.DialContext(dctx, network, taddr.String())
+					return dialer.DialContext(dctx, network, addr)
 				},
 				TLSHandshakeTimeout:   cfg.WebseedTLSHandshakeTimeout,
 				TLSClientConfig:       &tls.Config{InsecureSkipVerify: !cfg.WebseedVerifyTLS}, // nolint: gosec
@@ -194,13 +352,16 @@ func NewSession(cfg Config) (*Session, error) {
 			},
 		},
 	}
-	dlSpeed := cfg.SpeedLimitDownload * 1024
-	if cfg.SpeedLimitDownload > 0 {
-		c.bucketDownload = ratelimit.NewBucketWithRate(float64(dlSpeed), dlSpeed)
-	}
-	ulSpeed := cfg.SpeedLimitUpload * 1024
-	if cfg.SpeedLimitUpload > 0 {
-		c.bucketUpload = ratelimit.NewBucketWithRate(float64(ulSpeed), ulSpeed)
+	c.bucketDownload = speedlimit.New(0, nil)
+	c.bucketUpload = speedlimit.New(0, nil)
+	c.speedLimitDownload = cfg.SpeedLimitDownload
+	c.speedLimitUpload = cfg.SpeedLimitUpload
+	c.speedLimitSchedule = speedLimitSchedule
+	c.activeSpeedLimitProfile = SpeedLimitProfileNormal
+	c.applySpeedLimits(time.Now())
+	err = c.loadTraffic()
+	if err != nil {
+		return nil, err
 	}
 	err = c.startBlocklistReloader()
 	if err != nil {
@@ -215,8 +376,24 @@ func NewSession(cfg Config) (*Session, error) {
 	if cfg.DHTEnabled {
 		ext.Set(63) // DHT Protocol (BEP 5)
 		c.dhtPeerRequests = make(map[*torrent]struct{})
+		c.dhtNodes = make(map[string]time.Time)
+		if err2 := c.loadDHTNodes(); err2 != nil {
+			c.log.Errorln("cannot load DHT nodes:", err2)
+		}
+	}
+	if cfg.PortMappingEnabled {
+		c.portMapper = portmap.New(externalip.SetMapped)
+		c.portMapper.Start()
+		if cfg.DHTEnabled {
+			c.portMapper.Add(portmap.UDP, int(cfg.DHTPort))
+		}
 	}
 	c.initMetrics()
+	c.updateTraffic(time.Now())
+	if err2 := c.loadDefaultTrackers(); err2 != nil {
+		c.log.Errorln("cannot load default trackers:", err2)
+		c.defaultTrackers = cfg.DefaultTrackers
+	}
 	c.loadExistingTorrents(ids)
 	if c.config.RPCEnabled {
 		c.rpc = newRPCServer(c)
@@ -225,8 +402,22 @@ func NewSession(cfg Config) (*Session, error) {
 			return nil, err
 		}
 	}
-	if cfg.DHTEnabled {
-		go c.processDHTResults()
+	if dhtNode != nil {
+		c.dhtStopC = make(chan struct{})
+		go c.processDHTResults(dhtNode, c.dhtStopC)
+	}
+	if cfg.OutgoingInterface != "" {
+		go c.watchOutgoingInterface()
+	}
+	if len(speedLimitSchedule) > 0 {
+		go c.speedLimitScheduler()
+	}
+	go c.trafficLoop()
+	if cfg.TrackerScrapeInterval > 0 {
+		go c.scraper()
+	}
+	if cfg.DefaultTrackersFile != "" && cfg.DefaultTrackersFileReloadInterval > 0 {
+		go c.defaultTrackersReloader()
 	}
 	go c.updateStatsLoop()
 	return c, nil
@@ -234,9 +425,15 @@ func NewSession(cfg Config) (*Session, error) {
 
 func (s *Session) parseTrackers(tiers [][]string, private bool) []tracker.Tracker {
 	ret := make([]tracker.Tracker, 0, len(tiers))
+	seen := make(map[string]struct{})
 	for _, tier := range tiers {
 		trackers := make([]tracker.Tracker, 0, len(tier))
 		for _, tr := range tier {
+			// Same tracker may be given in multiple tiers.
+			if _, ok := seen[tr]; ok {
+				continue
+			}
+			seen[tr] = struct{}{}
 			t, err := s.trackerManager.Get(tr, s.config.TrackerHTTPTimeout, s.getTrackerUserAgent(private), int64(s.config.TrackerHTTPMaxResponseSize))
 			if err != nil {
 				continue
@@ -262,9 +459,11 @@ func (s *Session) getTrackerUserAgent(private bool) string {
 func (s *Session) Close() error {
 	close(s.closeC)
 
-	if s.config.DHTEnabled {
-		s.dht.Stop()
+	s.mDHT.Lock()
+	if s.dht != nil {
+		stopDHTNode(s.dht)
 	}
+	s.mDHT.Unlock()
 
 	s.updateStats()
 
@@ -288,6 +487,17 @@ func (s *Session) Close() error {
 		}
 	}
 
+	if s.trackerServer != nil {
+		err := s.trackerServer.Close()
+		if err != nil {
+			s.log.Errorln("cannot stop tracker server:", err.Error())
+		}
+	}
+
+	if s.portMapper != nil {
+		s.portMapper.Close()
+	}
+
 	s.ram.Close()
 	s.pieceCache.Close()
 	s.trackerManager.Close()
@@ -364,10 +574,18 @@ func (s *Session) removeTorrentFromClient(id string) (*Torrent, error) {
 	// We need to make sure that we are not holding any lock that cause a block in DHT loop.
 	// DHT.PeersRequestResults tries to hold the same lock (mTorrents) when a message is received from
 	// DHT.PeersRequestResults. That's why we are releasing the lock before calling DHT.RemoveInfoHash.
+	remaining := len(s.torrentsByInfoHash[ih])
 	s.mTorrents.Unlock()
 
-	if s.config.DHTEnabled && len(s.torrentsByInfoHash[ih]) == 0 {
-		s.dht.RemoveInfoHash(string(ih))
+	if s.config.DHTEnabled && remaining == 0 {
+		s.mDHT.RLock()
+		if s.dht != nil {
+			s.dht.RemoveInfoHash(string(ih))
+		}
+		s.mDHT.RUnlock()
+	}
+	if s.trackerServer != nil && remaining == 0 {
+		s.trackerServer.Disallow(t.InfoHash())
 	}
 	return t, s.db.Update(func(tx *bbolt.Tx) error {
 		return tx.Bucket(torrentsBucket).DeleteBucket([]byte(id))
@@ -31,6 +31,12 @@ type AddTorrentOptions struct {
 	StopAfterDownload bool
 	// Stop torrent after metadata is downloaded from magnet links.
 	StopAfterMetadata bool
+	// Overrides the encryption settings in Config for this torrent.
+	Encryption EncryptionPolicy
+	// Share of the torrent in the global speed limits. Zero value means BandwidthPriorityNormal.
+	BandwidthPriority BandwidthPriority
+	// Do not add Config.DefaultTrackers to the torrent.
+	NoDefaultTrackers bool
 }
 
 // AddTorrent adds a new torrent to the session by reading .torrent metainfo from reader.
@@ -75,6 +81,11 @@ func (s *Session) addTorrentStopped(r io.Reader, opt *AddTorrentOptions) (*Torre
 			s.releasePort(port)
 		}
 	}()
+	announceKey, err := newAnnounceKey()
+	if err != nil {
+		return nil, err
+	}
+	trackers := s.withDefaultTrackers(mi.AnnounceList, mi.Info.Private, opt)
 	t, err := newTorrent2(
 		s,
 		id,
@@ -83,7 +94,7 @@ func (s *Session) addTorrentStopped(r io.Reader, opt *AddTorrentOptions) (*Torre
 		sto,
 		mi.Info.Name,
 		port,
-		s.parseTrackers(mi.AnnounceList, mi.Info.Private),
+		s.parseTrackers(trackers, mi.Info.Private),
 		nil, // fixedPeers
 		&mi.Info,
 		nil, // bitfield
@@ -92,10 +103,15 @@ func (s *Session) addTorrentStopped(r io.Reader, opt *AddTorrentOptions) (*Torre
 		opt.StopAfterDownload,
 		opt.StopAfterMetadata,
 		false, // completeCmdRun
+		opt.Encryption,
+		0, 0, // speed limits
+		opt.BandwidthPriority,
+		announceKey,
 	)
 	if err != nil {
 		return nil, err
 	}
+	t.rawTrackers = trackers
 	go s.checkTorrent(t)
 	defer func() {
 		if err != nil {
@@ -106,12 +122,15 @@ func (s *Session) addTorrentStopped(r io.Reader, opt *AddTorrentOptions) (*Torre
 		InfoHash:          mi.Info.Hash[:],
 		Port:              port,
 		Name:              mi.Info.Name,
-		Trackers:          mi.AnnounceList,
+		Trackers:          trackers,
 		URLList:           mi.URLList,
 		Info:              mi.Info.Bytes,
 		AddedAt:           t.addedAt,
 		StopAfterDownload: opt.StopAfterDownload,
 		StopAfterMetadata: opt.StopAfterMetadata,
+		Encryption:        string(opt.Encryption),
+		BandwidthPriority: int(opt.BandwidthPriority),
+		AnnounceKey:       announceKey,
 	}
 	err = s.resumer.Write(id, rspec)
 	if err != nil {
@@ -159,7 +178,8 @@ func filterOutControlChars(s string) string {
 
 func (s *Session) addURL(u string, opt *AddTorrentOptions) (*Torrent, error) {
 	client := http.Client{
-		Timeout: s.config.TorrentAddHTTPTimeout,
+		Timeout:   s.config.TorrentAddHTTPTimeout,
+		Transport: s.httpTransport,
 	}
 	resp, err := client.Get(u) // nolint: noctx
 	if err != nil {
@@ -188,6 +208,11 @@ func (s *Session) addMagnet(link string, opt *AddTorrentOptions) (*Torrent, erro
 			s.releasePort(port)
 		}
 	}()
+	announceKey, err := newAnnounceKey()
+	if err != nil {
+		return nil, err
+	}
+	trackers := s.withDefaultTrackers(ma.Trackers, false, opt)
 	t, err := newTorrent2(
 		s,
 		id,
@@ -196,7 +221,7 @@ func (s *Session) addMagnet(link string, opt *AddTorrentOptions) (*Torrent, erro
 		sto,
 		ma.Name,
 		port,
-		s.parseTrackers(ma.Trackers, false),
+		s.parseTrackers(trackers, false),
 		ma.Peers,
 		nil, // info
 		nil, // bitfield
@@ -205,10 +230,15 @@ func (s *Session) addMagnet(link string, opt *AddTorrentOptions) (*Torrent, erro
 		opt.StopAfterDownload,
 		opt.StopAfterMetadata,
 		false, // completeCmdRun
+		opt.Encryption,
+		0, 0, // speed limits
+		opt.BandwidthPriority,
+		announceKey,
 	)
 	if err != nil {
 		return nil, err
 	}
+	t.rawTrackers = trackers
 	go s.checkTorrent(t)
 	defer func() {
 		if err != nil {
@@ -219,11 +249,14 @@ func (s *Session) addMagnet(link string, opt *AddTorrentOptions) (*Torrent, erro
 		InfoHash:          ma.InfoHash[:],
 		Port:              port,
 		Name:              ma.Name,
-		Trackers:          ma.Trackers,
+		Trackers:          trackers,
 		FixedPeers:        ma.Peers,
 		AddedAt:           t.addedAt,
 		StopAfterDownload: opt.StopAfterDownload,
 		StopAfterMetadata: opt.StopAfterMetadata,
+		Encryption:        string(opt.Encryption),
+		BandwidthPriority: int(opt.BandwidthPriority),
+		AnnounceKey:       announceKey,
 	}
 	err = s.resumer.Write(id, rspec)
 	if err != nil {
@@ -237,6 +270,14 @@ func (s *Session) addMagnet(link string, opt *AddTorrentOptions) (*Torrent, erro
 }
 
 func (s *Session) add(opt *AddTorrentOptions) (id string, port int, sto *filestorage.FileStorage, err error) {
+	if !opt.Encryption.valid() {
+		err = newInputError(errInvalidEncryptionPolicy)
+		return
+	}
+	if opt.BandwidthPriority != 0 && !opt.BandwidthPriority.valid() {
+		err = newInputError(errInvalidBandwidthPriority)
+		return
+	}
 	port, err = s.getPort()
 	if err != nil {
 		return
@@ -283,5 +324,8 @@ func (s *Session) insertTorrent(t *torrent) *Torrent {
 	s.torrents[t.id] = t2
 	ih := dht.InfoHash(t.InfoHash())
 	s.torrentsByInfoHash[ih] = append(s.torrentsByInfoHash[ih], t2)
+	if s.trackerServer != nil {
+		s.trackerServer.Allow(t2.InfoHash())
+	}
 	return t2
 }
@@ -118,7 +118,8 @@ func (s *Session) reloadBlocklist() error {
 	req = req.WithContext(ctx)
 
 	client := http.Client{
-		Timeout: s.config.BlocklistUpdateTimeout,
+		Timeout:   s.config.BlocklistUpdateTimeout,
+		Transport: s.httpTransport,
 	}
 
 	resp, err := client.Do(req)
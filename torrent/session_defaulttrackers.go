@@ -0,0 +1,137 @@
+package torrent
+
+import (
+	"bufio"
+	"io"
+	"os"
+	"strings"
+	"time"
+)
+
+// loadDefaultTrackers sets the default trackers from the Config and the file.
+// If the file cannot be read, previously loaded trackers are kept.
+func (s *Session) loadDefaultTrackers() error {
+	tiers := s.config.DefaultTrackers
+	if s.config.DefaultTrackersFile != "" {
+		fileTiers, err := readTrackersFile(s.config.DefaultTrackersFile)
+		if err != nil {
+			return err
+		}
+		tiers = mergeTrackers(tiers, fileTiers)
+	}
+	s.mDefaultTrackers.Lock()
+	s.defaultTrackers = tiers
+	s.mDefaultTrackers.Unlock()
+	return nil
+}
+
+func (s *Session) defaultTrackersReloader() {
+	ticker := time.NewTicker(s.config.DefaultTrackersFileReloadInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			err := s.loadDefaultTrackers()
+			if err != nil {
+				s.log.Errorln("cannot load default trackers:", err)
+			}
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+// withDefaultTrackers returns the tiers of a public torrent after the default trackers are appended.
+func (s *Session) withDefaultTrackers(tiers [][]string, private bool, opt *AddTorrentOptions) [][]string {
+	if private || opt.NoDefaultTrackers {
+		return tiers
+	}
+	s.mDefaultTrackers.RLock()
+	defer s.mDefaultTrackers.RUnlock()
+	return mergeTrackers(tiers, s.defaultTrackers)
+}
+
+// withoutDefaultTrackers returns the tiers after the URLs of the default trackers are removed.
+// Tiers that become empty are removed as well.
+func (s *Session) withoutDefaultTrackers(tiers [][]string) [][]string {
+	s.mDefaultTrackers.RLock()
+	defer s.mDefaultTrackers.RUnlock()
+	defaults := make(map[string]struct{})
+	for _, tier := range s.defaultTrackers {
+		for _, u := range tier {
+			defaults[u] = struct{}{}
+		}
+	}
+	ret := make([][]string, 0, len(tiers))
+	for _, tier := range tiers {
+		var urls []string
+		for _, u := range tier {
+			if _, ok := defaults[u]; !ok {
+				urls = append(urls, u)
+			}
+		}
+		if len(urls) > 0 {
+			ret = append(ret, urls)
+		}
+	}
+	return ret
+}
+
+// mergeTrackers appends the tiers in b to a. URLs that already exist in a are not appended.
+func mergeTrackers(a, b [][]string) [][]string {
+	seen := make(map[string]struct{})
+	ret := make([][]string, 0, len(a)+len(b))
+	for _, tier := range a {
+		ret = append(ret, tier)
+		for _, u := range tier {
+			seen[u] = struct{}{}
+		}
+	}
+	for _, tier := range b {
+		var urls []string
+		for _, u := range tier {
+			if _, ok := seen[u]; ok {
+				continue
+			}
+			seen[u] = struct{}{}
+			urls = append(urls, u)
+		}
+		if len(urls) > 0 {
+			ret = append(ret, urls)
+		}
+	}
+	return ret
+}
+
+func readTrackersFile(name string) ([][]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return parseTrackersList(f)
+}
+
+// parseTrackersList reads a tracker URL in each line. Blank lines separate the tiers and lines starting with # are ignored.
+func parseTrackersList(r io.Reader) ([][]string, error) {
+	var tiers [][]string
+	var tier []string
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case strings.HasPrefix(line, "#"):
+		case line == "":
+			if len(tier) > 0 {
+				tiers = append(tiers, tier)
+				tier = nil
+			}
+		default:
+			tier = append(tier, line)
+		}
+	}
+	if len(tier) > 0 {
+		tiers = append(tiers, tier)
+	}
+	return tiers, scanner.Err()
+}
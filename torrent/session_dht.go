@@ -1,18 +1,252 @@
 package torrent
 
 import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"expvar"
 	"net"
+	"reflect"
+	"strconv"
+	"strings"
 	"time"
+
+	"github.com/nictuku/dht"
+	"go.etcd.io/bbolt"
 )
 
-func (s *Session) processDHTResults() {
+// Number of DHT nodes that are saved for bootstrapping the DHT on next start.
+// Must not exceed the buffer size of dht.DHT.AddNode, so loading saved nodes does not block.
+// Saved nodes are not taken from the routing table. See Session.addDHTNode.
+const maxSavedDHTNodes = 100
+
+var dhtNodesKey = []byte("dht-nodes")
+
+var errDHTDisabled = errors.New("DHT is disabled")
+
+// dhtNodeStats contains the counters of the DHT node.
+// The DHT library publishes its counters with expvar package, so they are process-wide.
+type dhtNodeStats struct {
+	Nodes           int64
+	GoodNodes       int64
+	BadNodes        int64
+	QueriesSent     int64
+	QueriesReceived int64
+	PeersFound      int64
+}
+
+// newDHTNode creates and starts a DHT node that listens on the address.
+func newDHTNode(cfg *Config, address string) (*dht.DHT, error) {
+	dhtConfig := dht.NewConfig()
+	dhtConfig.Address = address
+	dhtConfig.Port = int(cfg.DHTPort)
+	dhtConfig.DHTRouters = strings.Join(cfg.DHTBootstrapNodes, ",")
+	dhtConfig.SaveRoutingTable = false
+	// Routing table is not saved to disk but reachable nodes are counted at every save period for stats.
+	dhtConfig.SavePeriod = time.Minute
+	dhtConfig.NumTargetPeers = 0
+	node, err := dht.New(dhtConfig)
+	if err != nil {
+		return nil, err
+	}
+	err = node.Start()
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+// bindDHT replaces the DHT node with a new one if ip is different from the address that the current node is bound to.
+// The node is stopped if ip is nil.
+func (s *Session) bindDHT(ip net.IP) error {
+	var address string
+	if ip != nil {
+		address = ip.String()
+	}
+	s.mDHT.Lock()
+	defer s.mDHT.Unlock()
+	if address == s.dhtAddress {
+		return nil
+	}
+	select {
+	case <-s.closeC:
+		return nil
+	default:
+	}
+	if s.dht != nil {
+		s.log.Infoln("stopping DHT on", s.dhtAddress)
+		stopDHTNode(s.dht)
+		close(s.dhtStopC)
+		s.dht = nil
+		s.dhtAddress = ""
+	}
+	if ip == nil {
+		return nil
+	}
+	s.log.Infoln("starting DHT on", address)
+	node, err := newDHTNode(&s.config, address)
+	if err != nil {
+		return err
+	}
+	s.dht = node
+	s.dhtAddress = address
+	s.dhtStopC = make(chan struct{})
+	s.addSavedDHTNodes(node)
+	go s.processDHTResults(node, s.dhtStopC)
+	return nil
+}
+
+func (s *Session) dhtNodeStats() dhtNodeStats {
+	if !s.config.DHTEnabled {
+		return dhtNodeStats{}
+	}
+	var st dhtNodeStats
+	// Counters are incremented when nodes are added to and removed from the routing table.
+	st.Nodes = expvarInt("totalNodes") - expvarInt("totalKilledNodes")
+	// Reachable nodes are counted periodically in dht.Config.SavePeriod, one value per DHT node.
+	// Values of the nodes that are replaced in bindDHT and of the nodes in other sessions are not counted.
+	s.mDHT.RLock()
+	if s.dht != nil {
+		if m, ok := expvar.Get("reachableNodes").(*expvar.Map); ok {
+			if v, ok := m.Get(dhtNodeID(s.dht)).(*expvar.Int); ok {
+				st.GoodNodes = v.Value()
+			}
+		}
+	}
+	s.mDHT.RUnlock()
+	if st.GoodNodes > st.Nodes {
+		st.GoodNodes = st.Nodes
+	}
+	st.BadNodes = st.Nodes - st.GoodNodes
+	st.QueriesSent = expvarInt("totalSentPing") + expvarInt("totalSentGetPeers") + expvarInt("totalSentFindNode")
+	st.QueriesReceived = expvarInt("totalRecvGetPeers") + expvarInt("totalRecvFindNode")
+	st.PeersFound = expvarInt("totalPeers")
+	return st
+}
+
+// dhtNodeID returns the ID of the node in hex, as it is used for keys in the "reachableNodes" expvar map.
+// The DHT library does not export the ID, so it is read with reflection.
+func dhtNodeID(node *dht.DHT) string {
+	return hex.EncodeToString([]byte(reflect.ValueOf(node).Elem().FieldByName("nodeId").String()))
+}
+
+// stopDHTNode stops the node and removes its value from the "reachableNodes" expvar map.
+func stopDHTNode(node *dht.DHT) {
+	node.Stop()
+	if m, ok := expvar.Get("reachableNodes").(*expvar.Map); ok {
+		m.Delete(dhtNodeID(node))
+	}
+}
+
+func expvarInt(name string) int64 {
+	if v, ok := expvar.Get(name).(*expvar.Int); ok {
+		return v.Value()
+	}
+	return 0
+}
+
+// AddDHTNode adds a node to the DHT routing table. addr must be in "host:port" format.
+// Added node is also saved, so it is used for bootstrapping the DHT on next start.
+func (s *Session) AddDHTNode(addr string) error {
+	if !s.config.DHTEnabled {
+		return errDHTDisabled
+	}
+	_, portstr, err := net.SplitHostPort(addr)
+	if err != nil {
+		return newInputError(err)
+	}
+	port, err := strconv.ParseUint(portstr, 10, 16)
+	if err != nil || port == 0 {
+		return newInputError(errors.New("invalid port number: " + portstr))
+	}
+	s.addDHTNode(addr)
+	return nil
+}
+
+// addDHTNode adds the node to the DHT and remembers it for saving.
+//
+// The DHT library does not expose its routing table, so the known-good nodes in the table cannot be saved.
+// Instead, the nodes that are learned from the PORT messages of connected peers or added manually are saved.
+// These nodes are not verified by the DHT before saving, so a single node is kept for each IP address.
+// A peer can only announce a node on its own IP, so it cannot replace the other saved nodes.
+func (s *Session) addDHTNode(addr string) {
+	s.mDHT.RLock()
+	if s.dht != nil {
+		s.dht.AddNode(addr)
+	}
+	s.mDHT.RUnlock()
+	host, _, _ := net.SplitHostPort(addr)
+	s.mDHTNodes.Lock()
+	defer s.mDHTNodes.Unlock()
+	for a := range s.dhtNodes {
+		if h, _, _ := net.SplitHostPort(a); h == host {
+			delete(s.dhtNodes, a)
+		}
+	}
+	s.dhtNodes[addr] = time.Now()
+	if len(s.dhtNodes) <= maxSavedDHTNodes {
+		return
+	}
+	// Forget the node that is seen least recently.
+	var oldest string
+	var oldestTime time.Time
+	for a, t := range s.dhtNodes {
+		if oldest == "" || t.Before(oldestTime) {
+			oldest, oldestTime = a, t
+		}
+	}
+	delete(s.dhtNodes, oldest)
+}
+
+// loadDHTNodes adds the nodes that are saved in previous run to the DHT.
+func (s *Session) loadDHTNodes() error {
+	err := s.db.View(func(tx *bbolt.Tx) error {
+		val := tx.Bucket(sessionBucket).Get(dhtNodesKey)
+		if val == nil {
+			return nil
+		}
+		return json.Unmarshal(val, &s.dhtNodes)
+	})
+	if err != nil {
+		return err
+	}
+	s.mDHT.RLock()
+	if s.dht != nil {
+		s.addSavedDHTNodes(s.dht)
+	}
+	s.mDHT.RUnlock()
+	return nil
+}
+
+func (s *Session) addSavedDHTNodes(node *dht.DHT) {
+	s.mDHTNodes.Lock()
+	defer s.mDHTNodes.Unlock()
+	for addr := range s.dhtNodes {
+		node.AddNode(addr)
+	}
+	s.log.Debugf("added %d saved nodes to DHT", len(s.dhtNodes))
+}
+
+func (s *Session) saveDHTNodes() error {
+	s.mDHTNodes.Lock()
+	val, err := json.Marshal(s.dhtNodes)
+	s.mDHTNodes.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sessionBucket).Put(dhtNodesKey, val)
+	})
+}
+
+func (s *Session) processDHTResults(node *dht.DHT, stopC chan struct{}) {
 	dhtLimiter := time.NewTicker(time.Second)
 	defer dhtLimiter.Stop()
 	for {
 		select {
 		case <-dhtLimiter.C:
-			s.handleDHTtick()
-		case res := <-s.dht.PeersRequestResults:
+			s.handleDHTtick(node)
+		case res := <-node.PeersRequestResults:
 			for ih, peers := range res {
 				s.mTorrents.RLock()
 				torrents, ok := s.torrentsByInfoHash[ih]
@@ -21,7 +255,13 @@ func (s *Session) processDHTResults() {
 					continue
 				}
 				addrs := parseDHTPeers(peers)
+				now := time.Now()
 				for _, t := range torrents {
+					t.torrent.mDHTStats.Lock()
+					t.torrent.dhtStats.LastPeers = len(addrs)
+					t.torrent.dhtStats.LastPeersTime = now
+					t.torrent.dhtStats.PeersTotal += len(addrs)
+					t.torrent.mDHTStats.Unlock()
 					select {
 					case t.torrent.dhtPeersC <- addrs:
 					case <-t.torrent.closeC:
@@ -29,18 +269,24 @@ func (s *Session) processDHTResults() {
 					}
 				}
 			}
+		case <-stopC:
+			return
 		case <-s.closeC:
 			return
 		}
 	}
 }
 
-func (s *Session) handleDHTtick() {
+func (s *Session) handleDHTtick(node *dht.DHT) {
 	s.mPeerRequests.Lock()
 	defer s.mPeerRequests.Unlock()
 	for t := range s.dhtPeerRequests {
-		s.dht.PeersRequestPort(string(t.infoHash[:]), true, t.port)
+		node.PeersRequestPort(string(t.infoHash[:]), true, t.port)
 		delete(s.dhtPeerRequests, t)
+		t.mDHTStats.Lock()
+		t.dhtStats.Announces++
+		t.dhtStats.LastAnnounce = time.Now()
+		t.mDHTStats.Unlock()
 		return
 	}
 }
@@ -0,0 +1,144 @@
+package torrent
+
+import (
+	"errors"
+	"net"
+	"sync"
+
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+)
+
+// Extension is a custom message type for the Extension Protocol (BEP 10).
+// Extensions are registered on a Session with RegisterExtension method.
+// All callbacks are called from the event loop of the torrent, so they must not block.
+type Extension struct {
+	// Name of the extension that is advertised in "m" dictionary of the extension handshake.
+	// Must be unique in Session and must not collide with the extensions implemented by Rain.
+	Name string
+	// Handshake returns additional keys to be included in the extension handshake sent to the peer.
+	// Values must be encodable with bencode. Optional.
+	Handshake func(p *ExtensionPeer) map[string]any
+	// OnHandshake is called when the peer sends an extension handshake that advertises this extension.
+	// Values in the handshake dictionary are passed as raw bencoded bytes. Optional.
+	OnHandshake func(p *ExtensionPeer, handshake map[string][]byte)
+	// OnMessage is called for each message that is sent by the peer for this extension. Optional.
+	OnMessage func(p *ExtensionPeer, payload []byte)
+	// OnDisconnect is called when the connection to a peer that advertised this extension is closed. Optional.
+	OnDisconnect func(p *ExtensionPeer)
+}
+
+// registeredExtension is an Extension with a message ID assigned by the Session.
+type registeredExtension struct {
+	*Extension
+	id uint8
+}
+
+var builtinExtensionNames = map[string]struct{}{
+	peerprotocol.ExtensionKeyMetadata:  {},
+	peerprotocol.ExtensionKeyPEX:       {},
+	peerprotocol.ExtensionKeyHolepunch: {},
+	peerprotocol.ExtensionKeyDontHave:  {},
+}
+
+// RegisterExtension adds a custom extension to the Session.
+// The extension is advertised to the peers that are connected after this call.
+func (s *Session) RegisterExtension(ext Extension) error {
+	if ext.Name == "" {
+		return errors.New("extension name is empty")
+	}
+	if _, ok := builtinExtensionNames[ext.Name]; ok {
+		return errors.New("extension name is reserved: " + ext.Name)
+	}
+	s.mCustomExtensions.Lock()
+	defer s.mCustomExtensions.Unlock()
+	for _, e := range s.customExtensions {
+		if e.Name == ext.Name {
+			return errors.New("extension is already registered: " + ext.Name)
+		}
+	}
+	id := peerprotocol.ExtensionIDCustomBegin + len(s.customExtensions)
+	if id > 255 {
+		return errors.New("too many extensions")
+	}
+	s.customExtensions = append(s.customExtensions, registeredExtension{Extension: &ext, id: uint8(id)})
+	return nil
+}
+
+func (s *Session) getCustomExtensions() []registeredExtension {
+	s.mCustomExtensions.RLock()
+	defer s.mCustomExtensions.RUnlock()
+	return s.customExtensions
+}
+
+// ExtensionPeer is a connected peer that is passed to the callbacks of an Extension.
+type ExtensionPeer struct {
+	ext       registeredExtension
+	peer      *peer.Peer
+	torrentID string
+	infoHash  InfoHash
+
+	m         sync.Mutex
+	remoteID  uint8
+	supported bool
+}
+
+func newExtensionPeer(ext registeredExtension, pe *peer.Peer, torrentID string, infoHash [20]byte) *ExtensionPeer {
+	return &ExtensionPeer{
+		ext:       ext,
+		peer:      pe,
+		torrentID: torrentID,
+		infoHash:  infoHash,
+	}
+}
+
+// TorrentID returns the ID of the torrent that the peer is connected for.
+func (p *ExtensionPeer) TorrentID() string {
+	return p.torrentID
+}
+
+// InfoHash returns the info hash of the torrent that the peer is connected for.
+func (p *ExtensionPeer) InfoHash() InfoHash {
+	return p.infoHash
+}
+
+// ID returns the peer ID sent in the BitTorrent handshake.
+func (p *ExtensionPeer) ID() [20]byte {
+	return p.peer.ID
+}
+
+// Addr returns the remote address of the peer.
+func (p *ExtensionPeer) Addr() net.Addr {
+	return p.peer.Addr()
+}
+
+// Supported returns true if the peer has advertised the extension in its extension handshake.
+func (p *ExtensionPeer) Supported() bool {
+	p.m.Lock()
+	defer p.m.Unlock()
+	return p.supported
+}
+
+// Send a message with the payload to the peer.
+// Returns error if the peer has not advertised the extension yet.
+// Send is safe to be called from any goroutine.
+func (p *ExtensionPeer) Send(payload []byte) error {
+	p.m.Lock()
+	id, ok := p.remoteID, p.supported
+	p.m.Unlock()
+	if !ok {
+		return errors.New("extension is not supported by peer: " + p.ext.Name)
+	}
+	p.peer.SendMessage(peerprotocol.ExtensionMessage{
+		ExtendedMessageID: id,
+		Payload:           peerprotocol.ExtensionRawMessage{ExtendedMessageID: id, Data: payload},
+	})
+	return nil
+}
+
+func (p *ExtensionPeer) setRemoteID(id uint8) {
+	p.m.Lock()
+	p.remoteID = id
+	p.supported = true
+	p.m.Unlock()
+}
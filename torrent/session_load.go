@@ -90,6 +90,18 @@ func (s *Session) loadExistingTorrent(id string) (tt *Torrent, hasStarted bool,
 	if err != nil {
 		return
 	}
+	announceKey := spec.AnnounceKey
+	if announceKey == 0 {
+		// Torrent is added by an older version.
+		announceKey, err = newAnnounceKey()
+		if err != nil {
+			return
+		}
+		err = s.resumer.WriteAnnounceKey(id, announceKey)
+		if err != nil {
+			return
+		}
+	}
 	t, err := newTorrent2(
 		s,
 		id,
@@ -112,6 +124,11 @@ func (s *Session) loadExistingTorrent(id string) (tt *Torrent, hasStarted bool,
 		spec.StopAfterDownload,
 		spec.StopAfterMetadata,
 		spec.CompleteCmdRun,
+		EncryptionPolicy(spec.Encryption),
+		spec.SpeedLimitDownload,
+		spec.SpeedLimitUpload,
+		BandwidthPriority(spec.BandwidthPriority),
+		announceKey,
 	)
 	if err != nil {
 		return
@@ -166,16 +183,21 @@ func (s *Session) CompactDatabase(output string) error {
 	}
 	for _, t := range s.torrents {
 		spec := &boltdbresumer.Spec{
-			InfoHash:          t.torrent.InfoHash(),
-			Port:              t.torrent.port,
-			Name:              t.torrent.name,
-			Trackers:          t.torrent.rawTrackers,
-			URLList:           t.torrent.rawWebseedSources,
-			FixedPeers:        t.torrent.fixedPeers,
-			Info:              t.torrent.info.Bytes,
-			AddedAt:           t.torrent.addedAt,
-			StopAfterDownload: t.torrent.stopAfterDownload,
-			StopAfterMetadata: t.torrent.stopAfterMetadata,
+			InfoHash:           t.torrent.InfoHash(),
+			Port:               t.torrent.port,
+			Name:               t.torrent.name,
+			Trackers:           t.TrackerTiers(),
+			URLList:            t.torrent.rawWebseedSources,
+			FixedPeers:         t.torrent.fixedPeers,
+			Info:               t.torrent.info.Bytes,
+			AddedAt:            t.torrent.addedAt,
+			StopAfterDownload:  t.torrent.stopAfterDownload,
+			StopAfterMetadata:  t.torrent.stopAfterMetadata,
+			Encryption:         string(t.torrent.encryption),
+			SpeedLimitDownload: t.torrent.bucketDownload.Rate() / 1024,
+			SpeedLimitUpload:   t.torrent.bucketUpload.Rate() / 1024,
+			BandwidthPriority:  int(t.torrent.bandwidthPriority()),
+			AnnounceKey:        t.torrent.announceKey,
 		}
 		err = res.Write(t.torrent.id, spec)
 		if err != nil {
@@ -12,6 +12,12 @@ type sessionMetrics struct {
 
 	Torrents              metrics.Gauge
 	Peers                 metrics.Counter
+	PeerConnections       metrics.Gauge
+	HalfOpenDials         metrics.Gauge
+	IncomingRejectedPerIP metrics.Gauge
+	IncomingRateLimited   metrics.Gauge
+	IncomingPenalized     metrics.Gauge
+	PenalizedIPs          metrics.Gauge
 	PortsAvailable        metrics.Gauge
 	Uptime                metrics.Gauge
 	BlockListRules        metrics.Gauge
@@ -32,6 +38,12 @@ type sessionMetrics struct {
 	SpeedUpload           metrics.Meter
 	SpeedRead             metrics.Meter
 	SpeedWrite            metrics.Meter
+	DHTNodes              metrics.Gauge
+	DHTGoodNodes          metrics.Gauge
+	DHTBadNodes           metrics.Gauge
+	DHTQueriesSent        metrics.Gauge
+	DHTQueriesReceived    metrics.Gauge
+	DHTPeersFound         metrics.Gauge
 }
 
 func (s *Session) initMetrics() {
@@ -46,7 +58,14 @@ func (s *Session) initMetrics() {
 			defer s.mTorrents.RUnlock()
 			return int64(len(s.torrents))
 		}),
-		Peers: metrics.NewRegisteredCounter("peers", r),
+		Peers:           metrics.NewRegisteredCounter("peers", r),
+		PeerConnections: metrics.NewRegisteredFunctionalGauge("peer_connections", r, func() int64 { return int64(s.connLimiter.Stats().Conns) }),
+		HalfOpenDials:   metrics.NewRegisteredFunctionalGauge("half_open_dials", r, func() int64 { return int64(s.connLimiter.Stats().HalfOpen) }),
+
+		IncomingRejectedPerIP: metrics.NewRegisteredFunctionalGauge("incoming_rejected_per_ip", r, func() int64 { return s.connGuard.Stats().RejectedTooManyConnections }),
+		IncomingRateLimited:   metrics.NewRegisteredFunctionalGauge("incoming_rate_limited", r, func() int64 { return s.connGuard.Stats().RejectedRateLimited }),
+		IncomingPenalized:     metrics.NewRegisteredFunctionalGauge("incoming_penalized", r, func() int64 { return s.connGuard.Stats().RejectedPenalized }),
+		PenalizedIPs:          metrics.NewRegisteredFunctionalGauge("penalized_ips", r, func() int64 { return int64(s.connGuard.Stats().PenalizedIPs) }),
 		PortsAvailable: metrics.NewRegisteredFunctionalGauge("ports_available", r, func() int64 {
 			s.mPorts.RLock()
 			defer s.mPorts.RUnlock()
@@ -83,6 +102,13 @@ func (s *Session) initMetrics() {
 		SpeedUpload:   metrics.NewRegisteredMeter("speed_upload", r),
 		SpeedRead:     s.pieceCache.NumLoadedBytes,
 		SpeedWrite:    metrics.NewRegisteredMeter("speed_write", r),
+
+		DHTNodes:           metrics.NewRegisteredFunctionalGauge("dht_nodes", r, func() int64 { return s.dhtNodeStats().Nodes }),
+		DHTGoodNodes:       metrics.NewRegisteredFunctionalGauge("dht_good_nodes", r, func() int64 { return s.dhtNodeStats().GoodNodes }),
+		DHTBadNodes:        metrics.NewRegisteredFunctionalGauge("dht_bad_nodes", r, func() int64 { return s.dhtNodeStats().BadNodes }),
+		DHTQueriesSent:     metrics.NewRegisteredFunctionalGauge("dht_queries_sent", r, func() int64 { return s.dhtNodeStats().QueriesSent }),
+		DHTQueriesReceived: metrics.NewRegisteredFunctionalGauge("dht_queries_received", r, func() int64 { return s.dhtNodeStats().QueriesReceived }),
+		DHTPeersFound:      metrics.NewRegisteredFunctionalGauge("dht_peers_found", r, func() int64 { return s.dhtNodeStats().PeersFound }),
 	}
 	_ = r.Register("speed_read", s.metrics.SpeedRead)
 	_ = r.Register("reads_per_seconds", s.metrics.ReadsPerSecond)
@@ -0,0 +1,162 @@
+package torrent
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"time"
+)
+
+var errOutgoingInterfaceDown = errors.New("outgoing interface is not available")
+
+// outgoingDialer binds outgoing connections to the address of Config.OutgoingInterface or to Config.OutgoingIP.
+// Connections fail instead of using the default route if the address is not available.
+type outgoingDialer struct {
+	iface string
+	ip    net.IP
+}
+
+func newOutgoingDialer(iface, ip string) (*outgoingDialer, error) {
+	d := &outgoingDialer{iface: iface}
+	if ip != "" {
+		d.ip = net.ParseIP(ip)
+		if d.ip == nil {
+			return nil, errors.New("invalid outgoing IP: " + ip)
+		}
+	}
+	return d, nil
+}
+
+// localIP returns the IP address that outgoing connections are bound to.
+// If an interface is configured, the address is looked up each time because it may change while the session is running.
+func (d *outgoingDialer) localIP() (net.IP, error) {
+	if d.iface == "" {
+		return d.ip, nil
+	}
+	ifi, err := net.InterfaceByName(d.iface)
+	if err != nil {
+		return nil, err
+	}
+	if ifi.Flags&net.FlagUp == 0 {
+		return nil, errors.New("interface is down: " + d.iface)
+	}
+	addrs, err := ifi.Addrs()
+	if err != nil {
+		return nil, err
+	}
+	var first net.IP
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		if d.ip != nil && ipnet.IP.Equal(d.ip) {
+			return d.ip, nil
+		}
+		if first == nil && ipnet.IP.To4() != nil {
+			first = ipnet.IP.To4()
+		}
+	}
+	if d.ip != nil {
+		return nil, errors.New("interface " + d.iface + " does not have address " + d.ip.String())
+	}
+	if first == nil {
+		return nil, errors.New("interface has no IPv4 address: " + d.iface)
+	}
+	return first, nil
+}
+
+// DialContext implements proxy.Dialer interface.
+func (d *outgoingDialer) DialContext(ctx context.Context, network, address string) (net.Conn, error) {
+	ip, err := d.localIP()
+	if err != nil {
+		return nil, &net.OpError{Op: "dial", Net: network, Err: err}
+	}
+	var laddr net.Addr = &net.TCPAddr{IP: ip}
+	if strings.HasPrefix(network, "udp") {
+		laddr = &net.UDPAddr{IP: ip}
+	}
+	nd := net.Dialer{LocalAddr: laddr}
+	return nd.DialContext(ctx, network, address)
+}
+
+// ListenUDP opens a UDP socket bound to the outgoing address for talking to UDP trackers.
+func (d *outgoingDialer) ListenUDP() (net.PacketConn, error) {
+	ip, err := d.localIP()
+	if err != nil {
+		return nil, &net.OpError{Op: "listen", Net: "udp4", Err: err}
+	}
+	return net.ListenUDP("udp4", &net.UDPAddr{IP: ip})
+}
+
+// watchOutgoingInterface periodically checks the outgoing interface and stops running torrents while it is not available.
+// Torrents are not marked as stopped in the database so they are resumed on next startup.
+// They are also started again when the interface becomes available.
+// DHT node is stopped while the interface is not available and it is restarted when the address of the interface changes.
+func (s *Session) watchOutgoingInterface() {
+	ticker := time.NewTicker(s.config.OutgoingInterfaceCheckInterval)
+	defer ticker.Stop()
+	available := true
+	for {
+		ip, err := s.outgoingDialer.localIP()
+		if err != nil {
+			if available {
+				s.log.Errorln("outgoing interface is not available, stopping all torrents:", err.Error())
+			}
+			s.stopTorrentsOnInterfaceDown()
+		} else if !available {
+			s.log.Infoln("outgoing interface is available again, starting stopped torrents")
+			s.startTorrentsOnInterfaceUp()
+		}
+		available = err == nil
+		if s.config.DHTEnabled {
+			err = s.bindDHT(ip)
+			if err != nil {
+				s.log.Errorln("cannot start DHT:", err.Error())
+			}
+		}
+		select {
+		case <-ticker.C:
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+// stopTorrentsOnInterfaceDown stops the running torrents and remembers them for starting again later.
+// Torrents that are already stopped are skipped.
+func (s *Session) stopTorrentsOnInterfaceDown() {
+	for _, t := range s.ListTorrents() {
+		switch t.torrent.Stats().Status {
+		case Stopping, Stopped:
+			continue
+		}
+		s.mInterfaceStopped.Lock()
+		s.interfaceStopped[t.torrent.id] = struct{}{}
+		s.mInterfaceStopped.Unlock()
+		t.torrent.stopWithError(errOutgoingInterfaceDown)
+	}
+}
+
+// startTorrentsOnInterfaceUp starts the torrents that are stopped by stopTorrentsOnInterfaceDown.
+func (s *Session) startTorrentsOnInterfaceUp() {
+	s.mInterfaceStopped.Lock()
+	ids := s.interfaceStopped
+	s.interfaceStopped = make(map[string]struct{})
+	s.mInterfaceStopped.Unlock()
+	for id := range ids {
+		t := s.GetTorrent(id)
+		if t != nil {
+			t.torrent.Start()
+		}
+	}
+}
+
+// forgetInterfaceStopped prevents the torrent from being started when the outgoing interface becomes available.
+// Called when the user stops or starts the torrent.
+func (s *Session) forgetInterfaceStopped(id string) {
+	s.mInterfaceStopped.Lock()
+	delete(s.interfaceStopped, id)
+	s.mInterfaceStopped.Unlock()
+}
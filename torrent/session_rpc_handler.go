@@ -46,6 +46,9 @@ func (h *rpcHandler) AddTorrent(args *rpctypes.AddTorrentRequest, reply *rpctype
 		ID:                args.AddTorrentOptions.ID,
 		StopAfterDownload: args.StopAfterDownload,
 		StopAfterMetadata: args.StopAfterMetadata,
+		Encryption:        EncryptionPolicy(args.Encryption),
+		BandwidthPriority: BandwidthPriority(args.BandwidthPriority),
+		NoDefaultTrackers: args.NoDefaultTrackers,
 	}
 	t, err := h.session.AddTorrent(r, opt)
 	var e *InputError
@@ -65,6 +68,9 @@ func (h *rpcHandler) AddURI(args *rpctypes.AddURIRequest, reply *rpctypes.AddURI
 		ID:                args.AddTorrentOptions.ID,
 		StopAfterDownload: args.StopAfterDownload,
 		StopAfterMetadata: args.StopAfterMetadata,
+		Encryption:        EncryptionPolicy(args.Encryption),
+		BandwidthPriority: BandwidthPriority(args.BandwidthPriority),
+		NoDefaultTrackers: args.NoDefaultTrackers,
 	}
 	t, err := h.session.AddURI(args.URI, opt)
 	var e *InputError
@@ -127,6 +133,16 @@ func (h *rpcHandler) GetSessionStats(args *rpctypes.GetSessionStatsRequest, repl
 		Peers:          s.Peers,
 		PortsAvailable: s.PortsAvailable,
 
+		PeerConnections:      s.PeerConnections,
+		PeerConnectionsLimit: s.PeerConnectionsLimit,
+		HalfOpenDials:        s.HalfOpenDials,
+		HalfOpenDialsLimit:   s.HalfOpenDialsLimit,
+
+		IncomingRejectedPerIP: s.IncomingRejectedPerIP,
+		IncomingRateLimited:   s.IncomingRateLimited,
+		IncomingPenalized:     s.IncomingPenalized,
+		PenalizedIPs:          s.PenalizedIPs,
+
 		BlockListRules:   s.BlockListRules,
 		BlockListRecency: int(s.BlockListRecency / time.Second),
 
@@ -151,10 +167,31 @@ func (h *rpcHandler) GetSessionStats(args *rpctypes.GetSessionStatsRequest, repl
 		SpeedRead:     s.SpeedRead,
 		SpeedWrite:    s.SpeedWrite,
 
+		SpeedLimitDownload: s.SpeedLimitDownload,
+		SpeedLimitUpload:   s.SpeedLimitUpload,
+		SpeedLimitProfile:  s.SpeedLimitProfile,
+		TurtleMode:         s.TurtleMode,
+
 		BytesDownloaded: s.BytesDownloaded,
 		BytesUploaded:   s.BytesUploaded,
 		BytesRead:       s.BytesRead,
 		BytesWritten:    s.BytesWritten,
+
+		BytesDownloadedToday:     s.BytesDownloadedToday,
+		BytesUploadedToday:       s.BytesUploadedToday,
+		BytesDownloadedThisMonth: s.BytesDownloadedThisMonth,
+		BytesUploadedThisMonth:   s.BytesUploadedThisMonth,
+		DailyTrafficQuota:        s.DailyTrafficQuota,
+		MonthlyTrafficQuota:      s.MonthlyTrafficQuota,
+		UploadsStoppedByQuota:    s.UploadsStoppedByQuota,
+		DownloadsStoppedByQuota:  s.DownloadsStoppedByQuota,
+
+		DHTNodes:           s.DHTNodes,
+		DHTGoodNodes:       s.DHTGoodNodes,
+		DHTBadNodes:        s.DHTBadNodes,
+		DHTQueriesSent:     s.DHTQueriesSent,
+		DHTQueriesReceived: s.DHTQueriesReceived,
+		DHTPeersFound:      s.DHTPeersFound,
 	}
 	return nil
 }
@@ -260,6 +297,14 @@ func (h *rpcHandler) GetTorrentStats(args *rpctypes.GetTorrentStatsRequest, repl
 			Download: s.Speed.Download,
 			Upload:   s.Speed.Upload,
 		},
+		SpeedLimit: struct {
+			Download int64
+			Upload   int64
+		}{
+			Download: s.SpeedLimit.Download,
+			Upload:   s.SpeedLimit.Upload,
+		},
+		BandwidthPriority: int(s.BandwidthPriority),
 	}
 	if s.Error != nil {
 		reply.Stats.Error = s.Error.Error()
@@ -281,11 +326,14 @@ func (h *rpcHandler) GetTorrentTrackers(args *rpctypes.GetTorrentTrackersRequest
 	reply.Trackers = make([]rpctypes.Tracker, len(trackers))
 	for i, t := range trackers {
 		reply.Trackers[i] = rpctypes.Tracker{
-			URL:      t.URL,
-			Status:   trackerStatusToString(t.Status),
-			Leechers: t.Leechers,
-			Seeders:  t.Seeders,
-			Warning:  t.Warning,
+			URL:        t.URL,
+			Status:     trackerStatusToString(t.Status),
+			Leechers:   t.Leechers,
+			Seeders:    t.Seeders,
+			Warning:    t.Warning,
+			Peers:      t.Peers,
+			PeersTotal: t.PeersTotal,
+			Health:     t.Health,
 		}
 		if t.Error != nil {
 			reply.Trackers[i].Error = t.Error.Error()
@@ -298,6 +346,39 @@ func (h *rpcHandler) GetTorrentTrackers(args *rpctypes.GetTorrentTrackersRequest
 		if !t.NextAnnounce.IsZero() {
 			reply.Trackers[i].NextAnnounce = rpctypes.Time{Time: t.NextAnnounce}
 		}
+		reply.Trackers[i].Completed = t.Completed
+		if !t.LastScrape.IsZero() {
+			reply.Trackers[i].LastScrape = rpctypes.Time{Time: t.LastScrape}
+		}
+		if t.ScrapeError != nil {
+			reply.Trackers[i].ScrapeError = t.ScrapeError.Error()
+		}
+	}
+	return nil
+}
+
+func (h *rpcHandler) GetTorrentTrackerHistory(args *rpctypes.GetTorrentTrackerHistoryRequest, reply *rpctypes.GetTorrentTrackerHistoryResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	reply.History = make([]rpctypes.TrackerAnnounce, 0)
+	for _, tr := range t.Trackers() {
+		for _, r := range tr.History {
+			a := rpctypes.TrackerAnnounce{
+				Tracker: tr.URL,
+				URL:     r.URL,
+				Time:    rpctypes.Time{Time: r.Time},
+				Event:   r.Event,
+				Peers:   r.Peers,
+				Latency: r.Latency.Milliseconds(),
+			}
+			if r.Error != nil {
+				a.Error = r.Error.Error()
+				a.ErrorType = r.Error.err.Type()
+			}
+			reply.History = append(reply.History, a)
+		}
 	}
 	return nil
 }
@@ -322,6 +403,8 @@ func (h *rpcHandler) GetTorrentPeers(args *rpctypes.GetTorrentPeersRequest, repl
 			source = "INCOMING"
 		case SourceManual:
 			source = "MANUAL"
+		case SourceHolepunch:
+			source = "HOLEPUNCH"
 		default:
 			panic("unhandled peer source")
 		}
@@ -340,6 +423,7 @@ func (h *rpcHandler) GetTorrentPeers(args *rpctypes.GetTorrentPeersRequest, repl
 			Snubbed:            p.Snubbed,
 			EncryptedHandshake: p.EncryptedHandshake,
 			EncryptedStream:    p.EncryptedStream,
+			UploadOnly:         p.UploadOnly,
 			DownloadSpeed:      p.DownloadSpeed,
 			UploadSpeed:        p.UploadSpeed,
 		}
@@ -415,6 +499,15 @@ func (h *rpcHandler) AddPeer(args *rpctypes.AddPeerRequest, reply *rpctypes.AddP
 	return t.AddPeer(args.Addr)
 }
 
+func (h *rpcHandler) AddDHTNode(args *rpctypes.AddDHTNodeRequest, reply *rpctypes.AddDHTNodeResponse) error {
+	err := h.session.AddDHTNode(args.Addr)
+	var e *InputError
+	if errors.As(err, &e) || err == errDHTDisabled {
+		return jsonrpc2.NewError(2, err.Error())
+	}
+	return err
+}
+
 func (h *rpcHandler) AddTracker(args *rpctypes.AddTrackerRequest, reply *rpctypes.AddTrackerResponse) error {
 	t := h.session.GetTorrent(args.ID)
 	if t == nil {
@@ -423,6 +516,103 @@ func (h *rpcHandler) AddTracker(args *rpctypes.AddTrackerRequest, reply *rpctype
 	return t.AddTracker(args.URL)
 }
 
+func (h *rpcHandler) RemoveTracker(args *rpctypes.RemoveTrackerRequest, reply *rpctypes.RemoveTrackerResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	return trackerError(t.RemoveTracker(args.URL))
+}
+
+func (h *rpcHandler) ReplaceTracker(args *rpctypes.ReplaceTrackerRequest, reply *rpctypes.ReplaceTrackerResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	return trackerError(t.ReplaceTracker(args.OldURL, args.NewURL))
+}
+
+func (h *rpcHandler) MoveTrackerTier(args *rpctypes.MoveTrackerTierRequest, reply *rpctypes.MoveTrackerTierResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	return trackerError(t.MoveTrackerTier(args.From, args.To))
+}
+
+func (h *rpcHandler) GetTorrentTrackerTiers(args *rpctypes.GetTorrentTrackerTiersRequest, reply *rpctypes.GetTorrentTrackerTiersResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	reply.Tiers = t.TrackerTiers()
+	return nil
+}
+
+func (h *rpcHandler) ReplaceTrackerURLs(args *rpctypes.ReplaceTrackerURLsRequest, reply *rpctypes.ReplaceTrackerURLsResponse) error {
+	changes, err := h.session.ReplaceTrackerURLs(args.Pattern, args.Replacement, args.DryRun)
+	var e *InputError
+	if errors.As(err, &e) {
+		return jsonrpc2.NewError(2, e.Error())
+	}
+	if err != nil {
+		return err
+	}
+	reply.Changes = make([]rpctypes.TrackerURLChange, len(changes))
+	for i, c := range changes {
+		reply.Changes[i] = rpctypes.TrackerURLChange{
+			ID:     c.TorrentID,
+			OldURL: c.OldURL,
+			NewURL: c.NewURL,
+		}
+	}
+	return nil
+}
+
+func trackerError(err error) error {
+	if err == errTrackerNotFound || err == errInvalidTierIndex {
+		return jsonrpc2.NewError(2, err.Error())
+	}
+	return err
+}
+
+func (h *rpcHandler) SetSpeedLimits(args *rpctypes.SetSpeedLimitsRequest, reply *rpctypes.SetSpeedLimitsResponse) error {
+	if args.Download < 0 || args.Upload < 0 {
+		return jsonrpc2.NewError(2, errNegativeSpeedLimit.Error())
+	}
+	h.session.SetSpeedLimits(args.Download, args.Upload)
+	return nil
+}
+
+func (h *rpcHandler) SetTurtleMode(args *rpctypes.SetTurtleModeRequest, reply *rpctypes.SetTurtleModeResponse) error {
+	h.session.SetTurtleMode(args.Enabled)
+	return nil
+}
+
+func (h *rpcHandler) SetTorrentSpeedLimits(args *rpctypes.SetTorrentSpeedLimitsRequest, reply *rpctypes.SetTorrentSpeedLimitsResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	err := t.SetSpeedLimits(args.Download, args.Upload)
+	if err == errNegativeSpeedLimit {
+		return jsonrpc2.NewError(2, err.Error())
+	}
+	return err
+}
+
+func (h *rpcHandler) SetTorrentBandwidthPriority(args *rpctypes.SetTorrentBandwidthPriorityRequest, reply *rpctypes.SetTorrentBandwidthPriorityResponse) error {
+	t := h.session.GetTorrent(args.ID)
+	if t == nil {
+		return errTorrentNotFound
+	}
+	err := t.SetBandwidthPriority(BandwidthPriority(args.Priority))
+	if err == errInvalidBandwidthPriority {
+		return jsonrpc2.NewError(2, err.Error())
+	}
+	return err
+}
+
 func (h *rpcHandler) MoveTorrent(args *rpctypes.MoveTorrentRequest, reply *rpctypes.MoveTorrentResponse) error {
 	t := h.session.GetTorrent(args.ID)
 	if t == nil {
@@ -0,0 +1,145 @@
+package torrent
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/cenkalti/rain/internal/tracker"
+)
+
+// trackerScrape is the last scrape result of a torrent from a tracker.
+type trackerScrape struct {
+	tracker.ScrapeResult
+	Time  time.Time
+	Error error
+}
+
+type scrapeRequest struct {
+	Response chan []tracker.Tracker
+}
+
+// scrapeTrackers returns the trackers of the torrent if the torrent is stopped.
+// Trackers in tiers are returned separately.
+func (t *torrent) scrapeTrackers() []tracker.Tracker {
+	var trackers []tracker.Tracker
+	req := scrapeRequest{Response: make(chan []tracker.Tracker, 1)}
+	select {
+	case t.scrapeCommandC <- req:
+	case <-t.closeC:
+		return nil
+	}
+	select {
+	case trackers = <-req.Response:
+	case <-t.closeC:
+	}
+	return trackers
+}
+
+func (t *torrent) handleScrapeCommand(req scrapeRequest) {
+	if t.status() != Stopped {
+		req.Response <- nil
+		return
+	}
+	req.Response <- flattenTrackers(t.trackers)
+}
+
+func flattenTrackers(trackers []tracker.Tracker) []tracker.Tracker {
+	ret := make([]tracker.Tracker, 0, len(trackers))
+	for _, tr := range trackers {
+		if tier, ok := tr.(*tracker.Tier); ok {
+			ret = append(ret, tier.Trackers...)
+		} else {
+			ret = append(ret, tr)
+		}
+	}
+	return ret
+}
+
+func (t *torrent) setScrape(url string, sc trackerScrape) {
+	t.mScrapes.Lock()
+	t.scrapes[url] = sc
+	t.mScrapes.Unlock()
+}
+
+func (t *torrent) getScrape(url string) (trackerScrape, bool) {
+	t.mScrapes.Lock()
+	defer t.mScrapes.Unlock()
+	sc, ok := t.scrapes[url]
+	return sc, ok
+}
+
+func (s *Session) scraper() {
+	s.scrapeStoppedTorrents()
+	ticker := time.NewTicker(s.config.TrackerScrapeInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			s.scrapeStoppedTorrents()
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+// scrapeStoppedTorrents learns the swarm sizes of stopped torrents from their trackers.
+// Info hashes are grouped by tracker, so a single request is made for many torrents.
+func (s *Session) scrapeStoppedTorrents() {
+	type scrapeGroup struct {
+		tracker  tracker.Tracker
+		torrents map[[20]byte][]*torrent
+	}
+	groups := make(map[string]*scrapeGroup)
+	s.mTorrents.RLock()
+	torrents := make([]*torrent, 0, len(s.torrents))
+	for _, t := range s.torrents {
+		torrents = append(torrents, t.torrent)
+	}
+	s.mTorrents.RUnlock()
+	for _, t := range torrents {
+		for _, tr := range t.scrapeTrackers() {
+			g, ok := groups[tr.URL()]
+			if !ok {
+				g = &scrapeGroup{tracker: tr, torrents: make(map[[20]byte][]*torrent)}
+				groups[tr.URL()] = g
+			}
+			g.torrents[t.infoHash] = append(g.torrents[t.infoHash], t)
+		}
+	}
+	var wg sync.WaitGroup
+	for url, g := range groups {
+		wg.Add(1)
+		go func(url string, g *scrapeGroup) {
+			defer wg.Done()
+			infoHashes := make([][20]byte, 0, len(g.torrents))
+			for ih := range g.torrents {
+				infoHashes = append(infoHashes, ih)
+			}
+			ctx, cancel := context.WithTimeout(context.Background(), s.config.TrackerHTTPTimeout)
+			defer cancel()
+			go func() {
+				select {
+				case <-s.closeC:
+					cancel()
+				case <-ctx.Done():
+				}
+			}()
+			results, err := g.tracker.Scrape(ctx, infoHashes)
+			if err == tracker.ErrScrapeNotSupported {
+				return
+			}
+			if err != nil {
+				s.log.Debugf("cannot scrape %s: %s", url, err)
+			}
+			now := time.Now()
+			for ih, torrents := range g.torrents {
+				sc := trackerScrape{ScrapeResult: results[ih], Time: now, Error: err}
+				for _, t := range torrents {
+					t.setScrape(url, sc)
+				}
+			}
+		}(url, g)
+	}
+	wg.Wait()
+}
@@ -0,0 +1,124 @@
+package torrent
+
+import (
+	"errors"
+	"time"
+
+	"github.com/cenkalti/rain/internal/speedlimit"
+)
+
+// Names of the speed limit profiles that are reported in SessionStats.
+const (
+	// SpeedLimitProfileNormal uses the limits in Config or the limits set with Session.SetSpeedLimits.
+	SpeedLimitProfileNormal = "normal"
+	// SpeedLimitProfileScheduled uses the limits of a matching entry in Config.SpeedLimitSchedule.
+	SpeedLimitProfileScheduled = "scheduled"
+	// SpeedLimitProfileTurtle uses the turtle mode limits in Config.
+	SpeedLimitProfileTurtle = "turtle"
+)
+
+// Interval for checking whether the active schedule entry has changed.
+const speedLimitScheduleInterval = time.Minute
+
+var errNegativeSpeedLimit = errors.New("speed limit cannot be negative")
+
+// SpeedLimitSchedule contains alternative global speed limits for a recurring time range in a week.
+type SpeedLimitSchedule struct {
+	// Days of the week that the range starts, e.g. ["mon", "tue"]. Empty means every day.
+	Days []string
+	// Start and end of the range in local time in "15:04" format.
+	// If End is before Start, the range ends on the next day. If End is equal to Start, the range covers the whole day.
+	Start, End string
+	// Global download speed limit in KB/s in the range. Zero means unlimited.
+	Download int64
+	// Global upload speed limit in KB/s in the range. Zero means unlimited.
+	Upload int64
+}
+
+type scheduledSpeedLimit struct {
+	speedlimit.TimeRange
+	download, upload int64
+}
+
+func parseSpeedLimitSchedule(schedule []SpeedLimitSchedule) ([]scheduledSpeedLimit, error) {
+	ret := make([]scheduledSpeedLimit, 0, len(schedule))
+	for _, e := range schedule {
+		r, err := speedlimit.ParseTimeRange(e.Days, e.Start, e.End)
+		if err != nil {
+			return nil, err
+		}
+		ret = append(ret, scheduledSpeedLimit{TimeRange: r, download: e.Download, upload: e.Upload})
+	}
+	return ret, nil
+}
+
+// SetSpeedLimits changes the global download and upload speed limits in KB/s. Zero means unlimited.
+// New limits are applied immediately to all torrents unless turtle mode or a schedule entry is active.
+// Values in Config are used again after the Session is restarted.
+func (s *Session) SetSpeedLimits(download, upload int64) {
+	s.mSpeedLimits.Lock()
+	s.speedLimitDownload, s.speedLimitUpload = download, upload
+	s.applySpeedLimits(time.Now())
+	s.mSpeedLimits.Unlock()
+}
+
+// SetTurtleMode enables or disables the turtle mode.
+// While turtle mode is enabled, global speed limits are TurtleSpeedLimitDownload and TurtleSpeedLimitUpload in Config.
+func (s *Session) SetTurtleMode(enabled bool) {
+	s.mSpeedLimits.Lock()
+	s.turtleMode = enabled
+	s.applySpeedLimits(time.Now())
+	s.mSpeedLimits.Unlock()
+}
+
+// TurtleMode returns true if turtle mode is enabled.
+func (s *Session) TurtleMode() bool {
+	s.mSpeedLimits.Lock()
+	defer s.mSpeedLimits.Unlock()
+	return s.turtleMode
+}
+
+func (s *Session) speedLimitProfile() string {
+	s.mSpeedLimits.Lock()
+	defer s.mSpeedLimits.Unlock()
+	return s.activeSpeedLimitProfile
+}
+
+// applySpeedLimits sets the rates of session limiters from the active profile. Must be called with mSpeedLimits held.
+func (s *Session) applySpeedLimits(now time.Time) {
+	profile := SpeedLimitProfileNormal
+	download, upload := s.speedLimitDownload, s.speedLimitUpload
+	if s.turtleMode {
+		profile = SpeedLimitProfileTurtle
+		download, upload = s.config.TurtleSpeedLimitDownload, s.config.TurtleSpeedLimitUpload
+	} else {
+		for _, e := range s.speedLimitSchedule {
+			if e.Contains(now) {
+				profile = SpeedLimitProfileScheduled
+				download, upload = e.download, e.upload
+				break
+			}
+		}
+	}
+	if profile != s.activeSpeedLimitProfile {
+		s.log.Infof("speed limit profile changed to %s: download=%d KB/s upload=%d KB/s", profile, download, upload)
+		s.activeSpeedLimitProfile = profile
+	}
+	s.bucketDownload.SetRate(download * 1024)
+	s.bucketUpload.SetRate(upload * 1024)
+}
+
+func (s *Session) speedLimitScheduler() {
+	ticker := time.NewTicker(speedLimitScheduleInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.mSpeedLimits.Lock()
+			s.applySpeedLimits(now)
+			s.mSpeedLimits.Unlock()
+		case <-s.closeC:
+			return
+		}
+	}
+}
@@ -19,6 +19,24 @@ type SessionStats struct {
 	// Number of available ports for new torrents.
 	PortsAvailable int
 
+	// Number of peer connections in all torrents, including the ones in handshake state.
+	PeerConnections int
+	// Session-wide limit of peer connections. Zero means no limit.
+	PeerConnectionsLimit int
+	// Number of outgoing connections that are being dialed or handshaked.
+	HalfOpenDials int
+	// Session-wide limit of half-open dials. Zero means no limit.
+	HalfOpenDialsLimit int
+
+	// Number of incoming connections rejected because of the per-IP connection limit.
+	IncomingRejectedPerIP int64
+	// Number of incoming connections rejected because of the per-IP rate limit.
+	IncomingRateLimited int64
+	// Number of incoming connections rejected because the IP is penalized for failed handshakes.
+	IncomingPenalized int64
+	// Number of IPs that are currently penalized for failed handshakes.
+	PenalizedIPs int
+
 	// Number of rules in blocklist.
 	BlockListRules int
 	// Time elapsed after the last successful update of blocklist.
@@ -65,6 +83,15 @@ type SessionStats struct {
 	// Write speed to disk in bytes/s.
 	SpeedWrite int
 
+	// Global download speed limit in KB/s. Zero means unlimited.
+	SpeedLimitDownload int64
+	// Global upload speed limit in KB/s. Zero means unlimited.
+	SpeedLimitUpload int64
+	// Active speed limit profile: "normal", "scheduled" or "turtle".
+	SpeedLimitProfile string
+	// Turtle mode is enabled.
+	TurtleMode bool
+
 	// Number of bytes downloaded from peers.
 	BytesDownloaded int64
 	// Number of bytes uploaded to peers.
@@ -73,16 +100,57 @@ type SessionStats struct {
 	BytesRead int64
 	// Number of bytes written to disk.
 	BytesWritten int64
+
+	// Number of bytes downloaded from peers today. Counters are saved in the session database.
+	BytesDownloadedToday int64
+	// Number of bytes uploaded to peers today.
+	BytesUploadedToday int64
+	// Number of bytes downloaded from peers in this month.
+	BytesDownloadedThisMonth int64
+	// Number of bytes uploaded to peers in this month.
+	BytesUploadedThisMonth int64
+	// Daily traffic quota in bytes. Zero means no limit.
+	DailyTrafficQuota int64
+	// Monthly traffic quota in bytes. Zero means no limit.
+	MonthlyTrafficQuota int64
+	// Uploads are stopped because a traffic quota is exceeded.
+	UploadsStoppedByQuota bool
+	// Downloads are stopped because a traffic quota is exceeded.
+	DownloadsStoppedByQuota bool
+
+	// Number of nodes in DHT routing table.
+	DHTNodes int
+	// Number of nodes in DHT routing table that have replied to a query.
+	DHTGoodNodes int
+	// Number of nodes in DHT routing table that have not replied yet.
+	DHTBadNodes int
+	// Number of queries sent to DHT nodes.
+	DHTQueriesSent int64
+	// Number of queries received from DHT nodes.
+	DHTQueriesReceived int64
+	// Number of peers returned from DHT nodes for all torrents.
+	DHTPeersFound int64
 }
 
 // Stats returns current statistics about the Session.
 func (s *Session) Stats() SessionStats {
+	day, month, uploadsStopped, downloadsStopped := s.trafficStats()
 	return SessionStats{
 		Uptime:         time.Duration(s.metrics.Uptime.Value()) * time.Second,
 		Torrents:       int(s.metrics.Torrents.Value()),
 		Peers:          int(s.metrics.Peers.Count()),
 		PortsAvailable: int(s.metrics.PortsAvailable.Value()),
 
+		PeerConnections:      int(s.metrics.PeerConnections.Value()),
+		PeerConnectionsLimit: s.config.MaxSessionPeers,
+		HalfOpenDials:        int(s.metrics.HalfOpenDials.Value()),
+		HalfOpenDialsLimit:   s.config.MaxSessionHalfOpen,
+
+		IncomingRejectedPerIP: s.metrics.IncomingRejectedPerIP.Value(),
+		IncomingRateLimited:   s.metrics.IncomingRateLimited.Value(),
+		IncomingPenalized:     s.metrics.IncomingPenalized.Value(),
+		PenalizedIPs:          int(s.metrics.PenalizedIPs.Value()),
+
 		BlockListRules:   int(s.metrics.BlockListRules.Value()),
 		BlockListRecency: time.Duration(s.metrics.BlockListRecency.Value()) * time.Second,
 
@@ -107,10 +175,31 @@ func (s *Session) Stats() SessionStats {
 		SpeedRead:     int(s.metrics.SpeedRead.Rate1()),
 		SpeedWrite:    int(s.metrics.SpeedWrite.Rate1()),
 
+		SpeedLimitDownload: s.bucketDownload.Rate() / 1024,
+		SpeedLimitUpload:   s.bucketUpload.Rate() / 1024,
+		SpeedLimitProfile:  s.speedLimitProfile(),
+		TurtleMode:         s.TurtleMode(),
+
 		BytesDownloaded: s.metrics.SpeedDownload.Count(),
 		BytesUploaded:   s.metrics.SpeedUpload.Count(),
 		BytesRead:       s.metrics.SpeedRead.Count(),
 		BytesWritten:    s.metrics.SpeedWrite.Count(),
+
+		BytesDownloadedToday:     day.Downloaded,
+		BytesUploadedToday:       day.Uploaded,
+		BytesDownloadedThisMonth: month.Downloaded,
+		BytesUploadedThisMonth:   month.Uploaded,
+		DailyTrafficQuota:        s.config.DailyTrafficQuota << 20,
+		MonthlyTrafficQuota:      s.config.MonthlyTrafficQuota << 20,
+		UploadsStoppedByQuota:    uploadsStopped,
+		DownloadsStoppedByQuota:  downloadsStopped,
+
+		DHTNodes:           int(s.metrics.DHTNodes.Value()),
+		DHTGoodNodes:       int(s.metrics.DHTGoodNodes.Value()),
+		DHTBadNodes:        int(s.metrics.DHTBadNodes.Value()),
+		DHTQueriesSent:     s.metrics.DHTQueriesSent.Value(),
+		DHTQueriesReceived: s.metrics.DHTQueriesReceived.Value(),
+		DHTPeersFound:      s.metrics.DHTPeersFound.Value(),
 	}
 }
 
@@ -152,4 +241,15 @@ func (s *Session) updateStats() {
 	if err != nil {
 		s.log.Errorln("cannot update stats:", err.Error())
 	}
+	s.updateTraffic(time.Now())
+	err = s.saveTraffic()
+	if err != nil {
+		s.log.Errorln("cannot save traffic counters:", err.Error())
+	}
+	if s.config.DHTEnabled {
+		err = s.saveDHTNodes()
+		if err != nil {
+			s.log.Errorln("cannot save DHT nodes:", err.Error())
+		}
+	}
 }
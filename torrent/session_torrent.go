@@ -13,7 +13,6 @@ import (
 	"time"
 
 	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
-	"github.com/cenkalti/rain/internal/tracker"
 	"go.etcd.io/bbolt"
 )
 
@@ -137,35 +136,113 @@ func (t *Torrent) AddPeer(addr string) error {
 	return t.torrent.addPeerString(addr)
 }
 
-// AddTracker adds a new tracker to the torrent.
+// AddTracker adds a new tracker to the torrent in a new tier.
 func (t *Torrent) AddTracker(uri string) error {
-	var private bool
-	if t.torrent.info != nil {
-		private = t.torrent.info.Private
+	err := t.torrent.checkTrackerURL(uri)
+	if err != nil {
+		return err
 	}
-	tr, err := t.torrent.session.trackerManager.Get(uri, t.torrent.session.config.TrackerHTTPTimeout, t.torrent.session.getTrackerUserAgent(private), int64(t.torrent.session.config.TrackerHTTPMaxResponseSize))
+	return t.torrent.updateTrackers(func(tiers [][]string) ([][]string, error) {
+		return append(tiers, []string{uri}), nil
+	})
+}
+
+// RemoveTracker removes the tracker from the torrent.
+// Tiers that have no trackers left are removed as well.
+func (t *Torrent) RemoveTracker(uri string) error {
+	return t.torrent.updateTrackers(func(tiers [][]string) ([][]string, error) {
+		var found bool
+		ret := tiers[:0]
+		for _, tier := range tiers {
+			urls := tier[:0]
+			for _, u := range tier {
+				if u == uri {
+					found = true
+				} else {
+					urls = append(urls, u)
+				}
+			}
+			if len(urls) > 0 {
+				ret = append(ret, urls)
+			}
+		}
+		if !found {
+			return nil, errTrackerNotFound
+		}
+		return ret, nil
+	})
+}
+
+// ReplaceTracker changes the URL of a tracker, keeping it in the same tier.
+// Useful when the passkey in the URL of a private tracker is changed.
+func (t *Torrent) ReplaceTracker(oldURI, newURI string) error {
+	err := t.torrent.checkTrackerURL(newURI)
 	if err != nil {
 		return err
 	}
-	err = t.torrent.session.db.Update(func(tx *bbolt.Tx) error {
-		b := tx.Bucket(torrentsBucket).Bucket([]byte(t.torrent.id))
-		value := b.Get(boltdbresumer.Keys.Trackers)
-		var trackers [][]string
-		err = json.Unmarshal(value, &trackers)
-		if err != nil {
-			return err
+	return t.torrent.updateTrackers(func(tiers [][]string) ([][]string, error) {
+		var found bool
+		for _, tier := range tiers {
+			for i, u := range tier {
+				if u == oldURI {
+					tier[i] = newURI
+					found = true
+				}
+			}
 		}
-		trackers = append(trackers, []string{uri})
-		value, err = json.Marshal(trackers)
-		if err != nil {
-			return err
+		if !found {
+			return nil, errTrackerNotFound
 		}
-		return b.Put(boltdbresumer.Keys.Trackers, value)
+		return tiers, nil
 	})
+}
+
+// MoveTrackerTier moves the tier at index "from" to index "to".
+// Tiers are announced in parallel; the order is kept when the torrent is exported.
+func (t *Torrent) MoveTrackerTier(from, to int) error {
+	return t.torrent.updateTrackers(func(tiers [][]string) ([][]string, error) {
+		if from < 0 || from >= len(tiers) || to < 0 || to >= len(tiers) {
+			return nil, errInvalidTierIndex
+		}
+		tier := tiers[from]
+		tiers = append(tiers[:from], tiers[from+1:]...)
+		tiers = append(tiers[:to], append([][]string{tier}, tiers[to:]...)...)
+		return tiers, nil
+	})
+}
+
+// TrackerTiers returns the tracker URLs of the torrent grouped in tiers.
+func (t *Torrent) TrackerTiers() [][]string {
+	t.torrent.mTrackers.RLock()
+	defer t.torrent.mTrackers.RUnlock()
+	return copyTiers(t.torrent.rawTrackers)
+}
+
+// SetSpeedLimits changes the download and upload speed limits of the torrent in KB/s. Zero means unlimited.
+// Global limits of the Session are applied as well.
+func (t *Torrent) SetSpeedLimits(download, upload int64) error {
+	if download < 0 || upload < 0 {
+		return errNegativeSpeedLimit
+	}
+	err := t.torrent.session.resumer.WriteSpeedLimits(t.torrent.id, download, upload)
+	if err != nil {
+		return err
+	}
+	t.torrent.bucketDownload.SetRate(download * 1024)
+	t.torrent.bucketUpload.SetRate(upload * 1024)
+	return nil
+}
+
+// SetBandwidthPriority changes the share of the torrent in the global speed limits of the Session.
+func (t *Torrent) SetBandwidthPriority(priority BandwidthPriority) error {
+	if !priority.valid() {
+		return errInvalidBandwidthPriority
+	}
+	err := t.torrent.session.resumer.WriteBandwidthPriority(t.torrent.id, int(priority))
 	if err != nil {
 		return err
 	}
-	t.torrent.AddTrackers([]tracker.Tracker{tr})
+	t.torrent.setBandwidthPriority(priority)
 	return nil
 }
 
@@ -175,6 +252,7 @@ func (t *Torrent) Start() error {
 	if err != nil {
 		return err
 	}
+	t.torrent.session.forgetInterfaceStopped(t.torrent.id)
 	t.torrent.Start()
 	return nil
 }
@@ -187,6 +265,7 @@ func (t *Torrent) Stop() error {
 	if err != nil {
 		return err
 	}
+	t.torrent.session.forgetInterfaceStopped(t.torrent.id)
 	t.torrent.Stop()
 	return nil
 }
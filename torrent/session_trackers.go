@@ -0,0 +1,114 @@
+package torrent
+
+import (
+	"encoding/json"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
+	"github.com/cenkalti/rain/internal/tracker/httptracker"
+	"go.etcd.io/bbolt"
+)
+
+// TrackerHTTPSettings contains the extra values that some private trackers require in the requests.
+type TrackerHTTPSettings struct {
+	// HTTP headers sent in announce and scrape requests.
+	Headers map[string]string
+	// Cookies sent in announce and scrape requests.
+	Cookies map[string]string
+	// Query parameters added to announce requests, e.g. "supportcrypto": "1".
+	Params map[string]string
+}
+
+func trackerHTTPSettings(m map[string]TrackerHTTPSettings) map[string]httptracker.Settings {
+	ret := make(map[string]httptracker.Settings, len(m))
+	for domain, s := range m {
+		ret[strings.ToLower(domain)] = httptracker.Settings{
+			Headers: s.Headers,
+			Cookies: s.Cookies,
+			Params:  s.Params,
+		}
+	}
+	return ret
+}
+
+// TrackerURLChange is a tracker URL of a torrent that is rewritten by Session.ReplaceTrackerURLs.
+type TrackerURLChange struct {
+	TorrentID string
+	OldURL    string
+	NewURL    string
+}
+
+// ReplaceTrackerURLs rewrites the tracker URLs matching the regular expression pattern in all torrents.
+// Inside replacement, $1 denotes the text of the first submatch as in regexp.Regexp.ReplaceAllString.
+// Useful when a private tracker moves to a new domain or the passkey is changed.
+// All torrents are saved to the database in a single transaction and the announcers of changed torrents are restarted.
+// If dryRun is true, the changes are returned without applying them.
+func (s *Session) ReplaceTrackerURLs(pattern, replacement string, dryRun bool) ([]TrackerURLChange, error) {
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		return nil, newInputError(err)
+	}
+	torrents := s.ListTorrents()
+	sort.Slice(torrents, func(i, j int) bool { return torrents[i].torrent.id < torrents[j].torrent.id })
+	for _, t := range torrents {
+		t.torrent.mTrackers.Lock()
+		defer t.torrent.mTrackers.Unlock()
+	}
+
+	var changes []TrackerURLChange
+	newTiers := make(map[*torrent][][]string)
+	for _, t := range torrents {
+		tiers := copyTiers(t.torrent.rawTrackers)
+		var changed bool
+		for _, tier := range tiers {
+			for i, u := range tier {
+				nu := re.ReplaceAllString(u, replacement)
+				if nu == u {
+					continue
+				}
+				err = t.torrent.checkTrackerURL(nu)
+				if err != nil {
+					return nil, newInputError(err)
+				}
+				changes = append(changes, TrackerURLChange{TorrentID: t.torrent.id, OldURL: u, NewURL: nu})
+				tier[i] = nu
+				changed = true
+			}
+		}
+		if changed {
+			newTiers[t.torrent] = tiers
+		}
+	}
+	if dryRun || len(newTiers) == 0 {
+		return changes, nil
+	}
+
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		for t, tiers := range newTiers {
+			b := tx.Bucket(torrentsBucket).Bucket([]byte(t.id))
+			if b == nil {
+				// Torrent is removed.
+				continue
+			}
+			value, err := json.Marshal(tiers)
+			if err != nil {
+				return err
+			}
+			err = b.Put(boltdbresumer.Keys.Trackers, value)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	for t, tiers := range newTiers {
+		t.applyTrackers(tiers)
+	}
+	s.log.Infof("replaced %d tracker URLs", len(changes))
+	return changes, nil
+}
@@ -0,0 +1,72 @@
+package torrent
+
+import (
+	"encoding/hex"
+	"fmt"
+	"net"
+	"strconv"
+
+	"github.com/cenkalti/rain/internal/trackerserver"
+)
+
+// TrackerServer is a BitTorrent tracker serving announce and scrape requests over HTTP and UDP.
+// Session runs a TrackerServer if Config.TrackerServerEnabled is true.
+type TrackerServer struct {
+	server *trackerserver.Server
+	// Info hashes in the config are never disallowed.
+	allowlist map[InfoHash]struct{}
+}
+
+// NewTrackerServer starts a new tracker with the TrackerServer* fields in cfg.
+// Info hashes in Config.TrackerServerAllowlist are allowed on the tracker.
+func NewTrackerServer(cfg Config) (*TrackerServer, error) {
+	allowlist := make(map[InfoHash]struct{}, len(cfg.TrackerServerAllowlist))
+	for _, s := range cfg.TrackerServerAllowlist {
+		b, err := hex.DecodeString(s)
+		if err != nil || len(b) != 20 {
+			return nil, fmt.Errorf("invalid info hash in tracker server allowlist: %q", s)
+		}
+		var ih InfoHash
+		copy(ih[:], b)
+		allowlist[ih] = struct{}{}
+	}
+	tcfg := trackerserver.Config{
+		AnnounceInterval:    cfg.TrackerServerAnnounceInterval,
+		MinAnnounceInterval: cfg.TrackerServerMinAnnounceInterval,
+		PeerLifetime:        2 * cfg.TrackerServerAnnounceInterval,
+		MaxNumWant:          uint32(cfg.TrackerServerMaxNumWant),
+		AllowAll:            cfg.TrackerServerAllowAll,
+	}
+	if cfg.TrackerServerHTTPPort != 0 {
+		tcfg.HTTPAddr = net.JoinHostPort(cfg.TrackerServerHost, strconv.Itoa(cfg.TrackerServerHTTPPort))
+	}
+	if cfg.TrackerServerUDPPort != 0 {
+		tcfg.UDPAddr = net.JoinHostPort(cfg.TrackerServerHost, strconv.Itoa(cfg.TrackerServerUDPPort))
+	}
+	srv, err := trackerserver.New(tcfg)
+	if err != nil {
+		return nil, err
+	}
+	for ih := range allowlist {
+		srv.Allow(ih)
+	}
+	return &TrackerServer{server: srv, allowlist: allowlist}, nil
+}
+
+// Allow the torrent to be announced to the tracker.
+func (t *TrackerServer) Allow(ih InfoHash) {
+	t.server.Allow(ih)
+}
+
+// Disallow the torrent that is previously allowed. Torrents in Config.TrackerServerAllowlist cannot be disallowed.
+func (t *TrackerServer) Disallow(ih InfoHash) {
+	if _, ok := t.allowlist[ih]; ok {
+		return
+	}
+	t.server.Disallow(ih)
+}
+
+// Close the tracker.
+func (t *TrackerServer) Close() error {
+	return t.server.Close()
+}
@@ -0,0 +1,111 @@
+package torrent
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/cenkalti/rain/internal/trafficcounter"
+	"go.etcd.io/bbolt"
+)
+
+// Actions that can be set in Config.TrafficQuotaAction.
+const (
+	// TrafficQuotaStopUploads stops uploading to peers when a quota is exceeded.
+	TrafficQuotaStopUploads = "stop-uploads"
+	// TrafficQuotaStopDownloads stops downloading from peers and WebSeed sources when a quota is exceeded.
+	TrafficQuotaStopDownloads = "stop-downloads"
+	// TrafficQuotaStopAll stops both uploads and downloads when a quota is exceeded.
+	TrafficQuotaStopAll = "stop-all"
+)
+
+// Interval for counting traffic and checking the quotas.
+const trafficUpdateInterval = time.Second
+
+var trafficKey = []byte("traffic")
+
+func validTrafficQuotaAction(s string) bool {
+	switch s {
+	case "", TrafficQuotaStopUploads, TrafficQuotaStopDownloads, TrafficQuotaStopAll:
+		return true
+	}
+	return false
+}
+
+func (s *Session) loadTraffic() error {
+	return s.db.View(func(tx *bbolt.Tx) error {
+		val := tx.Bucket(sessionBucket).Get(trafficKey)
+		if val == nil {
+			return nil
+		}
+		return json.Unmarshal(val, s.traffic)
+	})
+}
+
+func (s *Session) saveTraffic() error {
+	s.mTraffic.Lock()
+	val, err := json.Marshal(s.traffic)
+	s.mTraffic.Unlock()
+	if err != nil {
+		return err
+	}
+	return s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sessionBucket).Put(trafficKey, val)
+	})
+}
+
+func (s *Session) trafficLoop() {
+	ticker := time.NewTicker(trafficUpdateInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case now := <-ticker.C:
+			s.updateTraffic(now)
+		case <-s.closeC:
+			return
+		}
+	}
+}
+
+// updateTraffic adds the bytes transferred since last call to the counters and checks the quotas.
+func (s *Session) updateTraffic(now time.Time) {
+	downloaded := s.metrics.SpeedDownload.Count()
+	uploaded := s.metrics.SpeedUpload.Count()
+
+	s.mTraffic.Lock()
+	defer s.mTraffic.Unlock()
+	s.traffic.Add(downloaded-s.trafficLastDownloaded, uploaded-s.trafficLastUploaded, now)
+	s.trafficLastDownloaded, s.trafficLastUploaded = downloaded, uploaded
+
+	daily := s.config.DailyTrafficQuota << 20
+	monthly := s.config.MonthlyTrafficQuota << 20
+	exceeded := (daily > 0 && s.traffic.Day.Total() >= daily) || (monthly > 0 && s.traffic.Month.Total() >= monthly)
+	action := s.config.TrafficQuotaAction
+	stopUploads := exceeded && action != TrafficQuotaStopDownloads
+	stopDownloads := exceeded && action != TrafficQuotaStopUploads
+	if stopUploads != s.uploadsStoppedByQuota || stopDownloads != s.downloadsStoppedByQuota {
+		if exceeded {
+			s.log.Warningf("traffic quota exceeded, uploads stopped: %t, downloads stopped: %t", stopUploads, stopDownloads)
+		} else {
+			s.log.Info("traffic quota period is renewed, transfers are resumed")
+		}
+	}
+	s.uploadsStoppedByQuota, s.downloadsStoppedByQuota = stopUploads, stopDownloads
+}
+
+func (s *Session) uploadsStopped() bool {
+	s.mTraffic.Lock()
+	defer s.mTraffic.Unlock()
+	return s.uploadsStoppedByQuota
+}
+
+func (s *Session) downloadsStopped() bool {
+	s.mTraffic.Lock()
+	defer s.mTraffic.Unlock()
+	return s.downloadsStoppedByQuota
+}
+
+func (s *Session) trafficStats() (day, month trafficcounter.Period, uploadsStopped, downloadsStopped bool) {
+	s.mTraffic.Lock()
+	defer s.mTraffic.Unlock()
+	return s.traffic.Day, s.traffic.Month, s.uploadsStoppedByQuota, s.downloadsStoppedByQuota
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/cenkalti/rain/internal/bitfield"
 	"github.com/cenkalti/rain/internal/blocklist"
 	"github.com/cenkalti/rain/internal/bufferpool"
+	"github.com/cenkalti/rain/internal/connlimiter"
 	"github.com/cenkalti/rain/internal/externalip"
 	"github.com/cenkalti/rain/internal/handshaker/incominghandshaker"
 	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
@@ -29,6 +30,7 @@ import (
 	"github.com/cenkalti/rain/internal/piecepicker"
 	"github.com/cenkalti/rain/internal/piecewriter"
 	"github.com/cenkalti/rain/internal/resumer"
+	"github.com/cenkalti/rain/internal/speedlimit"
 	"github.com/cenkalti/rain/internal/storage"
 	"github.com/cenkalti/rain/internal/suspendchan"
 	"github.com/cenkalti/rain/internal/tracker"
@@ -49,7 +51,11 @@ type torrent struct {
 	infoHash [20]byte
 
 	// List of addresses to announce this torrent.
-	trackers    []tracker.Tracker
+	trackers []tracker.Tracker
+
+	// Tracker tiers in the order they are saved in the database.
+	// mTrackers serializes the changes made by the user.
+	mTrackers   sync.RWMutex
 	rawTrackers [][]string
 
 	// Peers added from magnet URLS with x.pe parameter.
@@ -77,6 +83,10 @@ type torrent struct {
 	// Unique peer ID is generated per downloader.
 	peerID [20]byte
 
+	// Sent to trackers in announce requests. Generated randomly when the torrent is added and saved,
+	// so it does not change between restarts.
+	announceKey uint32
+
 	files  []allocator.File
 	pieces []piece.Piece
 
@@ -101,8 +111,14 @@ type torrent struct {
 	// Keep recently seen peers to fill underpopulated PEX lists.
 	recentlySeen pexlist.RecentlySeen
 
+	// Addresses learned from PEX messages mapped to the peer that sent them.
+	// If we cannot connect to the address, we ask the peer to relay a holepunch rendezvous message.
+	holepunchRelays map[string]*peer.Peer
+
 	// Unchoker implements an algorithm to select peers to unchoke based on their download speed.
 	unchoker *unchoker.Unchoker
+	// Adjusts the number of unchoked peers if Config.AutoUploadSlots is enabled.
+	uploadSlotTuner *unchoker.SlotTuner
 
 	// Active piece downloads are kept in this map.
 	pieceDownloaders        map[*peer.Peer]*piecedownloader.PieceDownloader
@@ -147,14 +163,15 @@ type torrent struct {
 	trackersCommandC     chan trackersRequest     // Trackers()
 	peersCommandC        chan peersRequest        // Peers()
 	webseedsCommandC     chan webseedsRequest     // Webseeds()
+	scrapeCommandC       chan scrapeRequest       // scrapeTrackers()
 	startCommandC        chan struct{}            // Start()
-	stopCommandC         chan struct{}            // Stop()
+	stopCommandC         chan error               // Stop()
 	announceCommandC     chan struct{}            // Announce()
 	verifyCommandC       chan struct{}            // Verify()
 	notifyErrorCommandC  chan notifyErrorCommand  // NotifyError()
 	notifyListenCommandC chan notifyListenCommand // NotifyListen()
 	addPeersCommandC     chan []*net.TCPAddr      // AddPeers()
-	addTrackersCommandC  chan []tracker.Tracker   // AddTrackers()
+	setTrackersCommandC  chan []tracker.Tracker   // setTrackers()
 
 	// Trackers send announce responses to this channel.
 	addrsFromTrackers chan []*net.TCPAddr
@@ -185,10 +202,19 @@ type torrent struct {
 	dhtAnnouncer *announcer.DHTAnnouncer
 	dhtPeersC    chan []*net.TCPAddr
 
+	// Updated by Session when the torrent is announced to DHT and peers are found.
+	mDHTStats sync.Mutex
+	dhtStats  dhtAnnounceStats
+
 	// List of peers in handshake state.
 	incomingHandshakers map[*incominghandshaker.IncomingHandshaker]struct{}
 	outgoingHandshakers map[*outgoinghandshaker.OutgoingHandshaker]struct{}
 
+	// Counts connections against the session-wide limits. Nil if torrent is not running.
+	connOwner *connlimiter.Owner
+	// Session-wide limiter notifies the torrent over this channel when a connection slot becomes available.
+	connSlotC chan struct{}
+
 	// Handshake results are sent to these channels by handshakers.
 	incomingHandshakerResultC chan *incominghandshaker.IncomingHandshaker
 	outgoingHandshakerResultC chan *outgoinghandshaker.OutgoingHandshaker
@@ -241,6 +267,9 @@ type torrent struct {
 
 	ramNotifyC chan *peer.Peer
 
+	// Peers that are passed to the callbacks of custom extensions registered on Session.
+	extensionPeers map[*peer.Peer][]*ExtensionPeer
+
 	webseedClient          *http.Client
 	webseedSources         []*webseedsource.WebseedSource
 	rawWebseedSources      []string
@@ -260,6 +289,20 @@ type torrent struct {
 	// True means that completeCmd has run before.
 	completeCmdRun bool
 
+	// Overrides encryption settings in Config.
+	encryption EncryptionPolicy
+
+	// A piece download is not started because a traffic quota of the Session is exceeded.
+	pausedByQuota bool
+
+	// Last scrape results of stopped torrent, keyed by tracker URL. Written by the scraper of the Session.
+	mScrapes sync.Mutex
+	scrapes  map[string]trackerScrape
+
+	// Speed limits of the torrent. Session limits are applied as well.
+	bucketDownload *speedlimit.Limiter
+	bucketUpload   *speedlimit.Limiter
+
 	log logger.Logger
 }
 
@@ -282,6 +325,10 @@ func newTorrent2(
 	stopAfterDownload bool,
 	stopAfterMetadata bool,
 	completeCmdRun bool,
+	encryption EncryptionPolicy,
+	speedLimitDownload, speedLimitUpload int64, // in KB/s
+	bandwidthPriority BandwidthPriority,
+	announceKey uint32,
 ) (*torrent, error) {
 	if len(infoHash) != 20 {
 		return nil, errors.New("invalid infoHash (must be 20 bytes)")
@@ -319,17 +366,19 @@ func newTorrent2(
 		completeMetadataC:         make(chan struct{}),
 		closeC:                    make(chan struct{}),
 		startCommandC:             make(chan struct{}),
-		stopCommandC:              make(chan struct{}),
+		stopCommandC:              make(chan error),
 		announceCommandC:          make(chan struct{}),
 		verifyCommandC:            make(chan struct{}),
 		statsCommandC:             make(chan statsRequest),
 		trackersCommandC:          make(chan trackersRequest),
 		peersCommandC:             make(chan peersRequest),
 		webseedsCommandC:          make(chan webseedsRequest),
+		scrapeCommandC:            make(chan scrapeRequest),
+		scrapes:                   make(map[string]trackerScrape),
 		notifyErrorCommandC:       make(chan notifyErrorCommand),
 		notifyListenCommandC:      make(chan notifyListenCommand),
 		addPeersCommandC:          make(chan []*net.TCPAddr),
-		addTrackersCommandC:       make(chan []tracker.Tracker),
+		setTrackersCommandC:       make(chan []tracker.Tracker),
 		addrsFromTrackers:         make(chan []*net.TCPAddr),
 		peerIDs:                   make(map[[20]byte]struct{}),
 		incomingConnC:             make(chan net.Conn),
@@ -337,6 +386,7 @@ func newTorrent2(
 		infoDownloaderResultC:     make(chan *infodownloader.InfoDownloader),
 		incomingHandshakers:       make(map[*incominghandshaker.IncomingHandshaker]struct{}),
 		outgoingHandshakers:       make(map[*outgoinghandshaker.OutgoingHandshaker]struct{}),
+		connSlotC:                 make(chan struct{}, 1),
 		incomingHandshakerResultC: make(chan *incominghandshaker.IncomingHandshaker),
 		outgoingHandshakerResultC: make(chan *outgoinghandshaker.OutgoingHandshaker),
 		allocatorProgressC:        make(chan allocator.Progress),
@@ -355,6 +405,8 @@ func newTorrent2(
 		bytesWasted:               metrics.NewCounter(),
 		seededFor:                 metrics.NewCounter(),
 		ramNotifyC:                make(chan *peer.Peer),
+		extensionPeers:            make(map[*peer.Peer][]*ExtensionPeer),
+		holepunchRelays:           make(map[string]*peer.Peer),
 		webseedClient:             &s.webseedClient,
 		webseedSources:            ws,
 		webseedPieceResultC:       suspendchan.New[*urldownloader.PieceResult](0),
@@ -363,7 +415,14 @@ func newTorrent2(
 		stopAfterDownload:         stopAfterDownload,
 		stopAfterMetadata:         stopAfterMetadata,
 		completeCmdRun:            completeCmdRun,
+		encryption:                encryption,
+		bucketDownload:            speedlimit.New(speedLimitDownload*1024, s.bucketDownload),
+		bucketUpload:              speedlimit.New(speedLimitUpload*1024, s.bucketUpload),
+	}
+	if bandwidthPriority == 0 {
+		bandwidthPriority = BandwidthPriorityNormal
 	}
+	t.setBandwidthPriority(bandwidthPriority)
 	if len(t.webseedSources) > s.config.WebseedMaxSources {
 		t.webseedSources = t.webseedSources[:10]
 	}
@@ -379,12 +438,16 @@ func newTorrent2(
 	if t.info != nil {
 		t.piecePool = bufferpool.New(int(t.info.PieceLength))
 	}
+	t.announceKey = announceKey
 	n := t.copyPeerIDPrefix()
 	_, err := rand.Read(t.peerID[n:])
 	if err != nil {
 		return nil, err
 	}
-	t.unchoker = unchoker.New(cfg.UnchokedPeers, cfg.OptimisticUnchokedPeers)
+	t.unchoker = unchoker.New(cfg.UnchokedPeers, cfg.OptimisticUnchokedPeers, s.newChokingStrategy())
+	if cfg.AutoUploadSlots {
+		t.uploadSlotTuner = unchoker.NewSlotTuner(cfg.UnchokedPeers, cfg.MaxUnchokedPeers)
+	}
 	go t.run()
 	return t, nil
 }
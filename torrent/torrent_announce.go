@@ -1,25 +1,145 @@
 package torrent
 
 import (
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
 	"math"
+	"sort"
+	"strings"
 
+	"github.com/cenkalti/rain/internal/announcer"
 	"github.com/cenkalti/rain/internal/tracker"
 )
 
-func (t *torrent) handleNewTrackers(trackers []tracker.Tracker) {
-	t.trackers = append(t.trackers, trackers...)
+var (
+	errTrackerNotFound  = errors.New("tracker not found")
+	errInvalidTierIndex = errors.New("invalid tier index")
+)
+
+func (t *torrent) checkTrackerURL(uri string) error {
+	_, err := t.session.trackerManager.Get(uri, t.session.config.TrackerHTTPTimeout, t.session.getTrackerUserAgent(t.private()), int64(t.session.config.TrackerHTTPMaxResponseSize))
+	return err
+}
+
+// newAnnounceKey returns a random key for identifying the torrent to trackers.
+// It must not be guessable by others because trackers may use it to accept announces from a changed IP.
+func newAnnounceKey() (uint32, error) {
+	var b [4]byte
+	_, err := rand.Read(b[:])
+	if err != nil {
+		return 0, err
+	}
+	return binary.BigEndian.Uint32(b[:]), nil
+}
+
+func (t *torrent) private() bool {
+	return t.info != nil && t.info.Private
+}
+
+// updateTrackers changes the tracker tiers with fn, saves them to the database and restarts the announcers.
+// fn receives a copy of the current tiers and may modify it.
+func (t *torrent) updateTrackers(fn func(tiers [][]string) ([][]string, error)) error {
+	t.mTrackers.Lock()
+	defer t.mTrackers.Unlock()
+	tiers, err := fn(copyTiers(t.rawTrackers))
+	if err != nil {
+		return err
+	}
+	err = t.session.resumer.WriteTrackers(t.id, tiers)
+	if err != nil {
+		return err
+	}
+	t.applyTrackers(tiers)
+	return nil
+}
+
+// applyTrackers replaces the trackers with tiers that are already saved to the database.
+// mTrackers must be held by the caller.
+func (t *torrent) applyTrackers(tiers [][]string) {
+	t.rawTrackers = tiers
+	t.setTrackers(t.session.parseTrackers(tiers, t.private()))
+}
+
+// removeDefaultTrackers removes the default trackers that are added to a magnet before its info turns out to be private.
+// It must not be called from the run loop because updateTrackers waits for it.
+func (t *torrent) removeDefaultTrackers() {
+	err := t.updateTrackers(func(tiers [][]string) ([][]string, error) {
+		return t.session.withoutDefaultTrackers(tiers), nil
+	})
+	if err != nil {
+		t.log.Errorln("cannot remove default trackers:", err)
+	}
+}
+
+func copyTiers(tiers [][]string) [][]string {
+	ret := make([][]string, len(tiers))
+	for i, tier := range tiers {
+		ret[i] = append([]string(nil), tier...)
+	}
+	return ret
+}
+
+// handleSetTrackers replaces the trackers of the torrent.
+// Announcers of the unchanged tiers keep running, announcers of the removed tiers are stopped
+// and new announcers are started for the added tiers.
+func (t *torrent) handleSetTrackers(trackers []tracker.Tracker) {
+	old := make(map[string][]tracker.Tracker)
+	for _, tr := range t.trackers {
+		key := trackerKey(tr)
+		old[key] = append(old[key], tr)
+	}
+	for i, tr := range trackers {
+		key := trackerKey(tr)
+		if prev := old[key]; len(prev) > 0 {
+			// Keep the existing tier in order to preserve the index of the working tracker.
+			trackers[i] = prev[0]
+			old[key] = prev[1:]
+		}
+	}
+	t.trackers = trackers
+
 	status := t.status()
-	if status != Stopping && status != Stopped {
-		for _, tr := range trackers {
+	if status == Stopping || status == Stopped {
+		return
+	}
+	running := make(map[tracker.Tracker]*announcer.PeriodicalAnnouncer, len(t.announcers))
+	for _, an := range t.announcers {
+		running[an.Tracker] = an
+	}
+	t.announcers = nil
+	for _, tr := range trackers {
+		if an, ok := running[tr]; ok {
+			t.announcers = append(t.announcers, an)
+			delete(running, tr)
+		} else {
 			t.startNewAnnouncer(tr)
 		}
 	}
+	for _, an := range running {
+		an.Close()
+	}
+}
+
+// trackerKey returns a string that identifies the tier regardless of the order of trackers in it.
+func trackerKey(tr tracker.Tracker) string {
+	var urls []string
+	if tier, ok := tr.(*tracker.Tier); ok {
+		for _, tt := range tier.Trackers {
+			urls = append(urls, tt.URL())
+		}
+	} else {
+		urls = []string{tr.URL()}
+	}
+	sort.Strings(urls)
+	return strings.Join(urls, " ")
 }
 
 func (t *torrent) announcerFields() tracker.Torrent {
 	tr := tracker.Torrent{
 		InfoHash:        t.infoHash,
 		PeerID:          t.peerID,
+		Key:             t.announceKey,
 		Port:            t.port,
 		BytesDownloaded: t.bytesDownloaded.Count(),
 		BytesUploaded:   t.bytesUploaded.Count(),
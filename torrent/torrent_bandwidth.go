@@ -0,0 +1,66 @@
+package torrent
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+)
+
+// BandwidthPriority is the weight of a torrent when torrents share the global speed limits of the Session.
+// While the global limit is reached, a torrent with priority 8 gets twice the bandwidth of a torrent with priority 4.
+// Torrents do not have a hard limit, bandwidth that is not used by a torrent is shared between the others.
+type BandwidthPriority int
+
+// Built-in bandwidth priorities. Any value between 1 and MaxBandwidthPriority can be used as well.
+const (
+	BandwidthPriorityLow    BandwidthPriority = 1
+	BandwidthPriorityNormal BandwidthPriority = 4
+	BandwidthPriorityHigh   BandwidthPriority = 16
+
+	MaxBandwidthPriority BandwidthPriority = 1000
+)
+
+var errInvalidBandwidthPriority = errors.New("bandwidth priority must be low, normal, high or a number between 1 and 1000")
+
+// ParseBandwidthPriority parses a priority from "low", "normal", "high" or a number.
+func ParseBandwidthPriority(s string) (BandwidthPriority, error) {
+	switch strings.ToLower(s) {
+	case "low":
+		return BandwidthPriorityLow, nil
+	case "normal":
+		return BandwidthPriorityNormal, nil
+	case "high":
+		return BandwidthPriorityHigh, nil
+	}
+	n, err := strconv.Atoi(s)
+	if err != nil || !BandwidthPriority(n).valid() {
+		return 0, errInvalidBandwidthPriority
+	}
+	return BandwidthPriority(n), nil
+}
+
+func (p BandwidthPriority) valid() bool {
+	return p >= 1 && p <= MaxBandwidthPriority
+}
+
+// String returns the name of a built-in priority or the number.
+func (p BandwidthPriority) String() string {
+	switch p {
+	case BandwidthPriorityLow:
+		return "low"
+	case BandwidthPriorityNormal:
+		return "normal"
+	case BandwidthPriorityHigh:
+		return "high"
+	}
+	return strconv.Itoa(int(p))
+}
+
+func (t *torrent) setBandwidthPriority(p BandwidthPriority) {
+	t.bucketDownload.SetWeight(int(p))
+	t.bucketUpload.SetWeight(int(p))
+}
+
+func (t *torrent) bandwidthPriority() BandwidthPriority {
+	return BandwidthPriority(t.bucketDownload.Weight())
+}
@@ -41,6 +41,8 @@ func (t *torrent) closePeer(pe *peer.Peer) {
 		t.piecePicker.HandleDisconnect(pe)
 	}
 	t.unchoker.HandleDisconnect(pe)
+	t.closeCustomExtensionPeers(pe)
+	t.removeHolepunchRelay(pe)
 	t.pexDropPeer(pe.Addr())
 	t.dialAddresses()
 	t.session.metrics.Peers.Dec(1)
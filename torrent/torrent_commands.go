@@ -22,8 +22,13 @@ func (t *torrent) Start() {
 // Stop downloading and seeding.
 // Stop closes all peer connections.
 func (t *torrent) Stop() {
+	t.stopWithError(nil)
+}
+
+// stopWithError stops the torrent and sets the error that is reported in stats.
+func (t *torrent) stopWithError(err error) {
 	select {
-	case t.stopCommandC <- struct{}{}:
+	case t.stopCommandC <- err:
 	case <-t.closeC:
 	}
 }
@@ -159,9 +164,9 @@ func (t *torrent) AddPeers(peers []*net.TCPAddr) {
 	}
 }
 
-func (t *torrent) AddTrackers(trackers []tracker.Tracker) {
+func (t *torrent) setTrackers(trackers []tracker.Tracker) {
 	select {
-	case t.addTrackersCommandC <- trackers:
+	case t.setTrackersCommandC <- trackers:
 	case <-t.closeC:
 	}
 }
@@ -200,6 +205,34 @@ type Tracker struct {
 	Warning      string
 	LastAnnounce time.Time
 	NextAnnounce time.Time
+	// Number of peers that have completed downloading. Only known if the tracker is scraped.
+	Completed int
+	// Time of the last scrape request. Trackers of stopped torrents are scraped periodically.
+	LastScrape  time.Time
+	ScrapeError error
+	// Number of peers returned in the last announce response.
+	Peers int
+	// Total number of peers returned since the torrent is started.
+	PeersTotal int
+	// Score between 0 and 100 calculated from the recent announce results.
+	// Unhealthy trackers are retried less often and skipped in their tiers.
+	Health int
+	// Results of the recent announce requests, oldest first.
+	History []TrackerAnnounce
+}
+
+// TrackerAnnounce is the result of an announce request to a tracker.
+type TrackerAnnounce struct {
+	// URL of the tracker. Differs from Tracker.URL if another tracker in the same tier is announced.
+	URL string
+	// Time that the request is made.
+	Time time.Time
+	// One of "started", "completed" or "empty" for periodical announces.
+	Event string
+	// Number of peers returned in the response.
+	Peers   int
+	Latency time.Duration
+	Error   *AnnounceError
 }
 
 type trackersRequest struct {
@@ -236,6 +269,7 @@ type Peer struct {
 	Snubbed            bool
 	EncryptedHandshake bool
 	EncryptedStream    bool
+	UploadOnly         bool
 	DownloadSpeed      int
 	UploadSpeed        int
 }
@@ -254,6 +288,8 @@ const (
 	SourceIncoming
 	// SourceManual indicates that the peer is added manually via AddPeer method.
 	SourceManual
+	// SourceHolepunch indicates that the peer is connected with the help of another peer (BEP 55).
+	SourceHolepunch
 )
 
 type peersRequest struct {
@@ -29,6 +29,11 @@ func (t *torrent) handleNewConnection(conn net.Conn) {
 		conn.Close()
 		return
 	}
+	if !t.connOwner.AcquireConn() {
+		t.log.Debugln("session peer limit reached, rejecting peer", conn.RemoteAddr().String())
+		conn.Close()
+		return
+	}
 	h := incominghandshaker.New(conn)
 	t.incomingHandshakers[h] = struct{}{}
 	t.connectedPeerIPs[ipstr] = struct{}{}
@@ -39,6 +44,6 @@ func (t *torrent) handleNewConnection(conn net.Conn) {
 		t.incomingHandshakerResultC,
 		t.session.config.PeerHandshakeTimeout,
 		t.session.extensions,
-		t.session.config.ForceIncomingEncryption,
+		t.forceIncomingEncryption(),
 	)
 }
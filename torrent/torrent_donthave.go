@@ -0,0 +1,80 @@
+package torrent
+
+import (
+	"time"
+
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/piecepicker"
+)
+
+func (t *torrent) handleDontHave(pe *peer.Peer, i uint32) {
+	if t.piecePicker != nil {
+		t.piecePicker.HandleDontHave(pe, i)
+	} else {
+		pe.Bitfield.Clear(i)
+	}
+	if pd, ok := t.pieceDownloaders[pe]; ok && pd.Piece.Index == i {
+		t.closePieceDownloader(pd)
+		pd.CancelPending()
+		t.startPieceDownloaderFor(pe)
+	}
+	t.updateInterestedState(pe)
+}
+
+// losePiece marks a piece as missing after it is found that the data of the piece is not available anymore.
+// Connected peers are notified with lt_donthave message and the piece is going to be downloaded again.
+func (t *torrent) losePiece(i uint32) {
+	pi := &t.pieces[i]
+	if !pi.Done {
+		return
+	}
+	pi.Done = false
+	t.mBitfield.Lock()
+	t.bitfield.Clear(i)
+	t.mBitfield.Unlock()
+	err := t.writeBitfield()
+	if err != nil {
+		t.stop(err)
+		return
+	}
+	for pe := range t.peers {
+		t.sendDontHave(pe, i)
+	}
+	if t.completed {
+		t.resumeDownloading()
+	}
+}
+
+func (t *torrent) sendDontHave(pe *peer.Peer, i uint32) {
+	if pe.ExtensionHandshake == nil {
+		return
+	}
+	id, ok := pe.ExtensionHandshake.M[peerprotocol.ExtensionKeyDontHave]
+	if !ok || id == 0 {
+		return
+	}
+	msg := peerprotocol.ExtensionMessage{
+		ExtendedMessageID: id,
+		Payload:           peerprotocol.ExtensionDontHaveMessage{Index: i},
+	}
+	pe.SendMessage(msg)
+}
+
+// resumeDownloading switches a seeding torrent back to downloading state after losing some of its pieces.
+func (t *torrent) resumeDownloading() {
+	t.updateSeedDuration(time.Now())
+	t.completed = false
+	t.completeC = make(chan struct{})
+	t.piecePicker = piecepicker.New(t.pieces, t.session.config.EndgameMaxDuplicateDownloads, t.webseedSources)
+	for pe := range t.peers {
+		for i := uint32(0); i < pe.Bitfield.Len(); i++ {
+			if pe.Bitfield.Test(i) {
+				t.piecePicker.HandleHave(pe, i)
+			}
+		}
+		t.updateInterestedState(pe)
+	}
+	t.dialAddresses()
+	t.startPieceDownloaders()
+}
@@ -0,0 +1,99 @@
+package torrent
+
+import (
+	"errors"
+	"net"
+
+	"github.com/cenkalti/rain/internal/encryptioncache"
+	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
+	"github.com/cenkalti/rain/internal/peersource"
+)
+
+// EncryptionPolicy overrides the encryption settings in Config for a single torrent.
+type EncryptionPolicy string
+
+// Encryption policies that can be set in AddTorrentOptions.
+const (
+	// EncryptionDefault uses DisableOutgoingEncryption, ForceOutgoingEncryption and ForceIncomingEncryption values in Config.
+	EncryptionDefault EncryptionPolicy = ""
+	// EncryptionPrefer tries encrypted handshake first and falls back to plaintext. Both types of incoming connections are accepted.
+	EncryptionPrefer EncryptionPolicy = "prefer"
+	// EncryptionDisable dials only plaintext connections. Both types of incoming connections are accepted.
+	EncryptionDisable EncryptionPolicy = "disable"
+	// EncryptionForce dials and accepts only encrypted connections.
+	EncryptionForce EncryptionPolicy = "force"
+)
+
+var errInvalidEncryptionPolicy = errors.New("invalid encryption policy")
+
+func (p EncryptionPolicy) valid() bool {
+	switch p {
+	case EncryptionDefault, EncryptionPrefer, EncryptionDisable, EncryptionForce:
+		return true
+	}
+	return false
+}
+
+// outgoingEncryption returns whether encryption is disabled or forced for outgoing connections of the torrent.
+func (t *torrent) outgoingEncryption() (disable, force bool) {
+	switch t.encryption {
+	case EncryptionPrefer:
+		return false, false
+	case EncryptionDisable:
+		return true, false
+	case EncryptionForce:
+		return false, true
+	default:
+		return t.session.config.DisableOutgoingEncryption, t.session.config.ForceOutgoingEncryption
+	}
+}
+
+func (t *torrent) forceIncomingEncryption() bool {
+	switch t.encryption {
+	case EncryptionPrefer, EncryptionDisable:
+		return false
+	case EncryptionForce:
+		return true
+	default:
+		return t.session.config.ForceIncomingEncryption
+	}
+}
+
+// outgoingEncryptionFor returns the encryption settings for dialing the address.
+// If the peer did not accept the encryption handshake last time, it is dialed with plaintext handshake directly
+// instead of trying the encryption handshake first.
+func (t *torrent) outgoingEncryptionFor(addr *net.TCPAddr) (disable, force bool) {
+	disable, force = t.outgoingEncryption()
+	if !disable && !force && t.session.encryptionCache.Get(addr.String()) == encryptioncache.PlainText {
+		disable = true
+	}
+	return
+}
+
+// updateEncryptionCache records the handshake type accepted by the peer.
+func (t *torrent) updateEncryptionCache(oh *outgoinghandshaker.OutgoingHandshaker) {
+	if oh.Source == peersource.Holepunch {
+		// Holepunch connections are always plaintext.
+		return
+	}
+	addr := oh.Addr.String()
+	if oh.Error != nil {
+		if _, ok := oh.Error.(*net.OpError); ok {
+			// Could not connect. Handshake result is not known.
+			return
+		}
+		// Peer may have started to require encryption since last time.
+		if t.session.encryptionCache.Get(addr) == encryptioncache.PlainText {
+			t.session.encryptionCache.Remove(addr)
+		}
+		return
+	}
+	if oh.Cipher != 0 {
+		t.session.encryptionCache.Set(addr, encryptioncache.Encrypted)
+		return
+	}
+	if disable, _ := t.outgoingEncryption(); !disable {
+		// Encryption is enabled but the connection is made with plaintext handshake.
+		t.session.encryptionCache.Set(addr, encryptioncache.PlainText)
+	}
+}
@@ -0,0 +1,73 @@
+package torrent
+
+import (
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+)
+
+// addCustomExtensions advertises the extensions registered on Session in the handshake message that is going to be sent to the peer.
+func (t *torrent) addCustomExtensions(pe *peer.Peer, msg *peerprotocol.ExtensionHandshakeMessage) {
+	exts := t.session.getCustomExtensions()
+	if len(exts) == 0 {
+		return
+	}
+	eps := make([]*ExtensionPeer, 0, len(exts))
+	for _, ext := range exts {
+		ep := newExtensionPeer(ext, pe, t.id, t.infoHash)
+		eps = append(eps, ep)
+		msg.M[ext.Name] = ext.id
+		if ext.Handshake == nil {
+			continue
+		}
+		for k, v := range ext.Handshake(ep) {
+			if msg.Extra == nil {
+				msg.Extra = make(map[string]any)
+			}
+			msg.Extra[k] = v
+		}
+	}
+	t.extensionPeers[pe] = eps
+}
+
+func (t *torrent) handleCustomExtensionHandshake(pe *peer.Peer, msg peerprotocol.ExtensionHandshakeMessage) {
+	var raw map[string][]byte
+	for _, ep := range t.extensionPeers[pe] {
+		id, ok := msg.M[ep.ext.Name]
+		if !ok || id == 0 {
+			continue
+		}
+		ep.setRemoteID(id)
+		if ep.ext.OnHandshake == nil {
+			continue
+		}
+		if raw == nil {
+			raw = make(map[string][]byte, len(msg.Raw))
+			for k, v := range msg.Raw {
+				raw[k] = v
+			}
+		}
+		ep.ext.OnHandshake(ep, raw)
+	}
+}
+
+func (t *torrent) handleCustomExtensionMessage(pe *peer.Peer, msg peerprotocol.ExtensionRawMessage) {
+	for _, ep := range t.extensionPeers[pe] {
+		if ep.ext.id != msg.ExtendedMessageID {
+			continue
+		}
+		if ep.ext.OnMessage != nil {
+			ep.ext.OnMessage(ep, msg.Data)
+		}
+		return
+	}
+	pe.Logger().Debugln("received message for unknown extension id:", msg.ExtendedMessageID)
+}
+
+func (t *torrent) closeCustomExtensionPeers(pe *peer.Peer) {
+	for _, ep := range t.extensionPeers[pe] {
+		if ep.ext.OnDisconnect != nil && ep.Supported() {
+			ep.ext.OnDisconnect(ep)
+		}
+	}
+	delete(t.extensionPeers, pe)
+}
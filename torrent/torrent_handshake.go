@@ -21,17 +21,27 @@ func (t *torrent) checkInfoHash(infoHash [20]byte) bool {
 
 func (t *torrent) handleIncomingHandshakeDone(ih *incominghandshaker.IncomingHandshaker) {
 	delete(t.incomingHandshakers, ih)
+	defer t.updateConnectionCounts()
+	ip := ih.Conn.RemoteAddr().(*net.TCPAddr).IP
 	if ih.Error != nil {
-		delete(t.connectedPeerIPs, ih.Conn.RemoteAddr().(*net.TCPAddr).IP.String())
+		ih.Conn.Close()
+		delete(t.connectedPeerIPs, ip.String())
+		t.session.connGuard.HandshakeFailed(ip)
 		return
 	}
+	t.session.connGuard.HandshakeSucceeded(ip)
 	t.startPeer(ih.Conn, peersource.Incoming, t.incomingPeers, ih.PeerID, ih.Extensions, ih.Cipher)
 }
 
 func (t *torrent) handleOutgoingHandshakeDone(oh *outgoinghandshaker.OutgoingHandshaker) {
 	delete(t.outgoingHandshakers, oh)
+	defer t.updateConnectionCounts()
+	t.updateEncryptionCache(oh)
 	if oh.Error != nil {
 		delete(t.connectedPeerIPs, oh.Addr.IP.String())
+		if oh.Source == peersource.PEX && isUnreachable(oh.Error) {
+			t.sendHolepunchRendezvous(oh.Addr)
+		}
 		t.dialAddresses()
 		return
 	}
@@ -0,0 +1,200 @@
+package torrent
+
+import (
+	"errors"
+	"net"
+	"syscall"
+
+	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
+	"github.com/cenkalti/rain/internal/peer"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/peersource"
+)
+
+// maxHolepunchRelays limits the number of addresses remembered for sending rendezvous messages.
+const maxHolepunchRelays = 1000
+
+func supportsHolepunch(pe *peer.Peer) bool {
+	if pe.ExtensionHandshake == nil {
+		return false
+	}
+	id, ok := pe.ExtensionHandshake.M[peerprotocol.ExtensionKeyHolepunch]
+	return ok && id != 0
+}
+
+func (t *torrent) sendHolepunch(pe *peer.Peer, typ uint8, addr *net.TCPAddr, errCode uint32) {
+	msg := peerprotocol.ExtensionMessage{
+		ExtendedMessageID: pe.ExtensionHandshake.M[peerprotocol.ExtensionKeyHolepunch],
+		Payload: peerprotocol.ExtensionHolepunchMessage{
+			Type:  typ,
+			Addr:  addr,
+			Error: errCode,
+		},
+	}
+	pe.SendMessage(msg)
+}
+
+func (t *torrent) handleHolepunchMessage(pe *peer.Peer, msg peerprotocol.ExtensionHolepunchMessage) {
+	if !supportsHolepunch(pe) {
+		pe.Logger().Debugln("holepunch message received from peer that does not support the extension")
+		return
+	}
+	switch msg.Type {
+	case peerprotocol.ExtensionHolepunchMessageTypeRendezvous:
+		t.relayHolepunch(pe, msg.Addr)
+	case peerprotocol.ExtensionHolepunchMessageTypeConnect:
+		t.dialHolepunch(msg.Addr)
+	case peerprotocol.ExtensionHolepunchMessageTypeError:
+		pe.Logger().Debugln("holepunch error received for", msg.Addr.String(), "code:", msg.Error)
+	default:
+		pe.Logger().Debugln("unknown holepunch message type:", msg.Type)
+	}
+}
+
+// relayHolepunch sends connect messages to both sides when a peer wants to connect to another peer that we are connected to.
+func (t *torrent) relayHolepunch(pe *peer.Peer, addr *net.TCPAddr) {
+	if addr.Port == 0 || addr.IP.IsUnspecified() {
+		t.sendHolepunch(pe, peerprotocol.ExtensionHolepunchMessageTypeError, addr, peerprotocol.HolepunchErrNoSuchPeer)
+		return
+	}
+	if isSameAddr(pe.Addr(), addr) || isSameAddr(listenAddr(pe), addr) {
+		t.sendHolepunch(pe, peerprotocol.ExtensionHolepunchMessageTypeError, addr, peerprotocol.HolepunchErrNoSelf)
+		return
+	}
+	// Addresses in PEX messages are the listen addresses of the peers.
+	var target *peer.Peer
+	for p := range t.peers {
+		if isSameAddr(listenAddr(p), addr) {
+			target = p
+			break
+		}
+	}
+	if target == nil {
+		t.sendHolepunch(pe, peerprotocol.ExtensionHolepunchMessageTypeError, addr, peerprotocol.HolepunchErrNotConnected)
+		return
+	}
+	if !supportsHolepunch(target) {
+		t.sendHolepunch(pe, peerprotocol.ExtensionHolepunchMessageTypeError, addr, peerprotocol.HolepunchErrNoSupport)
+		return
+	}
+	pe.Logger().Debugln("relaying holepunch to", addr.String())
+	t.sendHolepunch(target, peerprotocol.ExtensionHolepunchMessageTypeConnect, listenAddr(pe), 0)
+	t.sendHolepunch(pe, peerprotocol.ExtensionHolepunchMessageTypeConnect, addr, 0)
+}
+
+// dialHolepunch connects to the address from our listening port, so the connection can be established
+// with TCP simultaneous open while the other side is connecting to us.
+func (t *torrent) dialHolepunch(addr *net.TCPAddr) {
+	if status := t.status(); status == Stopped || status == Stopping {
+		return
+	}
+	if _, force := t.outgoingEncryption(); force {
+		// Encryption handshake cannot be done when both sides are initiators.
+		return
+	}
+	if t.session.proxy != nil {
+		// Simultaneous open is not possible through a proxy.
+		return
+	}
+	if len(t.filterBannedIPs([]*net.TCPAddr{addr})) == 0 {
+		return
+	}
+	if len(t.outgoingPeers)+len(t.outgoingHandshakers) >= t.session.config.MaxPeerDial {
+		return
+	}
+	ip := addr.IP.String()
+	if _, ok := t.connectedPeerIPs[ip]; ok {
+		return
+	}
+	var localAddr *net.TCPAddr
+	if t.acceptor != nil {
+		localAddr = &net.TCPAddr{IP: net.ParseIP(t.session.config.Host), Port: t.port}
+	}
+	if od := t.session.outgoingDialer; od != nil {
+		lip, err := od.localIP()
+		if err != nil {
+			t.log.Debugln("cannot get outgoing address for holepunch:", err.Error())
+			return
+		}
+		if localAddr == nil {
+			localAddr = &net.TCPAddr{}
+		}
+		localAddr.IP = lip
+	}
+	if !t.connOwner.AcquireDial() {
+		return
+	}
+	h := outgoinghandshaker.NewHolepunch(addr, localAddr)
+	t.outgoingHandshakers[h] = struct{}{}
+	t.connectedPeerIPs[ip] = struct{}{}
+	go h.Run(
+		t.session.config.PeerConnectTimeout,
+		t.session.config.PeerHandshakeTimeout,
+		t.peerID,
+		t.infoHash,
+		t.outgoingHandshakerResultC,
+		t.session.extensions,
+		true,  // disableOutgoingEncryption
+		false, // forceOutgoingEncryption
+	)
+}
+
+func (t *torrent) addHolepunchRelay(pe *peer.Peer, addrs []*net.TCPAddr) {
+	if !t.session.config.HolepunchEnabled || !supportsHolepunch(pe) {
+		return
+	}
+	for _, addr := range addrs {
+		if len(t.holepunchRelays) >= maxHolepunchRelays {
+			return
+		}
+		t.holepunchRelays[addr.String()] = pe
+	}
+}
+
+func (t *torrent) removeHolepunchRelay(pe *peer.Peer) {
+	for addr, relay := range t.holepunchRelays {
+		if relay == pe {
+			delete(t.holepunchRelays, addr)
+		}
+	}
+}
+
+// sendHolepunchRendezvous asks the peer that has sent the address in a PEX message to help us for connecting to the address.
+func (t *torrent) sendHolepunchRendezvous(addr *net.TCPAddr) {
+	key := addr.String()
+	relay, ok := t.holepunchRelays[key]
+	if !ok {
+		return
+	}
+	delete(t.holepunchRelays, key)
+	relay.Logger().Debugln("sending holepunch rendezvous for", key)
+	t.sendHolepunch(relay, peerprotocol.ExtensionHolepunchMessageTypeRendezvous, addr, 0)
+}
+
+// isUnreachable returns true if the TCP connection to the peer could not be opened, which is the case when the peer is
+// behind a NAT. Errors that occur after the connection is opened mean that the peer is reachable without holepunching.
+func isUnreachable(err error) bool {
+	oe, ok := err.(*net.OpError)
+	if !ok || oe.Op != "dial" {
+		return false
+	}
+	return oe.Timeout() || errors.Is(oe, syscall.ECONNREFUSED)
+}
+
+// listenAddr returns the address that the peer accepts connections on.
+// Remote port of an incoming connection is not the listen port, so the port in the extension handshake is used instead.
+func listenAddr(pe *peer.Peer) *net.TCPAddr {
+	addr := pe.Addr()
+	if pe.Source != peersource.Incoming || pe.ExtensionHandshake == nil {
+		return addr
+	}
+	port := pe.ExtensionHandshake.ListenPort
+	if port <= 0 || port > 65535 {
+		return addr
+	}
+	return &net.TCPAddr{IP: addr.IP, Port: port}
+}
+
+func isSameAddr(a, b *net.TCPAddr) bool {
+	return a.Port == b.Port && a.IP.Equal(b.IP)
+}
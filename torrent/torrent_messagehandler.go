@@ -129,6 +129,9 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 		// pe.Logger().Debug("Peer ", pe.String(), " has piece #", pi.Index)
 		if t.piecePicker != nil {
 			t.piecePicker.HandleHave(pe, msg.Index)
+		} else {
+			// Keep the bitfield of the peer while seeding in case we lose a piece and start downloading again.
+			pe.Bitfield.Set(msg.Index)
 		}
 		t.updateInterestedState(pe)
 		t.startPieceDownloaderFor(pe)
@@ -155,6 +158,8 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 					t.piecePicker.HandleHave(pe, i)
 				}
 			}
+		} else {
+			pe.Bitfield = bf
 		}
 		t.updateInterestedState(pe)
 		t.startPieceDownloaderFor(pe)
@@ -167,6 +172,10 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			for _, pi := range t.pieces {
 				t.piecePicker.HandleHave(pe, pi.Index)
 			}
+		} else {
+			for _, pi := range t.pieces {
+				pe.Bitfield.Set(pi.Index)
+			}
 		}
 		t.updateInterestedState(pe)
 		t.startPieceDownloaderFor(pe)
@@ -220,7 +229,9 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 		t.startPieceDownloaders()
 	case peerprotocol.InterestedMessage:
 		pe.PeerInterested = true
-		t.unchoker.FastUnchoke(pe)
+		if !t.session.uploadsStopped() {
+			t.unchoker.FastUnchoke(pe)
+		}
 	case peerprotocol.NotInterestedMessage:
 		pe.PeerInterested = false
 	case peerprotocol.RequestMessage:
@@ -247,7 +258,7 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 		}
 		if pe.ClientChoking {
 			if pe.FastEnabled {
-				if pe.SentAllowedFast.Has(pi) {
+				if pe.SentAllowedFast.Has(pi) && !t.session.uploadsStopped() {
 					pe.SendPiece(msg, cachedpiece.New(pi, t.session.pieceCache, t.session.config.ReadCacheBlockSize, t.peerID))
 				} else {
 					m := peerprotocol.RejectMessage{RequestMessage: msg}
@@ -302,16 +313,29 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			}})
 		}
 	case peerprotocol.PortMessage:
-		if t.session.dht != nil {
-			t.session.dht.AddNode(fmt.Sprintf("%s:%d", pe.IP(), msg.Port))
+		if t.session.config.DHTEnabled {
+			t.session.addDHTNode(fmt.Sprintf("%s:%d", pe.IP(), msg.Port))
 		}
 	case peerwriter.BlockUploaded:
 		l := int64(msg.Length)
 		t.uploadSpeed.Mark(l)
 		t.bytesUploaded.Inc(l)
 		t.session.metrics.SpeedUpload.Mark(l)
+	case peerwriter.PieceReadError:
+		if t.pieces == nil || t.bitfield == nil || msg.Index >= uint32(len(t.pieces)) {
+			break
+		}
+		t.log.Warningf("piece #%d is lost: %s", msg.Index, msg.Error)
+		t.losePiece(msg.Index)
 	case peerprotocol.ExtensionHandshakeMessage:
 		pe.Logger().Debugln("extension handshake received:", msg)
+		// Peers may send another handshake later to update their upload_only state.
+		pe.UploadOnly = msg.UploadOnly != 0
+		if t.completed && pe.UploadOnly {
+			pe.Logger().Debugln("closing upload only peer because we are seeding too")
+			t.closePeer(pe)
+			break
+		}
 		if pe.ExtensionHandshake != nil {
 			pe.Logger().Debugln("peer changed extensions")
 			break
@@ -331,6 +355,7 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 				}
 			}
 		}
+		t.handleCustomExtensionHandshake(pe, msg)
 	case peerprotocol.ExtensionMetadataMessage:
 		t.handleMetadataMessage(pe, msg)
 	case peerprotocol.ExtensionPEXMessage:
@@ -342,6 +367,7 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			t.log.Error(err)
 			break
 		}
+		t.addHolepunchRelay(pe, addrs)
 		t.handleNewPeers(addrs, peersource.PEX)
 		addrs, err = tracker.DecodePeersCompact([]byte(msg.Dropped))
 		if err != nil {
@@ -349,6 +375,25 @@ func (t *torrent) handlePeerMessage(pm peer.Message) {
 			break
 		}
 		t.handleNewPeers(addrs, peersource.PEX)
+	case peerprotocol.ExtensionDontHaveMessage:
+		// Save donthave messages for processing later received while we don't have info yet.
+		if t.pieces == nil || t.bitfield == nil {
+			pe.Messages = append(pe.Messages, msg)
+			break
+		}
+		if msg.Index >= t.info.NumPieces {
+			pe.Logger().Errorln("unexpected donthave piece index:", msg.Index)
+			t.closePeer(pe)
+			break
+		}
+		t.handleDontHave(pe, msg.Index)
+	case peerprotocol.ExtensionHolepunchMessage:
+		if !t.session.config.HolepunchEnabled {
+			break
+		}
+		t.handleHolepunchMessage(pe, msg)
+	case peerprotocol.ExtensionRawMessage:
+		t.handleCustomExtensionMessage(pe, msg)
 	default:
 		panic(fmt.Sprintf("unhandled peer message type: %T", msg))
 	}
@@ -88,6 +88,7 @@ func (t *torrent) handleMetadataMessage(pe *peer.Peer, msg peerprotocol.Extensio
 			break
 		}
 		if info.Private {
+			go t.removeDefaultTrackers()
 			t.stop(errors.New("private torrent from magnet"))
 			break
 		}
@@ -6,6 +6,7 @@ import (
 	"strconv"
 
 	"github.com/cenkalti/rain/internal/bitfield"
+	"github.com/cenkalti/rain/internal/externalip"
 	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
 	"github.com/cenkalti/rain/internal/mse"
 	"github.com/cenkalti/rain/internal/peer"
@@ -88,25 +89,35 @@ func (t *torrent) filterBannedIPs(a []*net.TCPAddr) []*net.TCPAddr {
 }
 
 func (t *torrent) dialAddresses() {
+	// Release the slots that are acquired but not used.
+	defer t.updateConnectionCounts()
 	if t.completed {
+		t.connOwner.StopWaiting()
 		return
 	}
 	peersConnected := func() int {
 		return len(t.outgoingPeers) + len(t.outgoingHandshakers)
 	}
 	for peersConnected() < t.session.config.MaxPeerDial {
+		if !t.connOwner.AcquireDial() {
+			// Session-wide limit is reached. We will be notified when a slot is available.
+			return
+		}
 		addr, src := t.addrList.Pop()
 		if addr == nil {
+			t.connOwner.StopWaiting()
 			t.setNeedMorePeers(true)
 			return
 		}
 		ip := addr.IP.String()
 		if _, ok := t.connectedPeerIPs[ip]; ok {
+			t.updateConnectionCounts()
 			continue
 		}
-		h := outgoinghandshaker.New(addr, src)
+		h := outgoinghandshaker.New(addr, src, t.session.dialer)
 		t.outgoingHandshakers[h] = struct{}{}
 		t.connectedPeerIPs[ip] = struct{}{}
+		disableEncryption, forceEncryption := t.outgoingEncryptionFor(addr)
 		go h.Run(
 			t.session.config.PeerConnectTimeout,
 			t.session.config.PeerHandshakeTimeout,
@@ -114,8 +125,8 @@ func (t *torrent) dialAddresses() {
 			t.infoHash,
 			t.outgoingHandshakerResultC,
 			t.session.extensions,
-			t.session.config.DisableOutgoingEncryption,
-			t.session.config.ForceOutgoingEncryption,
+			disableEncryption,
+			forceEncryption,
 		)
 	}
 }
@@ -140,7 +151,7 @@ func (t *torrent) startPeer(
 	}
 	t.peerIDs[peerID] = struct{}{}
 
-	pe := peer.New(conn, source, peerID, extensions, cipher, t.session.config.PieceReadTimeout, t.session.config.RequestTimeout, t.session.config.MaxRequestsIn, t.session.bucketDownload, t.session.bucketUpload)
+	pe := peer.New(conn, source, peerID, extensions, cipher, t.session.config.PieceReadTimeout, t.session.config.RequestTimeout, t.session.config.MaxRequestsIn, t.bucketDownload, t.bucketUpload)
 	t.peers[pe] = struct{}{}
 	peers[pe] = struct{}{}
 	if t.info != nil {
@@ -173,6 +184,19 @@ func (t *torrent) sendFirstMessage(p *peer.Peer) {
 	}
 	if p.ExtensionsEnabled {
 		extHandshakeMsg := peerprotocol.NewExtensionHandshake(metadataSize, t.getClientVersion(), p.Addr().IP, t.session.config.MaxRequestsIn)
+		if t.completed {
+			extHandshakeMsg.UploadOnly = 1
+		}
+		if t.acceptor != nil {
+			extHandshakeMsg.ListenPort = t.port
+		}
+		if !t.session.config.HolepunchEnabled {
+			delete(extHandshakeMsg.M, peerprotocol.ExtensionKeyHolepunch)
+		}
+		if ip := externalip.FirstExternalIP(); ip != nil {
+			extHandshakeMsg.IPv4 = string(ip.To4())
+		}
+		t.addCustomExtensions(p, &extHandshakeMsg)
 		msg := peerprotocol.ExtensionMessage{
 			ExtendedMessageID: peerprotocol.ExtensionIDHandshake,
 			Payload:           extHandshakeMsg,
@@ -188,6 +212,24 @@ func (t *torrent) sendFirstMessage(p *peer.Peer) {
 	}
 }
 
+// sendUploadOnly sends another extension handshake to tell the peer that we have become a seeder (BEP 21).
+// Other keys are not repeated because the peer keeps the values of the keys that are missing in the update.
+func (t *torrent) sendUploadOnly(p *peer.Peer) {
+	if !p.ExtensionsEnabled {
+		return
+	}
+	msg := peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDHandshake,
+		Payload: peerprotocol.ExtensionHandshakeMessage{
+			M:            map[string]uint8{},
+			V:            t.getClientVersion(),
+			RequestQueue: t.session.config.MaxRequestsIn,
+			UploadOnly:   1,
+		},
+	}
+	p.SendMessage(msg)
+}
+
 func (t *torrent) getClientVersion() string {
 	if t.info != nil && t.info.Private {
 		return t.session.config.PrivateExtensionHandshakeClientVersion
@@ -225,3 +267,9 @@ func (t *torrent) handlePeerSnubbed(pe *peer.Peer) {
 		t.startInfoDownloaders()
 	}
 }
+
+// updateConnectionCounts reports the number of connections to the session-wide connection limiter.
+func (t *torrent) updateConnectionCounts() {
+	conns := len(t.peers) + len(t.incomingHandshakers) + len(t.outgoingHandshakers)
+	t.connOwner.Set(conns, len(t.outgoingHandshakers))
+}
@@ -31,9 +31,11 @@ func (t *torrent) checkCompletion() bool {
 		t.closeWebseedDownloader(src)
 	}
 	for pe := range t.peers {
-		if !pe.PeerInterested {
+		if !pe.PeerInterested || pe.UploadOnly {
 			t.closePeer(pe)
+			continue
 		}
+		t.sendUploadOnly(pe)
 	}
 	t.addrList.Reset()
 	for _, pd := range t.pieceDownloaders {
@@ -22,8 +22,8 @@ func (t *torrent) run() {
 			return
 		case <-t.startCommandC:
 			t.start()
-		case <-t.stopCommandC:
-			t.stop(nil)
+		case err := <-t.stopCommandC:
+			t.stop(err)
 		case <-t.announceCommandC:
 			t.setNeedMorePeers(true)
 		case <-t.verifyCommandC:
@@ -42,6 +42,8 @@ func (t *torrent) run() {
 			req.Response <- t.getPeers()
 		case req := <-t.webseedsCommandC:
 			req.Response <- t.getWebseeds()
+		case req := <-t.scrapeCommandC:
+			t.handleScrapeCommand(req)
 		case p := <-t.allocatorProgressC:
 			t.bytesAllocated = p.AllocatedSize
 		case al := <-t.allocatorResultC:
@@ -58,8 +60,8 @@ func (t *torrent) run() {
 			t.handleNewPeers(addrs, peersource.Manual)
 		case addrs := <-t.dhtPeersC:
 			t.handleNewPeers(addrs, peersource.DHT)
-		case trackers := <-t.addTrackersCommandC:
-			t.handleNewTrackers(trackers)
+		case trackers := <-t.setTrackersCommandC:
+			t.handleSetTrackers(trackers)
 		case conn := <-t.incomingConnC:
 			t.handleNewConnection(conn)
 		case res := <-t.webseedPieceResultC.ReceiveC():
@@ -73,13 +75,15 @@ func (t *torrent) run() {
 		case pe := <-t.peerSnubbedC:
 			t.handlePeerSnubbed(pe)
 		case <-t.unchokeTicker.C:
-			t.unchoker.TickUnchoke(t.getPeersForUnchoker(), t.completed)
+			t.tickUnchoke()
 		case ih := <-t.incomingHandshakerResultC:
 			t.handleIncomingHandshakeDone(ih)
 		case oh := <-t.outgoingHandshakerResultC:
 			t.handleOutgoingHandshakeDone(oh)
 		case pe := <-t.peerDisconnectedC:
 			t.closePeer(pe)
+		case <-t.connSlotC:
+			t.dialAddresses()
 		case pm := <-t.pieceMessagesC.ReceiveC():
 			t.handlePieceMessage(pm)
 		case pm := <-t.messages:
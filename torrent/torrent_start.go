@@ -6,9 +6,12 @@ import (
 	"github.com/cenkalti/rain/internal/acceptor"
 	"github.com/cenkalti/rain/internal/allocator"
 	"github.com/cenkalti/rain/internal/announcer"
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/externalip"
 	"github.com/cenkalti/rain/internal/peer"
 	"github.com/cenkalti/rain/internal/piecedownloader"
 	"github.com/cenkalti/rain/internal/piecepicker"
+	"github.com/cenkalti/rain/internal/portmap"
 	"github.com/cenkalti/rain/internal/tracker"
 	"github.com/cenkalti/rain/internal/urldownloader"
 	"github.com/cenkalti/rain/internal/verifier"
@@ -29,7 +32,12 @@ func (t *torrent) start() {
 	}
 
 	t.log.Info("starting torrent")
+	if t.externalIP == nil {
+		// External IP of the gateway may have been discovered by port mapping after the torrent is created.
+		t.externalIP = externalip.FirstExternalIP()
+	}
 	t.errC = make(chan error, 1)
+	t.connOwner = t.session.connLimiter.NewOwner(t.connSlotC)
 	t.portC = make(chan int, 1)
 	t.lastError = nil
 	t.downloadSpeed = metrics.NewMeter()
@@ -89,6 +97,9 @@ func (t *torrent) startAnnouncers() {
 	}
 	if t.dhtAnnouncer == nil && t.session.config.DHTEnabled && (t.info == nil || !t.info.Private) {
 		t.dhtAnnouncer = announcer.NewDHTAnnouncer()
+		t.mDHTStats.Lock()
+		t.dhtStats = dhtAnnounceStats{}
+		t.mDHTStats.Unlock()
 		go t.dhtAnnouncer.Run(t.announceDHT, t.session.config.DHTAnnounceInterval, t.session.config.DHTMinAnnounceInterval, t.log)
 	}
 }
@@ -111,16 +122,22 @@ func (t *torrent) startAcceptor() {
 	if t.acceptor != nil {
 		return
 	}
+	if t.session.config.ProxyOnly {
+		return
+	}
 	ip := net.ParseIP(t.session.config.Host)
-	listener, err := net.ListenTCP("tcp4", &net.TCPAddr{IP: ip, Port: t.port})
+	listener, err := btconn.Listen(&net.TCPAddr{IP: ip, Port: t.port}, t.session.config.HolepunchEnabled)
 	if err != nil {
 		t.log.Warningf("cannot listen port %d: %s", t.port, err)
 	} else {
 		t.log.Info("Listening peers on tcp://" + listener.Addr().String())
 		t.port = listener.Addr().(*net.TCPAddr).Port
 		t.portC <- t.port
-		t.acceptor = acceptor.New(listener, t.incomingConnC, t.log)
+		t.acceptor = acceptor.New(listener, t.session.connGuard.Accept, t.incomingConnC, t.log)
 		go t.acceptor.Run()
+		if t.session.portMapper != nil {
+			t.session.portMapper.Add(portmap.TCP, t.port)
+		}
 	}
 }
 
@@ -166,6 +183,10 @@ func (t *torrent) startPieceDownloaderForWebseed(src *webseedsource.WebseedSourc
 	if t.status() != Downloading {
 		return false
 	}
+	if t.session.downloadsStopped() {
+		t.pausedByQuota = true
+		return false
+	}
 	sp := t.piecePicker.PickWebseed(src)
 	if sp == nil {
 		return false
@@ -177,7 +198,7 @@ func (t *torrent) startPieceDownloaderForWebseed(src *webseedsource.WebseedSourc
 
 func (t *torrent) startWebseedDownloader(sp *piecepicker.WebseedDownloadSpec) {
 	t.log.Debugf("downloading pieces %d-%d from webseed %s", sp.Begin, sp.End, sp.Source.URL)
-	ud := urldownloader.New(sp.Source.URL, sp.Begin, sp.End, t.session.bucketDownload)
+	ud := urldownloader.New(sp.Source.URL, sp.Begin, sp.End, t.bucketDownload)
 	for _, src := range t.webseedSources {
 		if src != sp.Source {
 			continue
@@ -198,6 +219,10 @@ func (t *torrent) startPieceDownloaderFor(pe *peer.Peer) {
 	if t.status() != Downloading {
 		return
 	}
+	if t.session.downloadsStopped() {
+		t.pausedByQuota = true
+		return
+	}
 	if t.session.ram == nil {
 		t.startSinglePieceDownloader(pe)
 		return
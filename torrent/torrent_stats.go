@@ -110,6 +110,13 @@ type Stats struct {
 		// Uploaded bytes per second.
 		Upload int
 	}
+	// Speed limits of the torrent in KB/s. Zero means unlimited. Session limits are applied as well.
+	SpeedLimit struct {
+		Download int64
+		Upload   int64
+	}
+	// Share of the torrent in the global speed limits.
+	BandwidthPriority BandwidthPriority
 	// Time remaining to complete download. nil value means infinity.
 	ETA *time.Duration
 }
@@ -148,6 +155,9 @@ func (t *torrent) stats() Stats {
 	s.Pieces.Checked = t.checkedPieces
 	s.Speed.Download = int(t.downloadSpeed.Rate1())
 	s.Speed.Upload = int(t.uploadSpeed.Rate1())
+	s.SpeedLimit.Download = t.bucketDownload.Rate() / 1024
+	s.SpeedLimit.Upload = t.bucketUpload.Rate() / 1024
+	s.BandwidthPriority = t.bandwidthPriority()
 
 	if t.info != nil {
 		s.Bytes.Total = t.info.Length
@@ -203,18 +213,81 @@ func (t *torrent) avaliablePieceCount() uint32 {
 }
 
 func (t *torrent) bytesComplete() int64 {
-	if t.bitfield == nil || len(t.pieces) == 0 {
+	// Calculation is done with t.info instead of t.pieces because pieces are released when the torrent is stopped,
+	// but the correct value is still needed for sending "left" field in the stop announce.
+	if t.bitfield == nil || t.info == nil || t.info.NumPieces == 0 {
 		return 0
 	}
 	n := int64(t.info.PieceLength) * int64(t.bitfield.Count())
 	if t.bitfield.Test(t.bitfield.Len() - 1) {
 		n -= int64(t.info.PieceLength)
-		n += int64(t.pieces[t.bitfield.Len()-1].Length)
+		n += t.info.Length - int64(t.info.PieceLength)*int64(t.info.NumPieces-1)
 	}
 	return n
 }
 
+// getTrackers returns the status of trackers.
+// If the torrent is announced to DHT, a pseudo-tracker with dhtTrackerURL is included.
 func (t *torrent) getTrackers() []Tracker {
+	trackers := t.getAnnouncerTrackers()
+	if t.dhtAnnouncer != nil {
+		trackers = append(trackers, t.dhtTracker())
+	}
+	return trackers
+}
+
+// URL of the pseudo-tracker that shows the DHT announce status in Torrent.Trackers.
+const dhtTrackerURL = "DHT"
+
+// dhtAnnounceStats contains the results of DHT announces of a torrent since it is started.
+type dhtAnnounceStats struct {
+	Announces     int
+	LastAnnounce  time.Time
+	LastPeers     int
+	LastPeersTime time.Time
+	PeersTotal    int
+}
+
+// dhtTracker returns the DHT announce status of the torrent in Tracker format.
+func (t *torrent) dhtTracker() Tracker {
+	t.mDHTStats.Lock()
+	st := t.dhtStats
+	t.mDHTStats.Unlock()
+	tr := Tracker{
+		URL:          dhtTrackerURL,
+		LastAnnounce: st.LastAnnounce,
+		Peers:        st.LastPeers,
+		PeersTotal:   st.PeersTotal,
+	}
+	switch {
+	case st.Announces == 0:
+		tr.Status = NotContactedYet
+	case st.PeersTotal > 0:
+		tr.Status = Working
+	default:
+		// DHT does not report errors. Peers may be found later.
+		tr.Status = Contacting
+	}
+	return tr
+}
+
+func (t *torrent) getAnnouncerTrackers() []Tracker {
+	if len(t.announcers) == 0 {
+		// Torrent is not running. Show the scrape results.
+		all := flattenTrackers(t.trackers)
+		trackers := make([]Tracker, len(all))
+		for i, tr := range all {
+			trackers[i] = Tracker{URL: tr.URL()}
+			if sc, ok := t.getScrape(tr.URL()); ok {
+				trackers[i].Seeders = int(sc.Seeders)
+				trackers[i].Leechers = int(sc.Leechers)
+				trackers[i].Completed = int(sc.Completed)
+				trackers[i].LastScrape = sc.Time
+				trackers[i].ScrapeError = sc.Error
+			}
+		}
+		return trackers
+	}
 	trackers := make([]Tracker, len(t.announcers))
 	for i, an := range t.announcers {
 		st := an.Stats()
@@ -226,6 +299,27 @@ func (t *torrent) getTrackers() []Tracker {
 			Warning:      st.Warning,
 			LastAnnounce: st.LastAnnounce,
 			NextAnnounce: st.NextAnnounce,
+			Peers:        st.Peers,
+			PeersTotal:   st.PeersTotal,
+			Health:       st.Health,
+			History:      make([]TrackerAnnounce, len(st.History)),
+		}
+		for j, r := range st.History {
+			trackers[i].History[j] = TrackerAnnounce{
+				URL:     r.URL,
+				Time:    r.Time,
+				Event:   r.Event.String(),
+				Peers:   r.Peers,
+				Latency: r.Latency,
+			}
+			if r.Error != nil {
+				trackers[i].History[j].Error = &AnnounceError{r.Error}
+			}
+		}
+		if sc, ok := t.getScrape(trackers[i].URL); ok {
+			trackers[i].Completed = int(sc.Completed)
+			trackers[i].LastScrape = sc.Time
+			trackers[i].ScrapeError = sc.Error
 		}
 		if st.Error != nil {
 			trackers[i].Error = &AnnounceError{st.Error}
@@ -249,6 +343,8 @@ func (t *torrent) getPeers() []Peer {
 			source = SourceIncoming
 		case peersource.Manual:
 			source = SourceManual
+		case peersource.Holepunch:
+			source = SourceHolepunch
 		default:
 			panic("unhandled peer source")
 		}
@@ -266,6 +362,7 @@ func (t *torrent) getPeers() []Peer {
 			Snubbed:            pe.Snubbed,
 			EncryptedHandshake: pe.EncryptionCipher != 0,
 			EncryptedStream:    pe.EncryptionCipher == mse.RC4,
+			UploadOnly:         pe.UploadOnly,
 			Source:             source,
 			DownloadSpeed:      pe.DownloadSpeed(),
 			UploadSpeed:        pe.UploadSpeed(),
@@ -4,6 +4,7 @@ import (
 	"github.com/cenkalti/rain/internal/announcer"
 	"github.com/cenkalti/rain/internal/handshaker/incominghandshaker"
 	"github.com/cenkalti/rain/internal/handshaker/outgoinghandshaker"
+	"github.com/cenkalti/rain/internal/portmap"
 	"github.com/cenkalti/rain/internal/tracker"
 	"github.com/rcrowley/go-metrics"
 )
@@ -75,6 +76,8 @@ func (t *torrent) stop(err error) {
 
 	t.stopOutgoingHandshakers()
 	t.stopIncomingHandshakers()
+	t.connOwner.Close()
+	t.connOwner = nil
 
 	t.resetSpeeds()
 
@@ -173,6 +176,9 @@ func (t *torrent) stopAcceptor() {
 	t.log.Debugln("stopping acceptor")
 	if t.acceptor != nil {
 		t.acceptor.Close()
+		if t.session.portMapper != nil {
+			t.session.portMapper.Remove(portmap.TCP, t.port)
+		}
 	}
 	t.acceptor = nil
 }
@@ -1,6 +1,13 @@
 package torrent
 
 import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"expvar"
+	"io"
 	"net"
 	"net/http"
 	"os"
@@ -11,7 +18,13 @@ import (
 	"testing"
 	"time"
 
+	"github.com/cenkalti/rain/internal/btconn"
+	"github.com/cenkalti/rain/internal/encryptioncache"
 	"github.com/cenkalti/rain/internal/logger"
+	"github.com/cenkalti/rain/internal/metainfo"
+	"github.com/cenkalti/rain/internal/peerconn/peerreader"
+	"github.com/cenkalti/rain/internal/peerprotocol"
+	"github.com/cenkalti/rain/internal/resumer/boltdbresumer"
 	"github.com/cenkalti/rain/internal/webseedsource"
 	fhttp "github.com/chihaya/chihaya/frontend/http"
 	"github.com/chihaya/chihaya/middleware"
@@ -19,6 +32,7 @@ import (
 	_ "github.com/chihaya/chihaya/storage/memory"
 	"github.com/fortytw2/leaktest"
 	"github.com/stretchr/testify/assert"
+	"go.etcd.io/bbolt"
 )
 
 var (
@@ -34,15 +48,32 @@ func init() {
 	logger.SetDebug()
 }
 
-func newTestSession(t *testing.T) (*Session, func()) {
-	tmp, closeTmp := tempdir(t)
+// testConfig returns the config of a test session that keeps its files in dir.
+func testConfig(dir string) Config {
 	cfg := DefaultConfig
-	cfg.Database = filepath.Join(tmp, "session.db")
-	cfg.DataDir = tmp
+	cfg.Database = filepath.Join(dir, "session.db")
+	cfg.DataDir = dir
 	cfg.DHTEnabled = false
 	cfg.PEXEnabled = false
 	cfg.RPCEnabled = false
 	cfg.Host = "127.0.0.1"
+	// startSeeding modifies trackers of a stopped torrent, which would race with the scraper.
+	// Tests call scrapeStoppedTorrents directly instead.
+	cfg.TrackerScrapeInterval = 0
+	return cfg
+}
+
+func newTestSession(t *testing.T) (*Session, func()) {
+	return newTestSessionWithConfig(t, nil)
+}
+
+// newTestSessionWithConfig is like newTestSession but calls configure to modify the config before creating the session.
+func newTestSessionWithConfig(t *testing.T, configure func(cfg *Config)) (*Session, func()) {
+	tmp, closeTmp := tempdir(t)
+	cfg := testConfig(tmp)
+	if configure != nil {
+		configure(&cfg)
+	}
 	s, err := NewSession(cfg)
 	if err != nil {
 		t.Fatal(err)
@@ -62,12 +93,16 @@ func CopyDir(src, dst string) error {
 }
 
 func seeder(t *testing.T, clearTrackers bool) (addr string, c func()) {
+	s, closeSession := newTestSession(t)
+	return startSeeding(t, s, clearTrackers), closeSession
+}
+
+func startSeeding(t *testing.T, s *Session, clearTrackers bool) (addr string) {
 	f, err := os.Open(torrentFile)
 	if err != nil {
 		t.Fatal(err)
 	}
 	defer f.Close()
-	s, closeSession := newTestSession(t)
 	opt := &AddTorrentOptions{Stopped: true}
 	tor, err := s.AddTorrent(f, opt)
 	if err != nil {
@@ -95,9 +130,7 @@ func seeder(t *testing.T, clearTrackers bool) (addr string, c func()) {
 	case <-time.After(timeout):
 		t.Fatal("seeder is not ready")
 	}
-	return "127.0.0.1:" + strconv.Itoa(port), func() {
-		closeSession()
-	}
+	return "127.0.0.1:" + strconv.Itoa(port)
 }
 
 func tempdir(t *testing.T) (string, func()) {
@@ -151,6 +184,64 @@ func TestDownloadTorrent(t *testing.T) {
 	assertCompleted(t, tor)
 }
 
+func TestTrackerServer(t *testing.T) {
+	seeder, closeSeeder := newTestSessionWithConfig(t, func(cfg *Config) {
+		// Sample torrent announces to this tracker.
+		cfg.TrackerServerEnabled = true
+		cfg.TrackerServerHost = "127.0.0.1"
+		cfg.TrackerServerHTTPPort = 5000
+		cfg.TrackerServerUDPPort = 0
+	})
+	defer closeSeeder()
+	startSeeding(t, seeder, false)
+
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	tor, err := s.AddTorrent(f, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	assertCompleted(t, tor)
+}
+
+func TestTrackerServerPortInUse(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := testConfig(tmp)
+	cfg.DHTEnabled = true
+	cfg.DHTHost = "127.0.0.1"
+	cfg.DHTPort = 5020
+	cfg.DHTBootstrapNodes = nil
+	cfg.TrackerServerEnabled = true
+	cfg.TrackerServerHost = "127.0.0.1"
+	cfg.TrackerServerHTTPPort = l.Addr().(*net.TCPAddr).Port
+	cfg.TrackerServerUDPPort = 0
+	_, err = NewSession(cfg)
+	assert.Error(t, err)
+
+	// Database and DHT port are not left open.
+	cfg.TrackerServerEnabled = false
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, s.Close())
+}
+
 func TestTorrentRootDirectory(t *testing.T) {
 	defer leaktest.Check(t)()
 	addr, cl := seeder(t, true)
@@ -187,6 +278,1092 @@ func TestTorrentFiles(t *testing.T) {
 	assertCompleted(t, tor)
 }
 
+// testPeer is a peer connection that is driven by the test for sending and receiving raw protocol messages.
+type testPeer struct {
+	conn   net.Conn
+	reader *peerreader.PeerReader
+}
+
+// dialTestPeer connects to the torrent at addr from localIP and completes the handshake
+// with the extension protocol and the fast extension enabled.
+func dialTestPeer(t *testing.T, addr, localIP string) *testPeer {
+	taddr, err := net.ResolveTCPAddr("tcp4", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	infoHash, err := hex.DecodeString(torrentInfoHashString)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ih, peerID [20]byte
+	var extensions [8]byte
+	copy(ih[:], infoHash)
+	copy(peerID[:], "-TEST-"+localIP)
+	extensions[5] |= 0x10
+	extensions[7] |= 0x04
+	dialer := &net.Dialer{LocalAddr: &net.TCPAddr{IP: net.ParseIP(localIP)}}
+	conn, _, _, _, err := btconn.Dial(taddr, dialer, timeout, timeout, false, false, extensions, ih, peerID, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Remove the handshake deadline.
+	_ = conn.SetDeadline(time.Time{})
+	p := &testPeer{
+		conn:   conn,
+		reader: peerreader.New(conn, logger.New("test peer"), timeout, nil),
+	}
+	go p.reader.Run()
+	return p
+}
+
+func (p *testPeer) Close() {
+	p.reader.Stop()
+	p.conn.Close()
+	<-p.reader.Done()
+}
+
+func (p *testPeer) send(t *testing.T, msg peerprotocol.Message) {
+	var buf bytes.Buffer
+	buf.Write([]byte{0, 0, 0, 0, byte(msg.ID())})
+	var err error
+	if wt, ok := msg.(io.WriterTo); ok {
+		_, err = wt.WriteTo(&buf)
+	} else {
+		_, err = buf.ReadFrom(msg)
+	}
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := buf.Bytes()
+	binary.BigEndian.PutUint32(b, uint32(len(b)-4))
+	_, err = p.conn.Write(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// waitMessage returns the first received message for which match returns true.
+func (p *testPeer) waitMessage(t *testing.T, match func(msg any) bool) any {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case msg := <-p.reader.Messages():
+			if match(msg) {
+				return msg
+			}
+		case <-p.reader.Done():
+			t.Fatal("connection is closed")
+		case <-deadline:
+			t.Fatal("message is not received")
+		}
+	}
+}
+
+// waitClosed reads messages until the connection is closed by the remote peer.
+func (p *testPeer) waitClosed(t *testing.T) {
+	deadline := time.After(timeout)
+	for {
+		select {
+		case <-p.reader.Messages():
+		case <-p.reader.Done():
+			return
+		case <-deadline:
+			t.Fatal("connection is not closed")
+		}
+	}
+}
+
+func TestCustomExtension(t *testing.T) {
+	defer leaktest.Check(t)()
+	s1, closeSession1 := newTestSession(t)
+	defer closeSession1()
+	err := s1.RegisterExtension(Extension{
+		Name: "xx_test",
+		OnHandshake: func(p *ExtensionPeer, handshake map[string][]byte) {
+			_ = p.Send([]byte("ping " + string(handshake["xx_value"])))
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := startSeeding(t, s1, true)
+
+	s2, closeSession2 := newTestSession(t)
+	defer closeSession2()
+	received := make(chan string, 1)
+	err = s2.RegisterExtension(Extension{
+		Name: "xx_test",
+		Handshake: func(p *ExtensionPeer) map[string]any {
+			return map[string]any{"xx_value": "foo"}
+		},
+		OnMessage: func(p *ExtensionPeer, payload []byte) {
+			select {
+			case received <- string(payload):
+			default:
+			}
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, s2.RegisterExtension(Extension{Name: "xx_test"}))
+	assert.Error(t, s2.RegisterExtension(Extension{Name: "ut_pex"}))
+
+	tor, err := s2.AddURI(torrentMagnetLink+"&x.pe="+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case msg := <-received:
+		assert.Equal(t, "ping 3:foo", msg)
+	case <-time.After(timeout):
+		t.Fatal("extension message is not received")
+	}
+	assertCompleted(t, tor)
+}
+
+func TestFailedHandshakesReleaseConnectionSlots(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.MaxIncomingPerIP = 1
+		cfg.MaxHandshakeFailures = 0
+	})
+	defer closeSession()
+	addr := startSeeding(t, s, true)
+
+	// Handshake for a torrent that the session does not have.
+	handshake := append([]byte("\x13BitTorrent protocol"), make([]byte, 8+20+20)...)
+	for i := 0; i < 3; i++ {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = conn.Write(handshake)
+		if err != nil {
+			t.Fatal(err)
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(timeout))
+		_, err = conn.Read(make([]byte, 1))
+		conn.Close()
+		if nerr, ok := err.(net.Error); ok && nerr.Timeout() {
+			t.Fatal("connection is not closed after failed handshake")
+		}
+	}
+	assert.Equal(t, int64(0), s.connGuard.Stats().RejectedTooManyConnections)
+}
+
+func TestHolepunch(t *testing.T) {
+	defer leaktest.Check(t)()
+	enableHolepunch := func(cfg *Config) { cfg.HolepunchEnabled = true }
+
+	// Relay has the info but not the data, so the target keeps the connection open.
+	relay, closeRelay := newTestSessionWithConfig(t, enableHolepunch)
+	defer closeRelay()
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor, err := relay.AddTorrent(f, nil)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	relayAddr := "127.0.0.1:" + strconv.Itoa(<-tor.torrent.NotifyListen())
+
+	target, closeTarget := newTestSessionWithConfig(t, enableHolepunch)
+	defer closeTarget()
+	tor, err = target.AddURI(torrentMagnetLink+"&x.pe="+relayAddr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	targetAddr := &net.TCPAddr{IP: net.IPv4(127, 0, 0, 1), Port: <-tor.torrent.NotifyListen()}
+
+	// The test acts as the initiator on a different IP because only one connection is made to an IP.
+	l, err := net.Listen("tcp4", "127.0.0.2:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	listenAddr := l.Addr().(*net.TCPAddr)
+	p := dialTestPeer(t, relayAddr, "127.0.0.2")
+	defer p.Close()
+	p.send(t, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDHandshake,
+		Payload: peerprotocol.ExtensionHandshakeMessage{
+			M:          map[string]uint8{peerprotocol.ExtensionKeyHolepunch: peerprotocol.ExtensionIDHolepunch},
+			ListenPort: listenAddr.Port,
+		},
+	})
+
+	// Target is connected to the relay from an ephemeral port, so the relay must find it by the advertised listen port.
+	var connect peerprotocol.ExtensionHolepunchMessage
+	for start := time.Now(); connect.Type != peerprotocol.ExtensionHolepunchMessageTypeConnect; {
+		if time.Since(start) > timeout {
+			t.Fatal("rendezvous is not relayed")
+		}
+		p.send(t, peerprotocol.ExtensionMessage{
+			ExtendedMessageID: peerprotocol.ExtensionIDHolepunch,
+			Payload: peerprotocol.ExtensionHolepunchMessage{
+				Type: peerprotocol.ExtensionHolepunchMessageTypeRendezvous,
+				Addr: targetAddr,
+			},
+		})
+		msg := p.waitMessage(t, func(msg any) bool {
+			_, ok := msg.(peerprotocol.ExtensionHolepunchMessage)
+			return ok
+		})
+		connect = msg.(peerprotocol.ExtensionHolepunchMessage)
+		if connect.Type == peerprotocol.ExtensionHolepunchMessageTypeError {
+			// Target has not connected to the relay yet.
+			assert.Equal(t, uint32(peerprotocol.HolepunchErrNotConnected), connect.Error)
+			time.Sleep(100 * time.Millisecond)
+		}
+	}
+	assert.Equal(t, targetAddr.String(), connect.Addr.String())
+
+	// Target must connect to our listen address from its own listen port.
+	_ = l.(*net.TCPListener).SetDeadline(time.Now().Add(timeout))
+	conn, err := l.Accept()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	assert.Equal(t, targetAddr.Port, conn.RemoteAddr().(*net.TCPAddr).Port)
+	handshake := make([]byte, 68)
+	_ = conn.SetReadDeadline(time.Now().Add(timeout))
+	_, err = io.ReadFull(conn, handshake)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, torrentInfoHashString, hex.EncodeToString(handshake[28:48]))
+}
+
+func TestIsUnreachable(t *testing.T) {
+	l, err := net.Listen("tcp4", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := l.Addr().String()
+	conn, err := net.Dial("tcp4", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = conn.SetReadDeadline(time.Now())
+	_, err = conn.Read(make([]byte, 1))
+	conn.Close()
+	l.Close()
+	assert.False(t, isUnreachable(err), "read timeout on an open connection")
+
+	_, err = net.Dial("tcp4", addr)
+	assert.True(t, isUnreachable(err), "connection refused")
+}
+
+func TestUploadCapacity(t *testing.T) {
+	// No limits
+	assert.Equal(t, int64(0), uploadCapacity(0, 0, 100, 200))
+	// Torrent limit only
+	assert.Equal(t, int64(50), uploadCapacity(50, 0, 100, 200))
+	// Session limit is shared with other torrents uploading at 100.
+	assert.Equal(t, int64(900), uploadCapacity(0, 1000, 100, 200))
+	assert.Equal(t, int64(500), uploadCapacity(500, 1000, 100, 200))
+	// Other torrents use all of the session limit.
+	assert.Equal(t, int64(1), uploadCapacity(0, 1000, 0, 1000))
+}
+
+func TestSendDontHave(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	addr := startSeeding(t, s, true)
+	tor := s.ListTorrents()[0]
+	select {
+	case <-tor.torrent.NotifyComplete():
+	case <-time.After(timeout):
+		t.Fatal("seeder is not complete")
+	}
+
+	p := dialTestPeer(t, addr, "127.0.0.1")
+	defer p.Close()
+	p.send(t, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDHandshake,
+		Payload: peerprotocol.ExtensionHandshakeMessage{
+			M: map[string]uint8{peerprotocol.ExtensionKeyDontHave: peerprotocol.ExtensionIDDontHave},
+		},
+	})
+	p.send(t, peerprotocol.InterestedMessage{})
+	p.waitMessage(t, func(msg any) bool {
+		_, ok := msg.(peerprotocol.UnchokeMessage)
+		return ok
+	})
+	const index = 0
+
+	// Piece is lost when it cannot be read for uploading.
+	err := filepath.Walk(filepath.Join(s.config.DataDir, tor.ID()), func(path string, info os.FileInfo, err error) error {
+		if err != nil || info.IsDir() {
+			return err
+		}
+		return os.Truncate(path, 0)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	p.send(t, peerprotocol.RequestMessage{Index: index, Begin: 0, Length: 1})
+	msg := p.waitMessage(t, func(msg any) bool {
+		_, ok := msg.(peerprotocol.ExtensionDontHaveMessage)
+		return ok
+	})
+	assert.Equal(t, uint32(index), msg.(peerprotocol.ExtensionDontHaveMessage).Index)
+	stats := tor.Stats()
+	assert.Equal(t, Downloading, stats.Status)
+	assert.Equal(t, stats.Pieces.Total-1, stats.Pieces.Have)
+}
+
+func TestReceiveDontHave(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor, err := s.AddTorrent(f, &AddTorrentOptions{Stopped: true})
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor.torrent.trackers = nil
+	err = tor.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "127.0.0.1:" + strconv.Itoa(<-tor.torrent.NotifyListen())
+
+	p := dialTestPeer(t, addr, "127.0.0.1")
+	defer p.Close()
+	bf := make([]byte, (tor.torrent.info.NumPieces+7)/8)
+	bf[0] = 0x80
+	p.send(t, &peerprotocol.BitfieldMessage{Data: bf})
+	p.waitMessage(t, func(msg any) bool {
+		_, ok := msg.(peerprotocol.InterestedMessage)
+		return ok
+	})
+
+	// Peer does not have any piece that we need after donthave message.
+	p.send(t, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDDontHave,
+		Payload:           peerprotocol.ExtensionDontHaveMessage{Index: 0},
+	})
+	p.waitMessage(t, func(msg any) bool {
+		_, ok := msg.(peerprotocol.NotInterestedMessage)
+		return ok
+	})
+}
+
+func isUploadOnlyHandshake(msg any) bool {
+	hs, ok := msg.(peerprotocol.ExtensionHandshakeMessage)
+	return ok && hs.UploadOnly == 1
+}
+
+func TestUploadOnlySeeding(t *testing.T) {
+	defer leaktest.Check(t)()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	addr := startSeeding(t, s, true)
+	select {
+	case <-s.ListTorrents()[0].torrent.NotifyComplete():
+	case <-time.After(timeout):
+		t.Fatal("seeder is not complete")
+	}
+
+	p := dialTestPeer(t, addr, "127.0.0.1")
+	defer p.Close()
+	p.waitMessage(t, isUploadOnlyHandshake)
+
+	// Seeders have nothing to exchange.
+	p.send(t, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDHandshake,
+		Payload:           peerprotocol.ExtensionHandshakeMessage{UploadOnly: 1},
+	})
+	p.waitClosed(t)
+}
+
+func TestUploadOnlyComplete(t *testing.T) {
+	defer leaktest.Check(t)()
+	s1, closeSession1 := newTestSession(t)
+	defer closeSession1()
+	seederAddr := startSeeding(t, s1, true)
+
+	s2, closeSession2 := newTestSession(t)
+	defer closeSession2()
+	f, err := os.Open(torrentFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor, err := s2.AddTorrent(f, &AddTorrentOptions{Stopped: true})
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor.torrent.trackers = nil
+	err = tor.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	addr := "127.0.0.1:" + strconv.Itoa(<-tor.torrent.NotifyListen())
+
+	// Test peers use other addresses than the seeder because one connection is allowed per IP.
+	uploadOnly := dialTestPeer(t, addr, "127.0.0.2")
+	defer uploadOnly.Close()
+	uploadOnly.send(t, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDHandshake,
+		Payload:           peerprotocol.ExtensionHandshakeMessage{UploadOnly: 1},
+	})
+	// Interested too, so that it is dropped only for being upload only.
+	uploadOnly.send(t, peerprotocol.InterestedMessage{})
+	interested := dialTestPeer(t, addr, "127.0.0.3")
+	defer interested.Close()
+	interested.send(t, peerprotocol.ExtensionMessage{
+		ExtendedMessageID: peerprotocol.ExtensionIDHandshake,
+		Payload:           peerprotocol.ExtensionHandshakeMessage{},
+	})
+	interested.send(t, peerprotocol.InterestedMessage{})
+
+	deadline := time.Now().Add(timeout)
+	for {
+		var found bool
+		for _, pe := range tor.Peers() {
+			if pe.UploadOnly {
+				found = true
+			}
+		}
+		if found {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("upload only peer is not found")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	err = tor.AddPeer(seederAddr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case <-tor.torrent.NotifyComplete():
+	case <-time.After(timeout):
+		t.Fatal("download is not completed")
+	}
+
+	// Interested peer is kept and told that we became a seeder.
+	interested.waitMessage(t, isUploadOnlyHandshake)
+	uploadOnly.waitClosed(t)
+}
+
+func TestOutgoingIP(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t, true)
+	defer cl()
+
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.OutgoingIP = "127.0.0.1"
+	})
+	defer closeSession()
+
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+addr, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCompleted(t, tor)
+}
+
+func TestOutgoingInterfaceKillSwitch(t *testing.T) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.OutgoingInterface = "rain-missing0"
+		cfg.OutgoingInterfaceCheckInterval = 100 * time.Millisecond
+		// DHT is started when the interface becomes available.
+		cfg.DHTEnabled = true
+		cfg.DHTPort = 5016
+		cfg.DHTBootstrapNodes = nil
+	})
+	defer closeSession()
+
+	tor, err := s.AddURI(torrentMagnetLink, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err = <-tor.torrent.NotifyError():
+		assert.Equal(t, errOutgoingInterfaceDown, err)
+	case <-time.After(timeout):
+		t.Fatal("torrent is not stopped")
+	}
+	s.mDHT.RLock()
+	assert.Nil(t, s.dht)
+	s.mDHT.RUnlock()
+}
+
+func TestOutgoingInterfaceRestartTorrents(t *testing.T) {
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	tor, err := s.AddURI(torrentMagnetLink, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	stopped, err := s.AddURI(torrentMagnetLink, &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	errC := tor.torrent.NotifyError()
+	s.stopTorrentsOnInterfaceDown()
+	select {
+	case err = <-errC:
+		assert.Equal(t, errOutgoingInterfaceDown, err)
+	case <-time.After(timeout):
+		t.Fatal("torrent is not stopped")
+	}
+	// Torrents that are already stopped are skipped.
+	s.stopTorrentsOnInterfaceDown()
+	assert.Equal(t, map[string]struct{}{tor.ID(): {}}, s.interfaceStopped)
+
+	s.startTorrentsOnInterfaceUp()
+	assert.NotEqual(t, Stopped, tor.Stats().Status)
+	assert.Equal(t, Stopped, stopped.Stats().Status)
+	assert.Empty(t, s.interfaceStopped)
+}
+
+func TestEncryptionPolicy(t *testing.T) {
+	defer leaktest.Check(t)()
+	addr, cl := seeder(t, true)
+	defer cl()
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+
+	_, err := s.AddURI(torrentMagnetLink, &AddTorrentOptions{Encryption: "invalid"})
+	assert.Equal(t, errInvalidEncryptionPolicy, errors.Unwrap(err))
+
+	tor, err := s.AddURI(torrentMagnetLink+"&x.pe="+addr, &AddTorrentOptions{Encryption: EncryptionForce})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assertCompleted(t, tor)
+	assert.Equal(t, encryptioncache.Encrypted, s.encryptionCache.Get(addr))
+	for _, pe := range tor.Peers() {
+		assert.True(t, pe.EncryptedStream)
+	}
+}
+
+func TestSpeedLimits(t *testing.T) {
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := testConfig(tmp)
+	cfg.SpeedLimitDownload = 100
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor, err := s.AddURI(torrentMagnetLink, &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(100), s.Stats().SpeedLimitDownload)
+	s.SetSpeedLimits(200, 50)
+	assert.Equal(t, int64(200), s.Stats().SpeedLimitDownload)
+	assert.Equal(t, int64(50), s.Stats().SpeedLimitUpload)
+
+	err = tor.SetSpeedLimits(10, 20)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, int64(10), tor.Stats().SpeedLimit.Download)
+	assert.Equal(t, int64(20), tor.Stats().SpeedLimit.Upload)
+
+	assert.Equal(t, BandwidthPriorityNormal, tor.Stats().BandwidthPriority)
+	err = tor.SetBandwidthPriority(BandwidthPriorityHigh)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Error(t, tor.SetBandwidthPriority(0))
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Torrent limits are persisted, global limits are read from config again.
+	s, err = NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	assert.Equal(t, int64(100), s.Stats().SpeedLimitDownload)
+	tor = s.GetTorrent(tor.ID())
+	assert.Equal(t, int64(10), tor.Stats().SpeedLimit.Download)
+	assert.Equal(t, int64(20), tor.Stats().SpeedLimit.Upload)
+	assert.Equal(t, BandwidthPriorityHigh, tor.Stats().BandwidthPriority)
+}
+
+func TestTurtleMode(t *testing.T) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.SpeedLimitDownload = 100
+		cfg.TurtleSpeedLimitDownload = 10
+		cfg.TurtleSpeedLimitUpload = 5
+		// Active all the time.
+		cfg.SpeedLimitSchedule = []SpeedLimitSchedule{{Start: "00:00", End: "00:00", Download: 20, Upload: 30}}
+	})
+	defer closeSession()
+	stats := s.Stats()
+	assert.Equal(t, SpeedLimitProfileScheduled, stats.SpeedLimitProfile)
+	assert.Equal(t, int64(20), stats.SpeedLimitDownload)
+	assert.Equal(t, int64(30), stats.SpeedLimitUpload)
+
+	s.SetTurtleMode(true)
+	stats = s.Stats()
+	assert.True(t, stats.TurtleMode)
+	assert.Equal(t, SpeedLimitProfileTurtle, stats.SpeedLimitProfile)
+	assert.Equal(t, int64(10), stats.SpeedLimitDownload)
+	assert.Equal(t, int64(5), stats.SpeedLimitUpload)
+
+	s.SetTurtleMode(false)
+	assert.Equal(t, SpeedLimitProfileScheduled, s.Stats().SpeedLimitProfile)
+
+	_, err := NewSession(Config{SpeedLimitSchedule: []SpeedLimitSchedule{{Start: "9am"}}, PortBegin: 1, PortEnd: 2})
+	assert.Error(t, err)
+}
+
+func TestTrafficQuota(t *testing.T) {
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := testConfig(tmp)
+	cfg.DailyTrafficQuota = 1
+	cfg.TrafficQuotaAction = TrafficQuotaStopUploads
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.metrics.SpeedDownload.Mark(1 << 19)
+	s.metrics.SpeedUpload.Mark(1 << 19)
+	s.updateTraffic(time.Now())
+	stats := s.Stats()
+	assert.Equal(t, int64(1<<19), stats.BytesDownloadedToday)
+	assert.Equal(t, int64(1<<19), stats.BytesUploadedThisMonth)
+	assert.True(t, stats.UploadsStoppedByQuota)
+	assert.False(t, stats.DownloadsStoppedByQuota)
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Counters are persisted.
+	cfg.DailyTrafficQuota = 0
+	s, err = NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	stats = s.Stats()
+	assert.Equal(t, int64(1<<19), stats.BytesDownloadedToday)
+	assert.False(t, stats.UploadsStoppedByQuota)
+}
+
+func TestScrapeStoppedTorrent(t *testing.T) {
+	defer startHTTPTracker(t)()
+
+	s, closeSession := newTestSession(t)
+	defer closeSession()
+	tor, err := s.AddURI(torrentMagnetLink+"&tr=http://127.0.0.1:5000/announce", &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	trackers := tor.Trackers()
+	assert.Len(t, trackers, 1)
+	assert.True(t, trackers[0].LastScrape.IsZero())
+
+	s.scrapeStoppedTorrents()
+	trackers = tor.Trackers()
+	assert.Len(t, trackers, 1)
+	assert.False(t, trackers[0].LastScrape.IsZero())
+	assert.Nil(t, trackers[0].ScrapeError)
+}
+
+func TestEditTrackers(t *testing.T) {
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := testConfig(tmp)
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor, err := s.AddURI(torrentMagnetLink+"&tr=http://127.0.0.1:5001/announce&tr=http://127.0.0.1:5002/announce", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{{"http://127.0.0.1:5001/announce"}, {"http://127.0.0.1:5002/announce"}}, tor.TrackerTiers())
+
+	assert.NoError(t, tor.AddTracker("udp://127.0.0.1:5003/announce"))
+	assert.NoError(t, tor.RemoveTracker("http://127.0.0.1:5001/announce"))
+	assert.Equal(t, errTrackerNotFound, tor.RemoveTracker("http://127.0.0.1:5001/announce"))
+	assert.NoError(t, tor.ReplaceTracker("http://127.0.0.1:5002/announce", "http://127.0.0.1:5002/announce?passkey=x"))
+	assert.Error(t, tor.ReplaceTracker("udp://127.0.0.1:5003/announce", "foo://bar"))
+	assert.NoError(t, tor.MoveTrackerTier(1, 0))
+	assert.Equal(t, errInvalidTierIndex, tor.MoveTrackerTier(0, 2))
+	expected := [][]string{{"udp://127.0.0.1:5003/announce"}, {"http://127.0.0.1:5002/announce?passkey=x"}}
+	assert.Equal(t, expected, tor.TrackerTiers())
+
+	// Announcers of the running torrent are restarted.
+	trackers := tor.Trackers()
+	assert.Len(t, trackers, 2)
+	assert.Equal(t, "udp://127.0.0.1:5003/announce", trackers[0].URL)
+	assert.Equal(t, "http://127.0.0.1:5002/announce?passkey=x", trackers[1].URL)
+
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	tor = s.GetTorrent(tor.ID())
+	assert.Equal(t, expected, tor.TrackerTiers())
+}
+
+func TestReplaceTrackerURLs(t *testing.T) {
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := testConfig(tmp)
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor, err := s.AddURI(torrentMagnetLink+"&tr=http://old.example.com/abc/announce&tr=http://other.example.com/announce", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = s.ReplaceTrackerURLs("(", "", false)
+	assert.Error(t, err)
+
+	changes, err := s.ReplaceTrackerURLs(`^http://old\.example\.com/\w+/`, "https://new.example.com/xyz/", true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []TrackerURLChange{{TorrentID: tor.ID(), OldURL: "http://old.example.com/abc/announce", NewURL: "https://new.example.com/xyz/announce"}}
+	assert.Equal(t, expected, changes)
+	assert.Equal(t, "http://old.example.com/abc/announce", tor.TrackerTiers()[0][0])
+
+	changes, err = s.ReplaceTrackerURLs(`^http://old\.example\.com/\w+/`, "https://new.example.com/xyz/", false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, expected, changes)
+	assert.Equal(t, [][]string{{"https://new.example.com/xyz/announce"}, {"http://other.example.com/announce"}}, tor.TrackerTiers())
+	assert.Equal(t, "https://new.example.com/xyz/announce", tor.Trackers()[0].URL)
+
+	err = s.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err = NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	tor = s.GetTorrent(tor.ID())
+	assert.Equal(t, "https://new.example.com/xyz/announce", tor.TrackerTiers()[0][0])
+}
+
+func TestDefaultTrackers(t *testing.T) {
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	trackersFile := filepath.Join(tmp, "trackers.txt")
+	err := os.WriteFile(trackersFile, []byte("# comment\nhttp://c/announce\n\nhttp://b/announce\nhttp://d/announce\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := testConfig(tmp)
+	cfg.DefaultTrackers = [][]string{{"http://a/announce"}, {"http://b/announce"}}
+	cfg.DefaultTrackersFile = trackersFile
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	tor, err := s.AddURI(torrentMagnetLink+"&tr=http://a/announce", &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{{"http://a/announce"}, {"http://b/announce"}, {"http://c/announce"}, {"http://d/announce"}}, tor.TrackerTiers())
+
+	tor, err = s.AddURI(torrentMagnetLink+"&tr=http://a/announce", &AddTorrentOptions{Stopped: true, NoDefaultTrackers: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{{"http://a/announce"}}, tor.TrackerTiers())
+
+	err = os.WriteFile(trackersFile, []byte("http://e/announce\n"), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, s.loadDefaultTrackers())
+	tor, err = s.AddURI(torrentMagnetLink, &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{{"http://a/announce"}, {"http://b/announce"}, {"http://e/announce"}}, tor.TrackerTiers())
+}
+
+func TestDefaultTrackersFileMissing(t *testing.T) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.DefaultTrackers = [][]string{{"http://a/announce"}}
+		cfg.DefaultTrackersFile = filepath.Join(cfg.DataDir, "missing.txt")
+	})
+	defer closeSession()
+
+	// Session is usable although the file cannot be read.
+	tor, err := s.AddURI(torrentMagnetLink, &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{{"http://a/announce"}}, tor.TrackerTiers())
+}
+
+func TestDefaultTrackersPrivateMagnet(t *testing.T) {
+	s1, closeSession1 := newTestSession(t)
+	defer closeSession1()
+	name := filepath.Join(s1.config.DataDir, "private.bin")
+	err := os.WriteFile(name, make([]byte, 32<<10), 0o600)
+	if err != nil {
+		t.Fatal(err)
+	}
+	info, err := metainfo.NewInfoBytes("", []string{name}, true, 16<<10, "", logger.New("test"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	mi, err := metainfo.NewBytes(info, nil, nil, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tor1, err := s1.AddTorrent(bytes.NewReader(mi), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var port int
+	select {
+	case port = <-tor1.torrent.NotifyListen():
+	case <-time.After(timeout):
+		t.Fatal("torrent is not listening")
+	}
+
+	s2, closeSession2 := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.DefaultTrackers = [][]string{{"http://a/announce"}}
+	})
+	defer closeSession2()
+	infoHash := sha1.Sum(info)
+	link := "magnet:?xt=urn:btih:" + hex.EncodeToString(infoHash[:]) + "&tr=http://b/announce&x.pe=127.0.0.1:" + strconv.Itoa(port)
+	tor2, err := s2.AddURI(link, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{{"http://b/announce"}, {"http://a/announce"}}, tor2.TrackerTiers())
+	select {
+	case <-tor2.torrent.NotifyError():
+	case <-time.After(timeout):
+		t.Fatal("private torrent is not stopped")
+	}
+	for i := 0; i < 100 && len(tor2.TrackerTiers()) > 1; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, [][]string{{"http://b/announce"}}, tor2.TrackerTiers())
+	spec, err := s2.resumer.Read(tor2.ID())
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, [][]string{{"http://b/announce"}}, spec.Trackers)
+}
+
+func TestAnnounceKey(t *testing.T) {
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := testConfig(tmp)
+	reopen := func(s *Session) *Session {
+		if s != nil {
+			assert.NoError(t, s.Close())
+		}
+		s, err := NewSession(cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+	s := reopen(nil)
+	tor, err := s.AddURI(torrentMagnetLink, &AddTorrentOptions{Stopped: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := tor.ID()
+	key := tor.torrent.announceKey
+
+	// Key does not change between restarts.
+	s = reopen(s)
+	assert.Equal(t, key, s.GetTorrent(id).torrent.announceKey)
+
+	// A new key is generated and saved for torrents that do not have a key.
+	err = s.db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(torrentsBucket).Bucket([]byte(id)).Delete(boltdbresumer.Keys.AnnounceKey)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = reopen(s)
+	key = s.GetTorrent(id).torrent.announceKey
+	assert.NotEqual(t, uint32(0), key)
+	s = reopen(s)
+	assert.Equal(t, key, s.GetTorrent(id).torrent.announceKey)
+	assert.NoError(t, s.Close())
+}
+
+func TestSaveDHTNodes(t *testing.T) {
+	tmp, closeTmp := tempdir(t)
+	defer closeTmp()
+	cfg := testConfig(tmp)
+	cfg.DHTEnabled = true
+	cfg.DHTHost = "127.0.0.1"
+	cfg.DHTPort = 5012
+	cfg.DHTBootstrapNodes = nil
+	s, err := NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var e *InputError
+	assert.True(t, errors.As(s.AddDHTNode("127.0.0.1"), &e))
+	assert.True(t, errors.As(s.AddDHTNode("127.0.0.1:0"), &e))
+	assert.NoError(t, s.AddDHTNode("127.0.0.2:5013"))
+	assert.NoError(t, s.AddDHTNode("127.0.0.1:5015"))
+	// Only the last node is kept for an IP.
+	assert.NoError(t, s.AddDHTNode("127.0.0.1:5013"))
+	assert.NoError(t, s.Close())
+
+	// Saved nodes are loaded on next start.
+	s, err = NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var nodes []string
+	s.mDHTNodes.Lock()
+	for addr := range s.dhtNodes {
+		nodes = append(nodes, addr)
+	}
+	s.mDHTNodes.Unlock()
+	assert.ElementsMatch(t, []string{"127.0.0.1:5013", "127.0.0.2:5013"}, nodes)
+	assert.NoError(t, s.Close())
+
+	// Session is usable if saved nodes cannot be loaded.
+	db, err := bbolt.Open(cfg.Database, 0o600, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.Update(func(tx *bbolt.Tx) error {
+		return tx.Bucket(sessionBucket).Put(dhtNodesKey, []byte("invalid"))
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.NoError(t, db.Close())
+	s, err = NewSession(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	_, err = s.AddURI(torrentMagnetLink, &AddTorrentOptions{Stopped: true})
+	assert.NoError(t, err)
+}
+
+func TestBindDHT(t *testing.T) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.DHTEnabled = true
+		cfg.DHTHost = "127.0.0.1"
+		cfg.DHTPort = 5018
+		cfg.DHTBootstrapNodes = nil
+	})
+	defer closeSession()
+
+	assert.NoError(t, s.bindDHT(nil))
+	assert.Nil(t, s.dht)
+	assert.NoError(t, s.AddDHTNode("127.0.0.1:5019"))
+	assert.NoError(t, s.bindDHT(net.IPv4(127, 0, 0, 1)))
+	assert.NotNil(t, s.dht)
+	assert.Equal(t, "127.0.0.1", s.dhtAddress)
+}
+
+func TestDHTStats(t *testing.T) {
+	s, closeSession := newTestSessionWithConfig(t, func(cfg *Config) {
+		cfg.DHTEnabled = true
+		cfg.DHTHost = "127.0.0.1"
+		cfg.DHTPort = 5014
+		cfg.DHTBootstrapNodes = nil
+	})
+	defer closeSession()
+
+	tor, err := s.AddURI(torrentMagnetLink, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var dhtTracker *Tracker
+	for i := 0; i < 30 && (dhtTracker == nil || dhtTracker.Status == NotContactedYet); i++ {
+		time.Sleep(100 * time.Millisecond)
+		for _, tr := range tor.Trackers() {
+			if tr.URL == dhtTrackerURL {
+				tr := tr
+				dhtTracker = &tr
+			}
+		}
+	}
+	if dhtTracker == nil {
+		t.Fatal("DHT is not in trackers")
+	}
+	assert.Equal(t, Contacting, dhtTracker.Status)
+	assert.False(t, dhtTracker.LastAnnounce.IsZero())
+
+	// Node counter is incremented when a node responds and is added to the routing table.
+	other, err := newDHTNode(&Config{DHTPort: 5022}, "127.0.0.1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer stopDHTNode(other)
+	s.mDHT.RLock()
+	s.dht.AddNode("127.0.0.1:5022")
+	s.mDHT.RUnlock()
+	for i := 0; i < 30 && s.Stats().DHTNodes == 0; i++ {
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	// Reachable nodes of other DHT nodes in the process are not counted.
+	// The value of our node is not set until the first save period.
+	m := expvar.Get("reachableNodes").(*expvar.Map)
+	stale := new(expvar.Int)
+	stale.Set(1000)
+	m.Set("stale", stale)
+	defer m.Delete("stale")
+	stats := s.Stats()
+	assert.NotZero(t, stats.DHTNodes)
+	assert.Zero(t, stats.DHTGoodNodes)
+	assert.Equal(t, stats.DHTNodes, stats.DHTBadNodes)
+
+	s.mDHT.RLock()
+	id := dhtNodeID(s.dht)
+	s.mDHT.RUnlock()
+	assert.Len(t, id, 40)
+	good := new(expvar.Int)
+	good.Set(1)
+	m.Set(id, good)
+	stats = s.Stats()
+	assert.Equal(t, 1, stats.DHTGoodNodes)
+	assert.Equal(t, stats.DHTNodes-1, stats.DHTBadNodes)
+}
+
 func startHTTPTracker(t *testing.T) (stop func()) {
 	responseConfig := middleware.ResponseConfig{
 		AnnounceInterval: time.Minute,
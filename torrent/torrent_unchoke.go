@@ -0,0 +1,89 @@
+package torrent
+
+import (
+	"github.com/cenkalti/rain/internal/unchoker"
+)
+
+// ChokingStrategy decides which interested peers are unchoked in regular upload slots.
+// Sort is called every 10 seconds with the interested peers of a torrent.
+// Peers at the beginning of the slice get the slots.
+// Optimistic unchoke slots are handled separately and are not affected by the strategy.
+type ChokingStrategy = unchoker.Strategy
+
+// ChokingPeer contains the information about a peer that is given to a ChokingStrategy.
+type ChokingPeer = unchoker.PeerStats
+
+// Built-in choking algorithms that can be set in Config.SeedChokingAlgorithm.
+const (
+	// ChokingFastestUpload unchokes the peers that we upload to fastest.
+	ChokingFastestUpload = "fastest-upload"
+	// ChokingRoundRobin gives every interested peer a turn for 30 seconds.
+	ChokingRoundRobin = "round-robin"
+	// ChokingAntiLeech unchokes the peers that have just started downloading or are about to finish.
+	ChokingAntiLeech = "anti-leech"
+)
+
+// Number of unchoke rounds (10 seconds each) that a peer is kept unchoked in round-robin algorithm.
+const roundRobinRounds = 3
+
+func validSeedChokingAlgorithm(s string) bool {
+	switch s {
+	case "", ChokingFastestUpload, ChokingRoundRobin, ChokingAntiLeech:
+		return true
+	}
+	return false
+}
+
+// newChokingStrategy returns a new ChokingStrategy instance for a torrent.
+// Peers are ranked by download speed while downloading in all built-in algorithms.
+func (s *Session) newChokingStrategy() ChokingStrategy {
+	if s.config.ChokingStrategy != nil {
+		return s.config.ChokingStrategy()
+	}
+	switch s.config.SeedChokingAlgorithm {
+	case ChokingRoundRobin:
+		return unchoker.NewRoundRobin(roundRobinRounds)
+	case ChokingAntiLeech:
+		return unchoker.NewAntiLeech()
+	default:
+		return unchoker.NewFastest()
+	}
+}
+
+func (t *torrent) tickUnchoke() {
+	if t.pausedByQuota && !t.session.downloadsStopped() {
+		t.pausedByQuota = false
+		t.startPieceDownloaders()
+	}
+	if t.session.uploadsStopped() {
+		t.unchoker.ChokeAll(t.getPeersForUnchoker())
+		return
+	}
+	if t.uploadSlotTuner != nil {
+		rate := int64(t.uploadSpeed.Rate1())
+		limit := uploadCapacity(t.bucketUpload.Rate(), t.session.bucketUpload.Rate(), rate, int64(t.session.metrics.SpeedUpload.Rate1()))
+		slots := t.uploadSlotTuner.Update(int(rate), int(limit))
+		if slots != t.unchoker.NumUnchoked() {
+			t.log.Debugln("number of upload slots changed:", slots)
+			t.unchoker.SetNumUnchoked(slots)
+		}
+	}
+	t.unchoker.TickUnchoke(t.getPeersForUnchoker(), t.completed)
+}
+
+// uploadCapacity returns the upload limit of a torrent in bytes/s for tuning the number of upload slots.
+// Session limit is shared by all torrents, so the torrent can only use the part that other torrents do not use.
+// Returns zero if there is no limit.
+func uploadCapacity(torrentLimit, sessionLimit, torrentRate, sessionRate int64) int64 {
+	if sessionLimit <= 0 {
+		return torrentLimit
+	}
+	share := sessionLimit - (sessionRate - torrentRate)
+	if share < 1 {
+		share = 1
+	}
+	if torrentLimit > 0 && torrentLimit < share {
+		return torrentLimit
+	}
+	return share
+}